@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/bundle"
+	"github.com/pkg/errors"
+)
+
+type bundleGroup struct {
+	bundle bundle.Bundle
+}
+
+func (bg bundleGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	bundles, err := bg.bundle.Query(ctx, v.TraceID)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, bundles, http.StatusOK)
+}
+
+func (bg bundleGroup) queryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	bnd, err := bg.bundle.QueryByID(ctx, v.TraceID, params["id"])
+	if err != nil {
+		switch err {
+		case bundle.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case bundle.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, bnd, http.StatusOK)
+}
+
+func (bg bundleGroup) queryBySlug(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	bnd, err := bg.bundle.QueryBySlug(ctx, v.TraceID, params["slug"])
+	if err != nil {
+		switch err {
+		case bundle.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "Slug: %s", params["slug"])
+		}
+	}
+
+	return web.Respond(ctx, w, bnd, http.StatusOK)
+}
+
+func (bg bundleGroup) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var nb bundle.NewBundle
+	if err := web.Decode(r, &nb); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	bnd, err := bg.bundle.Create(ctx, v.TraceID, claims, nb, v.Now)
+	if err != nil {
+		switch err {
+		case bundle.ErrUnknownProduct:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case bundle.ErrSlugTaken:
+			return web.NewRequestError(err, http.StatusConflict)
+		case bundle.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "creating new bundle: %+v", nb)
+		}
+	}
+
+	return web.Respond(ctx, w, bnd, http.StatusCreated)
+}
+
+func (bg bundleGroup) update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var ub bundle.UpdateBundle
+	if err := web.Decode(r, &ub); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := bg.bundle.Update(ctx, v.TraceID, claims, params["id"], ub, v.Now); err != nil {
+		switch err {
+		case bundle.ErrInvalidID, bundle.ErrUnknownProduct:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case bundle.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case bundle.ErrSlugTaken:
+			return web.NewRequestError(err, http.StatusConflict)
+		case bundle.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s  Bundle: %+v", params["id"], &ub)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (bg bundleGroup) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := bg.bundle.Delete(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case bundle.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case bundle.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -2,60 +2,314 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
-	"io/ioutil"
+	"math"
 	"net/http"
+	"time"
 
 	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/bundle"
 	"github.com/igorbelousov/shop-backend/internal/data/product"
+	"github.com/igorbelousov/shop-backend/internal/data/promotion"
+	"github.com/igorbelousov/shop-backend/internal/data/shipping"
+	"github.com/igorbelousov/shop-backend/internal/data/tax"
+	"github.com/igorbelousov/shop-backend/internal/metrics"
+	"github.com/pkg/errors"
 )
 
 type cartGroup struct {
-	product product.Product
+	product   product.Product
+	bundle    bundle.Bundle
+	promotion promotion.Promotion
+	shipping  shipping.Shipping
+	tax       tax.Tax
 }
 
+// CartRequest is a single position of the cart sent by the client. It
+// holds either a product ID or a bundle ID.
+type CartRequest struct {
+	ID       string `json:"id"`
+	BundleID string `json:"bundle_id"`
+	Qty      int    `json:"qty" validate:"gt=0"`
+}
+
+// CartItem is a single position of the cart with its product or bundle
+// loaded. A bundle is shown as one item but priced through its components.
+type CartItem struct {
+	Product product.Info
+	Bundle  *bundle.Info `json:"Bundle,omitempty"`
+	Qty     int
+}
+
+// Cart is the priced cart returned to the client.
+type Cart struct {
+	Items     []CartItem           `json:"items"`
+	Discounts []promotion.Discount `json:"discounts"`
+	Subtotal  float64              `json:"subtotal"`
+	Discount  float64              `json:"discount"`
+	Tax       tax.Summary          `json:"tax"`
+	Total     float64              `json:"total"`
+}
+
+// query prices the posted cart. A coupon code can be passed with the
+// coupon query parameter, the address taxes are calculated for with the
+// country and region query parameters.
 func (bc cartGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
 
-	type CartRequest struct {
-		ID  string `json:"id"`
-		Qty int    `json:"qty"`
+	cr, err := decodeCart(r)
+	if err != nil {
+		return err
 	}
 
-	type CartItem struct {
-		Product product.Info
-		Qty     int
+	qs := r.URL.Query()
+	addr := shipping.Address{
+		Country: qs.Get("country"),
+		Region:  qs.Get("region"),
 	}
 
-	ci := []CartItem{}
-	cr := []CartRequest{}
+	c, err := bc.price(ctx, v, cr, qs.Get("coupon"), addr)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, c, http.StatusOK)
+}
 
+// shippingQuote returns the shipping methods available for the cart and
+// address with their prices.
+func (bc cartGroup) shippingQuote(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	v, ok := ctx.Value(web.KeyValues).(*web.Values)
 	if !ok {
 		return web.NewShutdownError("web value missing from context")
 	}
 
-	b, err := ioutil.ReadAll(r.Body)
-	defer r.Body.Close()
+	var qr struct {
+		Items   []CartRequest    `json:"items" validate:"required,min=1,dive"`
+		Address shipping.Address `json:"address"`
+		Coupon  string           `json:"coupon"`
+	}
+	if err := web.Decode(r, &qr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+	if err := checkCart(qr.Items); err != nil {
+		return err
+	}
+
+	c, err := bc.price(ctx, v, qr.Items, qr.Coupon, qr.Address)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		return err
+	}
+
+	var weight float64
+	for _, item := range c.Items {
+		if item.Bundle == nil {
+			weight += item.Product.Weight * float64(item.Qty)
+			continue
+		}
+		for _, comp := range item.Bundle.Items {
+			weight += comp.Weight * float64(comp.Qty*item.Qty)
+		}
+	}
 
+	var free bool
+	for _, d := range c.Discounts {
+		free = free || d.FreeShipping
 	}
-	err = json.Unmarshal(b, &cr)
+
+	quotes, err := bc.shipping.Quote(ctx, v.TraceID, qr.Address, weight, c.Total, free)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		return errors.Wrap(err, "quoting shipping")
+	}
+
+	return web.Respond(ctx, w, quotes, http.StatusOK)
+}
+
+// price loads the products of the cart, applies the promotions and
+// calculates the taxes for the address. The cart can be priced for
+// anonymous users, claims are only used to enforce per customer coupon
+// limits.
+func (bc cartGroup) price(ctx context.Context, v *web.Values, cr []CartRequest, coupon string, addr shipping.Address) (Cart, error) {
+	var userID string
+	if claims, ok := ctx.Value(auth.Key).(auth.Claims); ok {
+		userID = claims.Subject
 	}
 
-	for i, _ := range cr {
-		br, err := bc.product.QueryByID(ctx, v.TraceID, cr[i].ID)
+	items, err := bc.items(ctx, v.TraceID, cr, v.Now)
+	if err != nil {
+		return Cart{}, err
+	}
+
+	for _, item := range items {
+		if item.Bundle != nil {
+			for _, comp := range item.Bundle.Items {
+				if err := comp.Available(comp.Qty*item.Qty, v.Now); err != nil {
+					err = errors.Wrapf(err, "bundle %s", item.Bundle.Title)
+					return Cart{}, web.NewRequestError(err, http.StatusConflict)
+				}
+			}
+			continue
+		}
+		if err := item.Product.Available(item.Qty, v.Now); err != nil {
+			return Cart{}, web.NewRequestError(err, http.StatusConflict)
+		}
+	}
+
+	lines := []promotion.Line{}
+	for _, item := range items {
+		if item.Bundle == nil {
+			lines = append(lines, promotion.Line{
+				ProductID:  item.Product.ID,
+				CategoryID: item.Product.CategoryID,
+				BrandID:    item.Product.BrandID,
+				Price:      item.Product.Price,
+				Qty:        item.Qty,
+			})
+			continue
+		}
+		lines = append(lines, bundleLines(*item.Bundle, item.Qty)...)
+	}
+
+	discounts, err := bc.promotion.Apply(ctx, v.TraceID, userID, coupon, lines, v.Now)
+	if err != nil {
+		switch err {
+		case promotion.ErrInvalidCode, promotion.ErrUsageLimit:
+			return Cart{}, web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return Cart{}, errors.Wrap(err, "applying promotions")
+		}
+	}
+
+	c := Cart{
+		Items:     items,
+		Discounts: discounts,
+	}
+	for _, line := range lines {
+		c.Subtotal += line.Price * float64(line.Qty)
+	}
+	for _, d := range discounts {
+		c.Discount += d.Amount
+	}
+	c.Subtotal = math.Round(c.Subtotal*100) / 100
+	c.Discount = math.Round(c.Discount*100) / 100
+
+	// Taxes are charged on the discounted amounts, so spread the cart
+	// discount over the lines proportionally.
+	taxLines := make([]tax.Line, len(lines))
+	for i, line := range lines {
+		amount := line.Price * float64(line.Qty)
+		if c.Subtotal > 0 {
+			amount -= c.Discount * amount / c.Subtotal
+		}
+		taxLines[i] = tax.Line{
+			ProductID: line.ProductID,
+			Amount:    amount,
+		}
+	}
+
+	c.Tax, err = bc.tax.Calculate(ctx, v.TraceID, addr.Country, addr.Region, taxLines)
+	if err != nil {
+		return Cart{}, errors.Wrap(err, "calculating taxes")
+	}
+	c.Total = c.Tax.Gross
+
+	metrics.CartsPriced.Inc()
+
+	return c, nil
+}
+
+// items loads the products and bundles of the cart positions with their
+// prices at now.
+func (bc cartGroup) items(ctx context.Context, traceID string, cr []CartRequest, now time.Time) ([]CartItem, error) {
+	items := []CartItem{}
+	for _, line := range cr {
+		if line.BundleID != "" {
+			bnd, err := bc.bundle.QueryByID(ctx, traceID, line.BundleID)
+			if err != nil {
+				switch err {
+				case bundle.ErrInvalidID:
+					return nil, web.NewRequestError(err, http.StatusBadRequest)
+				case bundle.ErrNotFound:
+					return nil, web.NewRequestError(err, http.StatusNotFound)
+				default:
+					return nil, errors.Wrapf(err, "ID: %s", line.BundleID)
+				}
+			}
+			items = append(items, CartItem{
+				Bundle: &bnd,
+				Qty:    line.Qty,
+			})
+			continue
+		}
+
+		prod, err := bc.product.QueryByID(ctx, traceID, line.ID, now)
 		if err != nil {
-			return err
+			switch err {
+			case product.ErrInvalidID:
+				return nil, web.NewRequestError(err, http.StatusBadRequest)
+			case product.ErrNotFound:
+				return nil, web.NewRequestError(err, http.StatusNotFound)
+			default:
+				return nil, errors.Wrapf(err, "ID: %s", line.ID)
+			}
+		}
+		items = append(items, CartItem{
+			Product: prod,
+			Qty:     line.Qty,
+		})
+	}
+
+	return items, nil
+}
+
+// bundleLines expands a bundle into the lines of its components so
+// promotions and taxes apply per product. The bundle price is spread over
+// the components in proportion to their regular prices.
+func bundleLines(bnd bundle.Info, qty int) []promotion.Line {
+	lines := make([]promotion.Line, len(bnd.Items))
+	for i, comp := range bnd.Items {
+		price := bnd.Price / float64(len(bnd.Items)*comp.Qty)
+		if bnd.RegularPrice > 0 {
+			price = comp.Price * bnd.Price / bnd.RegularPrice
+		}
+		lines[i] = promotion.Line{
+			ProductID:  comp.ProductID,
+			CategoryID: comp.CategoryID,
+			BrandID:    comp.BrandID,
+			Price:      price,
+			Qty:        comp.Qty * qty,
+		}
+	}
+	return lines
+}
+
+// decodeCart reads the list of cart positions from the request body.
+func decodeCart(r *http.Request) ([]CartRequest, error) {
+	cr := []CartRequest{}
+	if err := web.Decode(r, &cr); err != nil {
+		return nil, err
+	}
+
+	if err := checkCart(cr); err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// checkCart validates the cart positions sent by the client.
+func checkCart(cr []CartRequest) error {
+	for _, line := range cr {
+		if (line.ID == "") == (line.BundleID == "") {
+			return web.NewRequestError(errors.New("cart line needs either id or bundle_id"), http.StatusBadRequest)
 		}
-		c := CartItem{
-			Product: br,
-			Qty:     cr[i].Qty,
+		if line.Qty <= 0 {
+			return web.NewRequestError(errors.Errorf("invalid qty %d for product %s", line.Qty, line.ID), http.StatusBadRequest)
 		}
-		ci = append(ci, c)
 	}
 
-	return web.Respond(ctx, w, ci, http.StatusOK)
+	return nil
 }
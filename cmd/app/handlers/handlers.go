@@ -1,27 +1,66 @@
 package handlers
 
 import (
-	"log"
+	"net"
 	"net/http"
 	"os"
 
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/foundation/ratelimit"
 	"github.com/igorbelousov/shop-backend/foundation/web"
 	"github.com/igorbelousov/shop-backend/internal/auth"
 	"github.com/igorbelousov/shop-backend/internal/data/acategory"
 	"github.com/igorbelousov/shop-backend/internal/data/article"
 	"github.com/igorbelousov/shop-backend/internal/data/brand"
+	"github.com/igorbelousov/shop-backend/internal/data/bundle"
 	"github.com/igorbelousov/shop-backend/internal/data/category"
 	"github.com/igorbelousov/shop-backend/internal/data/product"
+	"github.com/igorbelousov/shop-backend/internal/data/promotion"
+	"github.com/igorbelousov/shop-backend/internal/data/review"
+	"github.com/igorbelousov/shop-backend/internal/data/shipping"
 	"github.com/igorbelousov/shop-backend/internal/data/slide"
+	"github.com/igorbelousov/shop-backend/internal/data/subscription"
+	"github.com/igorbelousov/shop-backend/internal/data/tax"
 	"github.com/igorbelousov/shop-backend/internal/data/user"
+	"github.com/igorbelousov/shop-backend/internal/data/viewed"
+	"github.com/igorbelousov/shop-backend/internal/data/wishlist"
 	"github.com/igorbelousov/shop-backend/internal/mid"
 	"github.com/jmoiron/sqlx"
 )
 
+// Limits are the request rates the API allows each client. Review keeps a
+// single customer from flooding the moderation queue. Clients are told
+// apart by their address as forwarded by the Proxies.
+type Limits struct {
+	Store   ratelimit.Store
+	API     ratelimit.Limit
+	Login   ratelimit.Limit
+	Review  ratelimit.Limit
+	Proxies []*net.IPNet
+}
+
 //API function for define routers
-func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, db *sqlx.DB) *web.App {
+func API(build string, shutdown chan os.Signal, log *logger.Logger, a *auth.Auth, db *sqlx.DB, corsCfg mid.CORSConfig, limits Limits, taxCfg tax.Config, subCfg subscription.Config) *web.App {
+
+	// Shared wishlists can be embedded anywhere but never with credentials.
+	routes := map[string]mid.CORSPolicy{
+		"/wishlist/shared/:token": {
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{http.MethodGet},
+			AllowedHeaders: []string{"Accept"},
+			MaxAge:         corsCfg.MaxAge,
+		},
+	}
+	for route, policy := range corsCfg.Routes {
+		routes[route] = policy
+	}
+	corsCfg.Routes = routes
+
+	// Health checks are polled by the orchestrator and never limited.
+	byIP := mid.ByClientIP(limits.Proxies)
+	apiKey := mid.Except(byIP, "/readiness", "/liveiness")
 
-	app := web.NewApp(shutdown, mid.Logger(log), mid.Errors(log), mid.Metrics(), mid.Panics(log))
+	app := web.NewApp(shutdown, log, mid.Logger(log), mid.CORS(corsCfg), mid.Compress(), mid.Errors(log), mid.Metrics(), mid.Panics(log), mid.RateLimit(limits.Store, "api", limits.API, apiKey))
 
 	cg := checkGroup{
 		build: build,
@@ -32,8 +71,9 @@ func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, d
 	app.Handle(http.MethodGet, "/liveiness", cg.liveness)
 
 	ug := userGroup{
-		user: user.New(log, db),
-		auth: a,
+		user:   user.New(log, db),
+		auth:   a,
+		viewed: viewed.New(log, db),
 	}
 
 	catg := categoryGroup{
@@ -42,6 +82,11 @@ func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, d
 
 	prod := productGroup{
 		product: product.New(log, db),
+		viewed:  viewed.New(log, db),
+	}
+
+	rev := reviewGroup{
+		review: review.New(log, db),
 	}
 
 	slide := slideGroup{
@@ -60,14 +105,45 @@ func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, d
 		article: article.New(log, db),
 	}
 
+	prm := promotionGroup{
+		promotion: promotion.New(log, db),
+	}
+
+	ship := shippingGroup{
+		shipping: shipping.New(log, db),
+	}
+
+	tg := taxGroup{
+		tax: tax.New(log, db, taxCfg),
+	}
+
+	sub := subscriptionGroup{
+		subscription: subscription.New(log, db, subCfg),
+	}
+
+	bnd := bundleGroup{
+		bundle: bundle.New(log, db),
+	}
+
 	cart := cartGroup{
-		product: product.New(log, db),
+		product:   product.New(log, db),
+		bundle:    bundle.New(log, db),
+		promotion: promotion.New(log, db),
+		shipping:  shipping.New(log, db),
+		tax:       tax.New(log, db, taxCfg),
+	}
+
+	wish := wishlistGroup{
+		wishlist: wishlist.New(log, db),
+		cart:     cart,
 	}
 
-	util := new(utilsGroup)
+	util := utilsGroup{
+		log: log,
+	}
 
 	app.Handle(http.MethodGet, "/users/:page/:rows", ug.query, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
-	app.Handle(http.MethodGet, "/users/token/:kid", ug.token)
+	app.Handle(http.MethodGet, "/users/token/:kid", ug.token, mid.RateLimit(limits.Store, "login", limits.Login, byIP))
 	app.Handle(http.MethodGet, "/users/:id", ug.queryByID, mid.Authenticate(a))
 	app.Handle(http.MethodPost, "/users", ug.create, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
 	app.Handle(http.MethodPut, "/users/:id", ug.update, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
@@ -79,13 +155,45 @@ func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, d
 	app.Handle(http.MethodPost, "/category", catg.create, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
 	app.Handle(http.MethodPut, "/category/:id", catg.update, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
 	app.Handle(http.MethodDelete, "/category/:id", catg.delete, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/category/:id/attributes", prod.queryAttributes)
 
 	app.Handle(http.MethodGet, "/product/", prod.query)
-	app.Handle(http.MethodGet, "/product/:id", prod.queryByID)
+	app.Handle(http.MethodGet, "/product/:id", prod.queryByID, mid.AuthenticateOptional(a))
 	// app.Handle(http.MethodGet, "/category/:slug", prod.queryBySlug)
 	app.Handle(http.MethodPost, "/product", prod.create, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
 	app.Handle(http.MethodPut, "/product/:id", prod.update, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
 	app.Handle(http.MethodDelete, "/product/:id", prod.delete, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/product/:id/prices", prod.queryPriceHistory)
+	app.Handle(http.MethodGet, "/product/:id/reviews/:page/:rows", rev.queryByProduct)
+	app.Handle(http.MethodPost, "/product/:id/reviews", rev.create, mid.Authenticate(a), mid.RateLimit(limits.Store, "reviews", limits.Review, mid.BySubject))
+	app.Handle(http.MethodPut, "/product/:id/attributes", prod.setAttributes, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/product/:id/relations", prod.queryRelations)
+	app.Handle(http.MethodPost, "/product/:id/relations", prod.addRelation, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, "/product/:id/relations/:type/:related", prod.removeRelation, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	app.Handle(http.MethodPost, "/attribute", prod.createAttribute, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, "/attribute/:id", prod.deleteAttribute, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	app.Handle(http.MethodGet, "/review/:status/:page/:rows", rev.queryByStatus, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPut, "/review/:id/status", rev.moderate, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/review/:id/vote", rev.vote, mid.Authenticate(a))
+	app.Handle(http.MethodDelete, "/review/:id", rev.delete, mid.Authenticate(a))
+
+	app.Handle(http.MethodPost, "/product/:id/subscriptions", sub.create)
+	app.Handle(http.MethodGet, "/subscription/unsubscribe/:token", sub.unsubscribe)
+
+	app.Handle(http.MethodGet, "/bundle/", bnd.query)
+	app.Handle(http.MethodGet, "/bundle/:id", bnd.queryByID)
+	app.Handle(http.MethodGet, "/bundle/slug/:slug", bnd.queryBySlug)
+	app.Handle(http.MethodPost, "/bundle", bnd.create, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPut, "/bundle/:id", bnd.update, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, "/bundle/:id", bnd.delete, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	app.Handle(http.MethodGet, "/viewed/", prod.queryViewed, mid.AuthenticateOptional(a))
+
+	app.Handle(http.MethodGet, "/price-schedule/", prod.querySchedules, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/price-schedule", prod.createSchedule, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, "/price-schedule/:id", prod.deleteSchedule, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
 
 	app.Handle(http.MethodGet, "/slide/", slide.query)
 	app.Handle(http.MethodGet, "/slide/:id", slide.queryByID)
@@ -114,7 +222,40 @@ func API(build string, shutdown chan os.Signal, log *log.Logger, a *auth.Auth, d
 	app.Handle(http.MethodPut, "/article/:id", art.update, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
 	app.Handle(http.MethodDelete, "/article/:id", art.delete, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
 
-	app.Handle(http.MethodPost, "/cart/", cart.query)
+	app.Handle(http.MethodGet, "/promotion/", prm.query, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, "/promotion/:id", prm.queryByID, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/promotion", prm.create, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPut, "/promotion/:id", prm.update, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, "/promotion/:id", prm.delete, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	app.Handle(http.MethodPost, "/cart/", cart.query, mid.AuthenticateOptional(a))
+	app.Handle(http.MethodPost, "/shipping/quote", cart.shippingQuote, mid.AuthenticateOptional(a))
+
+	app.Handle(http.MethodGet, "/shipping/zone/", ship.queryZones, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/shipping/zone", ship.createZone, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPut, "/shipping/zone/:id", ship.updateZone, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, "/shipping/zone/:id", ship.deleteZone, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/shipping/method", ship.createMethod, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPut, "/shipping/method/:id", ship.updateMethod, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, "/shipping/method/:id", ship.deleteMethod, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	app.Handle(http.MethodGet, "/tax/class/", tg.queryClasses, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/tax/class", tg.createClass, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, "/tax/class/:id", tg.deleteClass, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, "/tax/rate", tg.createRate, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, "/tax/rate/:id", tg.deleteRate, mid.Authenticate(a), mid.Authorize(auth.RoleAdmin))
+
+	app.Handle(http.MethodGet, "/wishlist/", wish.query, mid.Authenticate(a))
+	app.Handle(http.MethodGet, "/wishlist/shared/:token", wish.queryByToken)
+	app.Handle(http.MethodGet, "/wishlist/:id", wish.queryByID, mid.Authenticate(a))
+	app.Handle(http.MethodPost, "/wishlist", wish.create, mid.Authenticate(a))
+	app.Handle(http.MethodPut, "/wishlist/:id", wish.update, mid.Authenticate(a))
+	app.Handle(http.MethodDelete, "/wishlist/:id", wish.delete, mid.Authenticate(a))
+	app.Handle(http.MethodPost, "/wishlist/:id/items", wish.addItem, mid.Authenticate(a))
+	app.Handle(http.MethodDelete, "/wishlist/:id/items/:product", wish.removeItem, mid.Authenticate(a))
+	app.Handle(http.MethodPost, "/wishlist/:id/share", wish.share, mid.Authenticate(a))
+	app.Handle(http.MethodDelete, "/wishlist/:id/share", wish.unshare, mid.Authenticate(a))
+	app.Handle(http.MethodPost, "/wishlist/:id/cart", wish.moveToCart, mid.Authenticate(a))
 
 	app.Handle(http.MethodPost, "/upload", util.Upload, mid.Authenticate(a))
 
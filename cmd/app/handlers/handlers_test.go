@@ -0,0 +1,51 @@
+package handlers_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/igorbelousov/shop-backend/cmd/app/handlers"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/foundation/ratelimit"
+	"github.com/igorbelousov/shop-backend/internal/data/subscription"
+	"github.com/igorbelousov/shop-backend/internal/data/tax"
+	"github.com/igorbelousov/shop-backend/internal/mid"
+)
+
+// TestAPI builds the routes of the service, which panics when two of them
+// conflict, and checks preflight requests reach every path.
+func TestAPI(t *testing.T) {
+	log := logger.New(ioutil.Discard, logger.Error)
+	cors := mid.CORSConfig{
+		CORSPolicy: mid.CORSPolicy{
+			AllowedOrigins: []string{"https://shop.example.com"},
+			AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		},
+	}
+	limits := handlers.Limits{
+		Store: ratelimit.NewMemory(),
+		API:   ratelimit.Limit{Requests: 100, Per: time.Second},
+		Login: ratelimit.Limit{Requests: 100, Per: time.Second},
+	}
+
+	app := handlers.API("test", make(chan os.Signal, 1), log, nil, nil, cors, limits, tax.Config{}, subscription.Config{})
+
+	for _, path := range []string{"/category", "/category/", "/product", "/product/"} {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", "https://shop.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("%s: Should answer the preflight request with %d : got %d", path, http.StatusNoContent, rec.Code)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://shop.example.com" {
+			t.Fatalf("%s: Should allow the origin : got %q", path, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/data/bundle"
+	"github.com/igorbelousov/shop-backend/internal/data/product"
+	"github.com/igorbelousov/shop-backend/internal/data/promotion"
+	"github.com/igorbelousov/shop-backend/internal/data/review"
+	"github.com/igorbelousov/shop-backend/internal/data/shipping"
+	"github.com/igorbelousov/shop-backend/internal/data/subscription"
+	"github.com/igorbelousov/shop-backend/internal/data/user"
+	"github.com/igorbelousov/shop-backend/internal/data/viewed"
+	"github.com/igorbelousov/shop-backend/internal/mid"
+)
+
+// The business packages share the messages of their common errors, so the
+// translation of product.ErrNotFound covers every ErrNotFound.
+func init() {
+	web.RegisterMessages("ru", map[string]string{
+		product.ErrNotFound.Error():                            "не найдено",
+		product.ErrInvalidID.Error():                           "идентификатор имеет неверный формат",
+		product.ErrForbidden.Error():                           "действие запрещено",
+		product.ErrUnavailable.Error():                         "товара нет в наличии в таком количестве",
+		product.ErrInvalidSchedule.Error():                     "расписание должно относиться либо к товару, либо к категории, задавать цену или скидку и иметь корректный период",
+		product.ErrSalePrice.Error():                           "цена распродажи должна быть ниже обычной цены",
+		product.ErrInvalidAttribute.Error():                    "некорректная характеристика",
+		product.ErrMissingRelease.Error():                      "для предзаказа нужна дата выхода",
+		product.ErrInBundle.Error():                            "товар входит в комплект",
+		bundle.ErrUnknownProduct.Error():                       "товар из комплекта не существует",
+		bundle.ErrSlugTaken.Error():                            "такой адрес уже занят",
+		shipping.ErrUnknownZone.Error():                        "зона доставки не существует",
+		review.ErrAlreadyReviewed.Error():                      "вы уже оставили отзыв на этот товар",
+		promotion.ErrMissingCode.Error():                       "акция должна иметь код или применяться автоматически",
+		promotion.ErrInvalidCode.Error():                       "недействительный код купона",
+		promotion.ErrUsageLimit.Error():                        "купон больше нельзя использовать",
+		promotion.ErrCodeTaken.Error():                         "такой код купона уже используется",
+		promotion.ErrInvalidWindow.Error():                     "акция должна заканчиваться позже, чем начинается",
+		subscription.ErrInStock.Error():                        "товар есть в наличии",
+		user.ErrAuthenticationFailure.Error():                  "ошибка аутентификации",
+		viewed.ErrNoVisitor.Error():                            "требуется пользователь или сессия",
+		mid.ErrForbidden.Error():                               "у вас нет прав на это действие",
+		"too many requests":                                    "слишком много запросов",
+		"expected authorization header format: bearer <token>": "ожидается заголовок авторизации в формате: bearer <token>",
+		"must provide email and password in Basic auth":        "укажите email и пароль в Basic-аутентификации",
+		"cart line needs either id or bundle_id":               "в строке корзины нужно указать id или bundle_id",
+	})
+}
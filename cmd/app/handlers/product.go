@@ -2,16 +2,26 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/igorbelousov/shop-backend/foundation/web"
 	"github.com/igorbelousov/shop-backend/internal/auth"
 	"github.com/igorbelousov/shop-backend/internal/data/product"
+	"github.com/igorbelousov/shop-backend/internal/data/viewed"
 	"github.com/pkg/errors"
 )
 
+// sessionHeader carries the token identifying an anonymous shopper.
+const sessionHeader = "X-Session-Token"
+
 type productGroup struct {
 	product product.Product
+	viewed  viewed.Viewed
 }
 
 func (pg productGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -21,7 +31,12 @@ func (pg productGroup) query(ctx context.Context, w http.ResponseWriter, r *http
 		return web.NewShutdownError("web value missing from context")
 	}
 
-	products, err := pg.product.Query(ctx, v.TraceID)
+	filter, err := productFilter(r.URL.Query())
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	products, err := pg.product.Query(ctx, v.TraceID, filter, v.Now)
 	if err != nil {
 		return err
 	}
@@ -36,7 +51,7 @@ func (pg productGroup) queryByID(ctx context.Context, w http.ResponseWriter, r *
 	}
 
 	params := web.Params(r)
-	prod, err := pg.product.QueryByID(ctx, v.TraceID, params["id"])
+	prod, err := pg.product.QueryByID(ctx, v.TraceID, params["id"], v.Now)
 	if err != nil {
 		switch err {
 		case product.ErrInvalidID:
@@ -48,6 +63,17 @@ func (pg productGroup) queryByID(ctx context.Context, w http.ResponseWriter, r *
 		}
 	}
 
+	// Views are only recorded for shoppers we can recognise again.
+	var userID string
+	if claims, ok := ctx.Value(auth.Key).(auth.Claims); ok {
+		userID = claims.Subject
+	}
+	if session := r.Header.Get(sessionHeader); userID != "" || session != "" {
+		if err := pg.viewed.Record(ctx, v.TraceID, userID, session, prod.ID, v.Now); err != nil {
+			return errors.Wrapf(err, "recording view of %s", prod.ID)
+		}
+	}
+
 	return web.Respond(ctx, w, prod, http.StatusOK)
 }
 
@@ -58,7 +84,7 @@ func (pg productGroup) queryBySlug(ctx context.Context, w http.ResponseWriter, r
 	}
 
 	params := web.Params(r)
-	prod, err := pg.product.QueryBySlug(ctx, v.TraceID, params["slug"])
+	prod, err := pg.product.QueryBySlug(ctx, v.TraceID, params["slug"], v.Now)
 	if err != nil {
 		switch err {
 		case product.ErrInvalidID:
@@ -91,7 +117,14 @@ func (pg productGroup) create(ctx context.Context, w http.ResponseWriter, r *htt
 
 	prod, err := pg.product.Create(ctx, v.TraceID, claims, np, v.Now)
 	if err != nil {
-		return errors.Wrapf(err, "creating new product: %+v", np)
+		switch err {
+		case product.ErrMissingRelease:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "creating new product: %+v", np)
+		}
 	}
 
 	return web.Respond(ctx, w, prod, http.StatusCreated)
@@ -116,7 +149,7 @@ func (pg productGroup) update(ctx context.Context, w http.ResponseWriter, r *htt
 	params := web.Params(r)
 	if err := pg.product.Update(ctx, v.TraceID, claims, params["id"], upd, v.Now); err != nil {
 		switch err {
-		case product.ErrInvalidID:
+		case product.ErrInvalidID, product.ErrMissingRelease:
 			return web.NewRequestError(err, http.StatusBadRequest)
 		case product.ErrNotFound:
 			return web.NewRequestError(err, http.StatusNotFound)
@@ -143,6 +176,101 @@ func (pg productGroup) delete(ctx context.Context, w http.ResponseWriter, r *htt
 
 	params := web.Params(r)
 	if err := pg.product.Delete(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrInBundle:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (pg productGroup) queryPriceHistory(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	history, err := pg.product.QueryPriceHistory(ctx, v.TraceID, params["id"], v.Now)
+	if err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, history, http.StatusOK)
+}
+
+func (pg productGroup) querySchedules(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	schedules, err := pg.product.QuerySchedules(ctx, v.TraceID, v.Now)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, schedules, http.StatusOK)
+}
+
+func (pg productGroup) createSchedule(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var ns product.NewSchedule
+	if err := web.Decode(r, &ns); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	s, err := pg.product.CreateSchedule(ctx, v.TraceID, claims, ns, v.Now)
+	if err != nil {
+		switch err {
+		case product.ErrInvalidSchedule, product.ErrSalePrice, product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case product.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "creating new price schedule: %+v", ns)
+		}
+	}
+
+	return web.Respond(ctx, w, s, http.StatusCreated)
+}
+
+func (pg productGroup) deleteSchedule(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := pg.product.DeleteSchedule(ctx, v.TraceID, claims, params["id"]); err != nil {
 		switch err {
 		case product.ErrInvalidID:
 			return web.NewRequestError(err, http.StatusBadRequest)
@@ -153,3 +281,277 @@ func (pg productGroup) delete(ctx context.Context, w http.ResponseWriter, r *htt
 
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
+
+func (pg productGroup) queryAttributes(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	attrs, err := pg.product.QueryAttributes(ctx, v.TraceID, params["id"])
+	if err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, attrs, http.StatusOK)
+}
+
+func (pg productGroup) createAttribute(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var na product.NewAttribute
+	if err := web.Decode(r, &na); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	a, err := pg.product.CreateAttribute(ctx, v.TraceID, claims, na, v.Now)
+	if err != nil {
+		switch errors.Cause(err) {
+		case product.ErrInvalidAttribute:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "creating new attribute: %+v", na)
+		}
+	}
+
+	return web.Respond(ctx, w, a, http.StatusCreated)
+}
+
+func (pg productGroup) deleteAttribute(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := pg.product.DeleteAttribute(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// setAttributes replaces the attribute values of a product with the posted
+// list of attribute codes and values.
+func (pg productGroup) setAttributes(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	values := []product.NewAttributeValue{}
+	if err := web.Decode(r, &values); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := pg.product.SetAttributes(ctx, v.TraceID, claims, params["id"], values); err != nil {
+		switch errors.Cause(err) {
+		case product.ErrInvalidID, product.ErrInvalidAttribute:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case product.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// productFilter reads the listing filter from the query string. The
+// category parameter limits the listing to a category, every attr.<code>
+// parameter to products with one of the comma separated values, or for
+// numbers with a value in the min..max range where either bound may be left
+// out.
+func productFilter(qs url.Values) (product.Filter, error) {
+	f := product.Filter{
+		CategoryID: qs.Get("category"),
+	}
+
+	var keys []string
+	for key := range qs {
+		if strings.HasPrefix(key, "attr.") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		af := product.AttributeFilter{
+			Code: strings.TrimPrefix(key, "attr."),
+		}
+		value := qs.Get(key)
+
+		if i := strings.Index(value, ".."); i >= 0 {
+			bounds := []**float64{&af.Min, &af.Max}
+			for j, s := range []string{value[:i], value[i+2:]} {
+				if s == "" {
+					continue
+				}
+				n, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					return product.Filter{}, fmt.Errorf("invalid range for %s: %s", af.Code, value)
+				}
+				*bounds[j] = &n
+			}
+		} else {
+			af.Values = strings.Split(value, ",")
+		}
+
+		f.Attributes = append(f.Attributes, af)
+	}
+
+	return f, nil
+}
+
+func (pg productGroup) queryRelations(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	rel, err := pg.product.QueryRelations(ctx, v.TraceID, params["id"], v.Now)
+	if err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, rel, http.StatusOK)
+}
+
+func (pg productGroup) addRelation(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var nr product.NewRelation
+	if err := web.Decode(r, &nr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := pg.product.AddRelation(ctx, v.TraceID, claims, params["id"], nr); err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case product.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s  Relation: %+v", params["id"], &nr)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (pg productGroup) removeRelation(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := pg.product.RemoveRelation(ctx, v.TraceID, claims, params["id"], params["related"], params["type"]); err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// queryViewed returns the recently viewed products of the logged in user, or
+// of the anonymous session from the X-Session-Token header.
+func (pg productGroup) queryViewed(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var userID string
+	if claims, ok := ctx.Value(auth.Key).(auth.Claims); ok {
+		userID = claims.Subject
+	}
+
+	items, err := pg.viewed.Query(ctx, v.TraceID, userID, r.Header.Get(sessionHeader))
+	if err != nil {
+		switch err {
+		case viewed.ErrNoVisitor:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrap(err, "unable to query for viewed products")
+		}
+	}
+
+	prods := []product.Info{}
+	for _, item := range items {
+		prod, err := pg.product.QueryByID(ctx, v.TraceID, item.ProductID, v.Now)
+		if err != nil {
+			return errors.Wrapf(err, "ID: %s", item.ProductID)
+		}
+		prods = append(prods, prod)
+	}
+
+	return web.Respond(ctx, w, prods, http.StatusOK)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/promotion"
+	"github.com/pkg/errors"
+)
+
+type promotionGroup struct {
+	promotion promotion.Promotion
+}
+
+func (pg promotionGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	promotions, err := pg.promotion.Query(ctx, v.TraceID)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, promotions, http.StatusOK)
+}
+
+func (pg promotionGroup) queryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	prm, err := pg.promotion.QueryByID(ctx, v.TraceID, params["id"])
+	if err != nil {
+		switch err {
+		case promotion.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case promotion.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, prm, http.StatusOK)
+}
+
+func (pg promotionGroup) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var np promotion.NewPromotion
+	if err := web.Decode(r, &np); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	prm, err := pg.promotion.Create(ctx, v.TraceID, claims, np, v.Now)
+	if err != nil {
+		switch err {
+		case promotion.ErrMissingCode, promotion.ErrInvalidWindow:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case promotion.ErrCodeTaken:
+			return web.NewRequestError(err, http.StatusConflict)
+		case promotion.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "creating new promotion: %+v", np)
+		}
+	}
+
+	return web.Respond(ctx, w, prm, http.StatusCreated)
+}
+
+func (pg promotionGroup) update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var upd promotion.UpdatePromotion
+	if err := web.Decode(r, &upd); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := pg.promotion.Update(ctx, v.TraceID, claims, params["id"], upd, v.Now); err != nil {
+		switch err {
+		case promotion.ErrInvalidID, promotion.ErrInvalidWindow:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case promotion.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case promotion.ErrCodeTaken:
+			return web.NewRequestError(err, http.StatusConflict)
+		case promotion.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s  Promotion: %+v", params["id"], &upd)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (pg promotionGroup) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := pg.promotion.Delete(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case promotion.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case promotion.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
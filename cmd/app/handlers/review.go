@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/review"
+	"github.com/pkg/errors"
+)
+
+type reviewGroup struct {
+	review review.Review
+}
+
+func (rg reviewGroup) queryByProduct(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	pageNumber, err := strconv.Atoi(params["page"])
+	if err != nil || pageNumber < 1 {
+		return web.NewRequestError(fmt.Errorf("invalid page format: %s", params["page"]), http.StatusBadRequest)
+	}
+	rowsPerPage, err := strconv.Atoi(params["rows"])
+	if err != nil || rowsPerPage < 1 {
+		return web.NewRequestError(fmt.Errorf("invalid rows format: %s", params["rows"]), http.StatusBadRequest)
+	}
+
+	reviews, err := rg.review.QueryByProduct(ctx, v.TraceID, params["id"], r.URL.Query().Get("sort"), pageNumber, rowsPerPage)
+	if err != nil {
+		switch err {
+		case review.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, reviews, http.StatusOK)
+}
+
+func (rg reviewGroup) queryByStatus(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	pageNumber, err := strconv.Atoi(params["page"])
+	if err != nil || pageNumber < 1 {
+		return web.NewRequestError(fmt.Errorf("invalid page format: %s", params["page"]), http.StatusBadRequest)
+	}
+	rowsPerPage, err := strconv.Atoi(params["rows"])
+	if err != nil || rowsPerPage < 1 {
+		return web.NewRequestError(fmt.Errorf("invalid rows format: %s", params["rows"]), http.StatusBadRequest)
+	}
+
+	reviews, err := rg.review.QueryByStatus(ctx, v.TraceID, params["status"], pageNumber, rowsPerPage)
+	if err != nil {
+		return errors.Wrap(err, "unable to query for reviews")
+	}
+
+	return web.Respond(ctx, w, reviews, http.StatusOK)
+}
+
+func (rg reviewGroup) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var nr review.NewReview
+	if err := web.Decode(r, &nr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	rev, err := rg.review.Create(ctx, v.TraceID, claims, params["id"], nr, v.Now)
+	if err != nil {
+		switch err {
+		case review.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case review.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case review.ErrAlreadyReviewed:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "creating new review: %+v", nr)
+		}
+	}
+
+	return web.Respond(ctx, w, rev, http.StatusCreated)
+}
+
+func (rg reviewGroup) moderate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var m review.Moderation
+	if err := web.Decode(r, &m); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := rg.review.Moderate(ctx, v.TraceID, claims, params["id"], m, v.Now); err != nil {
+		switch err {
+		case review.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case review.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case review.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s  Moderation: %+v", params["id"], &m)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (rg reviewGroup) vote(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var vote review.Vote
+	if err := web.Decode(r, &vote); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := rg.review.Vote(ctx, v.TraceID, claims, params["id"], vote); err != nil {
+		switch err {
+		case review.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case review.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (rg reviewGroup) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := rg.review.Delete(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case review.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case review.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case review.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/shipping"
+	"github.com/pkg/errors"
+)
+
+type shippingGroup struct {
+	shipping shipping.Shipping
+}
+
+func (sg shippingGroup) queryZones(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	zones, err := sg.shipping.QueryZones(ctx, v.TraceID)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, zones, http.StatusOK)
+}
+
+func (sg shippingGroup) createZone(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var nz shipping.NewZone
+	if err := web.Decode(r, &nz); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	z, err := sg.shipping.CreateZone(ctx, v.TraceID, claims, nz, v.Now)
+	if err != nil {
+		switch err {
+		case shipping.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "creating new shipping zone: %+v", nz)
+		}
+	}
+
+	return web.Respond(ctx, w, z, http.StatusCreated)
+}
+
+func (sg shippingGroup) updateZone(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var upd shipping.UpdateZone
+	if err := web.Decode(r, &upd); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := sg.shipping.UpdateZone(ctx, v.TraceID, claims, params["id"], upd, v.Now); err != nil {
+		switch err {
+		case shipping.ErrInvalidID, shipping.ErrUnknownZone:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case shipping.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case shipping.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s  Zone: %+v", params["id"], &upd)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (sg shippingGroup) deleteZone(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := sg.shipping.DeleteZone(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case shipping.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (sg shippingGroup) createMethod(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var nm shipping.NewMethod
+	if err := web.Decode(r, &nm); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	m, err := sg.shipping.CreateMethod(ctx, v.TraceID, claims, nm, v.Now)
+	if err != nil {
+		switch err {
+		case shipping.ErrUnknownZone:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case shipping.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "creating new shipping method: %+v", nm)
+		}
+	}
+
+	return web.Respond(ctx, w, m, http.StatusCreated)
+}
+
+func (sg shippingGroup) updateMethod(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var upd shipping.UpdateMethod
+	if err := web.Decode(r, &upd); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := sg.shipping.UpdateMethod(ctx, v.TraceID, claims, params["id"], upd, v.Now); err != nil {
+		switch err {
+		case shipping.ErrInvalidID, shipping.ErrUnknownZone:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case shipping.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case shipping.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s  Method: %+v", params["id"], &upd)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (sg shippingGroup) deleteMethod(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := sg.shipping.DeleteMethod(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case shipping.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
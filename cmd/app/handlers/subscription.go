@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/data/product"
+	"github.com/igorbelousov/shop-backend/internal/data/subscription"
+	"github.com/pkg/errors"
+)
+
+type subscriptionGroup struct {
+	subscription subscription.Subscription
+}
+
+func (sg subscriptionGroup) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var ns subscription.NewSubscription
+	if err := web.Decode(r, &ns); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if _, err := sg.subscription.Create(ctx, v.TraceID, params["id"], ns, v.Now); err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case subscription.ErrInStock:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// unsubscribe is linked from the notification emails, so it is a GET.
+func (sg subscriptionGroup) unsubscribe(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	if err := sg.subscription.Unsubscribe(ctx, v.TraceID, params["token"]); err != nil {
+		switch err {
+		case subscription.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrap(err, "unsubscribing")
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/tax"
+	"github.com/pkg/errors"
+)
+
+type taxGroup struct {
+	tax tax.Tax
+}
+
+func (tg taxGroup) queryClasses(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	classes, err := tg.tax.QueryClasses(ctx, v.TraceID)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, classes, http.StatusOK)
+}
+
+func (tg taxGroup) createClass(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var nc tax.NewClass
+	if err := web.Decode(r, &nc); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	c, err := tg.tax.CreateClass(ctx, v.TraceID, claims, nc, v.Now)
+	if err != nil {
+		switch err {
+		case tax.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "creating new tax class: %+v", nc)
+		}
+	}
+
+	return web.Respond(ctx, w, c, http.StatusCreated)
+}
+
+func (tg taxGroup) deleteClass(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := tg.tax.DeleteClass(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case tax.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (tg taxGroup) createRate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var nr tax.NewRate
+	if err := web.Decode(r, &nr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	rate, err := tg.tax.CreateRate(ctx, v.TraceID, claims, nr, v.Now)
+	if err != nil {
+		switch err {
+		case tax.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "creating new tax rate: %+v", nr)
+		}
+	}
+
+	return web.Respond(ctx, w, rate, http.StatusCreated)
+}
+
+func (tg taxGroup) deleteRate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := tg.tax.DeleteRate(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case tax.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -3,18 +3,22 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 
 	"github.com/igorbelousov/shop-backend/foundation/web"
 	"github.com/igorbelousov/shop-backend/internal/auth"
 	"github.com/igorbelousov/shop-backend/internal/data/user"
+	"github.com/igorbelousov/shop-backend/internal/data/viewed"
+	"github.com/igorbelousov/shop-backend/internal/metrics"
 	"github.com/pkg/errors"
 )
 
 type userGroup struct {
-	user user.User
-	auth *auth.Auth
+	user   user.User
+	auth   *auth.Auth
+	viewed viewed.Viewed
 }
 
 func (ug userGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -172,6 +176,14 @@ func (ug userGroup) token(ctx context.Context, w http.ResponseWriter, r *http.Re
 
 	claims, err := ug.user.Authenticate(ctx, v.TraceID, v.Now, email, pass)
 	if err != nil {
+		if _, ok := err.(*user.LockedError); ok || err == user.ErrAuthenticationFailure {
+			metrics.LoginsFailed.Inc()
+		}
+		if lerr, ok := err.(*user.LockedError); ok {
+			retry := math.Ceil(lerr.Until.Sub(v.Now).Seconds())
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry)))
+			return web.NewRequestError(err, http.StatusTooManyRequests)
+		}
 		switch err {
 		case user.ErrAuthenticationFailure:
 			return web.NewRequestError(err, http.StatusUnauthorized)
@@ -180,6 +192,13 @@ func (ug userGroup) token(ctx context.Context, w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	// Hand the history of the anonymous session over to the user.
+	if session := r.Header.Get(sessionHeader); session != "" {
+		if err := ug.viewed.Merge(ctx, v.TraceID, session, claims.Subject); err != nil {
+			return errors.Wrap(err, "merging viewed products")
+		}
+	}
+
 	params := web.Params(r)
 
 	var tkn struct {
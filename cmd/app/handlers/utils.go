@@ -2,23 +2,29 @@ package handlers
 
 import (
 	"context"
-	"log"
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/foundation/web"
 )
 
 type utilsGroup struct {
+	log *logger.Logger
 }
 
 func (ug utilsGroup) Upload(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
 	filename := uuid.New().String() + ".png"
 
 	file, _, err := r.FormFile("file")
 	if err != nil {
-		log.Println("File no in request: ", err)
+		ug.log.Warn("file not in request", logger.TraceID(v.TraceID), logger.Err(err))
 	}
 
 	web.Upload("./media/", file, filename)
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/shipping"
+	"github.com/igorbelousov/shop-backend/internal/data/wishlist"
+	"github.com/pkg/errors"
+)
+
+type wishlistGroup struct {
+	wishlist wishlist.Wishlist
+	cart     cartGroup
+}
+
+func (wg wishlistGroup) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	lists, err := wg.wishlist.QueryByUser(ctx, v.TraceID, claims)
+	if err != nil {
+		return errors.Wrap(err, "unable to query for wishlists")
+	}
+
+	return web.Respond(ctx, w, lists, http.StatusOK)
+}
+
+func (wg wishlistGroup) queryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	params := web.Params(r)
+	list, err := wg.wishlist.QueryByID(ctx, v.TraceID, claims, params["id"])
+	if err != nil {
+		return wishlistError(err, params["id"])
+	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+// queryByToken returns a shared wishlist. It is public, the token is the
+// only thing protecting the list.
+func (wg wishlistGroup) queryByToken(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	params := web.Params(r)
+	list, err := wg.wishlist.QueryByToken(ctx, v.TraceID, params["token"])
+	if err != nil {
+		switch err {
+		case wishlist.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrap(err, "unable to query for shared wishlist")
+		}
+	}
+
+	// The owner and the token are not for the eyes of other shoppers.
+	list.UserID = ""
+	list.ShareToken = nil
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+func (wg wishlistGroup) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var nw wishlist.NewWishlist
+	if err := web.Decode(r, &nw); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	list, err := wg.wishlist.Create(ctx, v.TraceID, claims, nw, v.Now)
+	if err != nil {
+		return errors.Wrapf(err, "creating new wishlist: %+v", nw)
+	}
+
+	return web.Respond(ctx, w, list, http.StatusCreated)
+}
+
+func (wg wishlistGroup) update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var uw wishlist.UpdateWishlist
+	if err := web.Decode(r, &uw); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := wg.wishlist.Update(ctx, v.TraceID, claims, params["id"], uw, v.Now); err != nil {
+		return wishlistError(err, params["id"])
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (wg wishlistGroup) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := wg.wishlist.Delete(ctx, v.TraceID, claims, params["id"]); err != nil {
+		return wishlistError(err, params["id"])
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (wg wishlistGroup) addItem(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	var ni wishlist.NewItem
+	if err := web.Decode(r, &ni); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := wg.wishlist.AddItem(ctx, v.TraceID, claims, params["id"], ni, v.Now); err != nil {
+		return wishlistError(err, params["id"])
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (wg wishlistGroup) removeItem(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := wg.wishlist.RemoveItem(ctx, v.TraceID, claims, params["id"], params["product"]); err != nil {
+		return wishlistError(err, params["id"])
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (wg wishlistGroup) share(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	params := web.Params(r)
+	token, err := wg.wishlist.Share(ctx, v.TraceID, claims, params["id"])
+	if err != nil {
+		return wishlistError(err, params["id"])
+	}
+
+	resp := struct {
+		Token string `json:"token"`
+	}{
+		Token: token,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+func (wg wishlistGroup) unshare(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := wg.wishlist.Unshare(ctx, v.TraceID, claims, params["id"]); err != nil {
+		return wishlistError(err, params["id"])
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// moveToCart adds every product of the wishlist to the cart posted in the
+// body, empties the wishlist and returns the priced cart. Products already in
+// the cart keep their quantity, the others are added once.
+func (wg wishlistGroup) moveToCart(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return web.NewShutdownError("claims missing from context")
+	}
+
+	cr, err := decodeCart(r)
+	if err != nil {
+		return err
+	}
+
+	params := web.Params(r)
+	list, err := wg.wishlist.QueryByID(ctx, v.TraceID, claims, params["id"])
+	if err != nil {
+		return wishlistError(err, params["id"])
+	}
+
+	inCart := make(map[string]bool)
+	for _, line := range cr {
+		inCart[line.ID] = true
+	}
+	for _, item := range list.Items {
+		if !inCart[item.ProductID] {
+			cr = append(cr, CartRequest{ID: item.ProductID, Qty: 1})
+		}
+	}
+
+	qs := r.URL.Query()
+	addr := shipping.Address{
+		Country: qs.Get("country"),
+		Region:  qs.Get("region"),
+	}
+
+	c, err := wg.cart.price(ctx, v, cr, qs.Get("coupon"), addr)
+	if err != nil {
+		return err
+	}
+
+	if _, err := wg.wishlist.Clear(ctx, v.TraceID, claims, list.ID); err != nil {
+		return wishlistError(err, list.ID)
+	}
+
+	return web.Respond(ctx, w, c, http.StatusOK)
+}
+
+// wishlistError maps the errors of the wishlist package to responses.
+func wishlistError(err error, wishlistID string) error {
+	switch err {
+	case wishlist.ErrInvalidID:
+		return web.NewRequestError(err, http.StatusBadRequest)
+	case wishlist.ErrNotFound:
+		return web.NewRequestError(err, http.StatusNotFound)
+	case wishlist.ErrForbidden:
+		return web.NewRequestError(err, http.StatusForbidden)
+	default:
+		return errors.Wrapf(err, "ID: %s", wishlistID)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"expvar"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -17,7 +16,16 @@ import (
 	"github.com/dgrijalva/jwt-go"
 	"github.com/igorbelousov/shop-backend/cmd/app/handlers"
 	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/foundation/notify"
+	"github.com/igorbelousov/shop-backend/foundation/ratelimit"
 	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/subscription"
+	"github.com/igorbelousov/shop-backend/internal/data/tax"
+	"github.com/igorbelousov/shop-backend/internal/metrics"
+	"github.com/igorbelousov/shop-backend/internal/mid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/ardanlabs/conf"
 
@@ -27,16 +35,16 @@ import (
 var build = "develop"
 
 func main() {
-	log := log.New(os.Stdout, "SHOP: ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	log := logger.New(os.Stdout, logger.Info, logger.String("service", "SHOP"))
 
 	if err := run(log); err != nil {
-		log.Println("SHOP error: ", err)
+		log.Error("startup", logger.Err(err))
 		os.Exit(1)
 	}
 
 }
 
-func run(log *log.Logger) error {
+func run(log *logger.Logger) error {
 	var cfg struct {
 		conf.Version
 		Web struct {
@@ -45,6 +53,26 @@ func run(log *log.Logger) error {
 			ReadTimeout     time.Duration `conf:"default:5s"`
 			WriteTimeout    time.Duration `conf:"default:5s"`
 			ShutdownTimeout time.Duration `conf:"default:5s"`
+			CORS            struct {
+				AllowedOrigins   []string      `conf:"default:*"`
+				AllowedMethods   []string      `conf:"default:GET;POST;PUT;DELETE;OPTIONS"`
+				AllowedHeaders   []string      `conf:"default:Accept;Content-Type;Content-Length;Accept-Encoding;X-CSRF-Token;Authorization;X-Session-Token;If-None-Match;Accept-Language"`
+				ExposedHeaders   []string      `conf:"default:Retry-After;X-RateLimit-Limit;X-RateLimit-Remaining;X-RateLimit-Reset;ETag"`
+				AllowCredentials bool          `conf:"default:false"`
+				MaxAge           time.Duration `conf:"default:10m"`
+			}
+		}
+		RateLimit struct {
+			Requests       int           `conf:"default:300"`
+			Per            time.Duration `conf:"default:1m"`
+			LoginRequests  int           `conf:"default:10"`
+			LoginPer       time.Duration `conf:"default:1m"`
+			ReviewRequests int           `conf:"default:10"`
+			ReviewPer      time.Duration `conf:"default:1h"`
+			TrustedProxies []string
+		}
+		Log struct {
+			Level string `conf:"default:debug"`
 		}
 		Auth struct {
 			KeyID          string `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"`
@@ -57,6 +85,26 @@ func run(log *log.Logger) error {
 			Host       string `conf:"default:0.0.0.0"`
 			Name       string `conf:"default:postgres"`
 			DisableTLS bool   `conf:"default:true"`
+			LogArgs    bool   `conf:"default:true"`
+		}
+		Tax struct {
+			PricesIncludeTax bool   `conf:"default:true"`
+			DefaultCountry   string `conf:"default:RU"`
+		}
+		Tracing struct {
+			Exporter    string  `conf:"default:none"`
+			Endpoint    string  `conf:"default:localhost:4317"`
+			Insecure    bool    `conf:"default:true"`
+			Probability float64 `conf:"default:0.05"`
+		}
+		Notify struct {
+			Interval       time.Duration `conf:"default:1m"`
+			UnsubscribeURL string        `conf:"default:http://localhost:3000/subscription/unsubscribe/"`
+			SMTPHost       string
+			SMTPPort       int `conf:"default:587"`
+			SMTPUser       string
+			SMTPPassword   string `conf:"noprint"`
+			From           string `conf:"default:shop@example.com"`
 		}
 	}
 
@@ -85,19 +133,30 @@ func run(log *log.Logger) error {
 
 	// Print the build version for our logs. Also expose it under /debug/vars.
 	expvar.NewString("build").Set(build)
-	log.Printf("main : Started : Application initializing : version %q", build)
-	defer log.Println("main: Completed")
+	level, err := logger.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return errors.Wrap(err, "parsing log level")
+	}
+	log = log.WithLevel(level)
+
+	log.Info("startup", logger.String("status", "initializing application"), logger.String("version", build))
+	defer log.Info("shutdown", logger.String("status", "completed"))
 
 	out, err := conf.String(&cfg)
 	if err != nil {
 		return errors.Wrap(err, "generating config for output")
 	}
-	log.Printf("main: Config :\n%v\n", out)
+	log.Info("startup", logger.String("config", out))
 
 	// =========================================================================
 	// Start Database
 
-	log.Println("main: Initializing database support")
+	log.Info("startup", logger.String("status", "initializing database support"))
+
+	database.SetLogConfig(database.LogConfig{
+		Args:   cfg.DB.LogArgs,
+		Redact: database.DefaultRedact,
+	})
 
 	db, err := database.Open(database.Config{
 		User:       cfg.DB.User,
@@ -110,13 +169,13 @@ func run(log *log.Logger) error {
 		return errors.Wrap(err, "connecting to db")
 	}
 	defer func() {
-		log.Printf("main: Database Stopping : %s", cfg.DB.Host)
+		log.Info("shutdown", logger.String("status", "stopping database support"), logger.String("host", cfg.DB.Host))
 		db.Close()
 	}()
 
 	// Initialize authentication support
 
-	log.Println("main : Started : Initializing authentication support")
+	log.Info("startup", logger.String("status", "initializing authentication support"))
 
 	privatePEM, err := ioutil.ReadFile(cfg.Auth.PrivateKeyFile)
 	if err != nil {
@@ -143,39 +202,144 @@ func run(log *log.Logger) error {
 
 	// /debug/pprof - Added to the default mux by importing the net/http/pprof package.
 	// /debug/vars - Added to the default mux by importing the expvar package.
-	log.Println("main: Initializing debugging support")
+	// /metrics - The Prometheus metrics of the service.
+	log.Info("startup", logger.String("status", "initializing debugging support"))
+
+	reg := prometheus.NewRegistry()
+	if err := metrics.Register(reg, db); err != nil {
+		return errors.Wrap(err, "registering metrics")
+	}
+	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
 
 	go func() {
-		log.Printf("main: Debug Listening %s", cfg.Web.DebugHost)
+		log.Info("startup", logger.String("status", "debug listening"), logger.String("host", cfg.Web.DebugHost))
 		if err := http.ListenAndServe(cfg.Web.DebugHost, http.DefaultServeMux); err != nil {
-			log.Printf("main: Debug Listener closed : %v", err)
+			log.Error("shutdown", logger.String("status", "debug listener closed"), logger.Err(err))
+		}
+	}()
+
+	// =========================================================================
+	// Start Tracing Support
+
+	log.Info("startup", logger.String("status", "initializing tracing support"), logger.String("exporter", cfg.Tracing.Exporter))
+
+	stopTracing, err := startTracing(context.Background(), tracingConfig{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		Probability: cfg.Tracing.Probability,
+		Service:     "shop-backend",
+		Version:     build,
+	})
+	if err != nil {
+		return errors.Wrap(err, "starting tracing")
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
+		defer cancel()
+		if err := stopTracing(ctx); err != nil {
+			log.Error("shutdown", logger.String("status", "flushing traces"), logger.Err(err))
 		}
 	}()
 
 	// =========================================================================
 	// Start API Service
 
-	log.Println("main: Initializing API support")
+	log.Info("startup", logger.String("status", "initializing API support"))
 
 	// Make a channel to listen for an interrupt or terminate signal from the OS.
 	// Use a buffered channel because the signal package requires it.
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
+	taxCfg := tax.Config{
+		PricesIncludeTax: cfg.Tax.PricesIncludeTax,
+		DefaultCountry:   cfg.Tax.DefaultCountry,
+	}
+
+	subCfg := subscription.Config{
+		UnsubscribeURL: cfg.Notify.UnsubscribeURL,
+	}
+
+	corsCfg := mid.CORSConfig{
+		CORSPolicy: mid.CORSPolicy{
+			AllowedOrigins:   cfg.Web.CORS.AllowedOrigins,
+			AllowedMethods:   cfg.Web.CORS.AllowedMethods,
+			AllowedHeaders:   cfg.Web.CORS.AllowedHeaders,
+			ExposedHeaders:   cfg.Web.CORS.ExposedHeaders,
+			AllowCredentials: cfg.Web.CORS.AllowCredentials,
+			MaxAge:           cfg.Web.CORS.MaxAge,
+		},
+	}
+
+	proxies, err := mid.ParseProxies(cfg.RateLimit.TrustedProxies)
+	if err != nil {
+		return errors.Wrap(err, "parsing trusted proxies")
+	}
+
+	limits := handlers.Limits{
+		Store:   ratelimit.NewMemory(),
+		Proxies: proxies,
+		API: ratelimit.Limit{
+			Requests: cfg.RateLimit.Requests,
+			Per:      cfg.RateLimit.Per,
+		},
+		Login: ratelimit.Limit{
+			Requests: cfg.RateLimit.LoginRequests,
+			Per:      cfg.RateLimit.LoginPer,
+		},
+		Review: ratelimit.Limit{
+			Requests: cfg.RateLimit.ReviewRequests,
+			Per:      cfg.RateLimit.ReviewPer,
+		},
+	}
+
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
-		Handler:      handlers.API(build, shutdown, log, auth, db),
+		Handler:      handlers.API(build, shutdown, log, auth, db, corsCfg, limits, taxCfg, subCfg),
 		ReadTimeout:  cfg.Web.ReadTimeout,
 		WriteTimeout: cfg.Web.WriteTimeout,
 	}
 
+	// =========================================================================
+	// Start Notification Dispatcher
+
+	// Without a mail server the notifications are only logged.
+	var notifier notify.Notifier = notify.NewLog(log)
+	if cfg.Notify.SMTPHost != "" {
+		notifier = notify.NewSMTP(notify.SMTPConfig{
+			Host:     cfg.Notify.SMTPHost,
+			Port:     cfg.Notify.SMTPPort,
+			User:     cfg.Notify.SMTPUser,
+			Password: cfg.Notify.SMTPPassword,
+			From:     cfg.Notify.From,
+		})
+	}
+
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	dispatchDone := make(chan struct{})
+
+	log.Info("startup", logger.String("status", "notification dispatcher running"), logger.String("interval", cfg.Notify.Interval.String()))
+	go func() {
+		defer close(dispatchDone)
+		subscription.New(log, db, subCfg).Run(dispatchCtx, notifier, cfg.Notify.Interval)
+	}()
+
+	// Wait for the dispatcher to stop before the database is closed, so a
+	// sent notification always has its subscription removed.
+	defer func() {
+		log.Info("shutdown", logger.String("status", "stopping notification dispatcher"))
+		stopDispatch()
+		<-dispatchDone
+	}()
+
 	// Make a channel to listen for errors coming from the listener. Use a
 	// buffered channel so the goroutine can exit if we don't collect this error.
 	serverErrors := make(chan error, 1)
 
 	// Start the service listening for requests.
 	go func() {
-		log.Printf("main: API listening on %s", api.Addr)
+		log.Info("startup", logger.String("status", "API listening"), logger.String("host", api.Addr))
 		serverErrors <- api.ListenAndServe()
 	}()
 
@@ -185,7 +349,7 @@ func run(log *log.Logger) error {
 		return errors.Wrap(err, "server error")
 
 	case sig := <-shutdown:
-		log.Printf("main: %v : Start shutdown", sig)
+		log.Info("shutdown", logger.String("status", "shutdown started"), logger.String("signal", sig.String()))
 
 		// Give outstanding requests a deadline for completion.
 		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
+	"go.opentelemetry.io/otel/exporters/trace/zipkin"
+	"go.opentelemetry.io/otel/propagation"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// tracingConfig selects where spans are exported to and how many traces
+// are sampled.
+type tracingConfig struct {
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	Probability float64
+	Service     string
+	Version     string
+}
+
+// startTracing registers the global tracer provider exporting spans to the
+// configured exporter. The returned function flushes the pending spans and
+// stops the exporter. With the exporter "none" spans are not recorded.
+func startTracing(ctx context.Context, cfg tracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exp export.SpanExporter
+	switch cfg.Exporter {
+	case "none", "":
+		return func(context.Context) error { return nil }, nil
+
+	case "otlp":
+		opts := []otlpgrpc.Option{otlpgrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlpgrpc.WithInsecure())
+		}
+		e, err := otlp.NewExporter(ctx, otlpgrpc.NewDriver(opts...))
+		if err != nil {
+			return nil, errors.Wrap(err, "starting otlp exporter")
+		}
+		exp = e
+
+	case "otlphttp":
+		opts := []otlphttp.Option{otlphttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlphttp.WithInsecure())
+		}
+		e, err := otlp.NewExporter(ctx, otlphttp.NewDriver(opts...))
+		if err != nil {
+			return nil, errors.Wrap(err, "starting otlp http exporter")
+		}
+		exp = e
+
+	case "zipkin":
+		e, err := zipkin.NewRawExporter(cfg.Endpoint, cfg.Service)
+		if err != nil {
+			return nil, errors.Wrap(err, "starting zipkin exporter")
+		}
+		exp = e
+
+	default:
+		return nil, errors.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+
+	res, err := resource.New(ctx, resource.WithAttributes(
+		semconv.ServiceNameKey.String(cfg.Service),
+		semconv.ServiceVersionKey.String(cfg.Version),
+	))
+	if err != nil {
+		return nil, errors.Wrap(err, "describing service")
+	}
+
+	// Follow the sampling decision of the caller, sample a share of the
+	// traces starting here.
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{
+			DefaultSampler: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Probability)),
+		}),
+		sdktrace.WithResource(res),
+		sdktrace.WithBatcher(exp),
+	)
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
@@ -3,12 +3,11 @@ package database
 
 import (
 	"context"
-	"fmt"
+	"database/sql"
 	"net/url"
-	"strings"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq" // The database driver in use.
+	"github.com/lib/pq"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -40,7 +39,14 @@ func Open(cfg Config) (*sqlx.DB, error) {
 		RawQuery: q.Encode(),
 	}
 
-	return sqlx.Open("postgres", u.String())
+	pqc, err := pq.NewConnector(u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// Open through a connector tracing the queries, keep the driver name
+	// so sqlx binds the query parameters the postgres way.
+	return sqlx.NewDb(sql.OpenDB(connector{pqc}), "postgres"), nil
 }
 
 // StatusCheck returns nil if it can successfully talk to the database. It
@@ -57,28 +63,3 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 	var tmp bool
 	return db.QueryRowContext(ctx, q).Scan(&tmp)
 }
-
-// Log provides a pretty print version of the query and parameters.
-func Log(query string, args ...interface{}) string {
-	for i, arg := range args {
-		n := fmt.Sprintf("$%d", i+1)
-
-		var a string
-		switch v := arg.(type) {
-		case string:
-			a = fmt.Sprintf("%q", v)
-		case []byte:
-			a = string(v)
-		case []string:
-			a = strings.Join(v, ",")
-		default:
-			a = fmt.Sprintf("%v", v)
-		}
-
-		query = strings.Replace(query, n, a, 1)
-		query = strings.Replace(query, "\t", "", -1)
-		query = strings.Replace(query, "\n", " ", -1)
-	}
-
-	return query
-}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+)
+
+// redacted replaces the arguments that must not show up in the logs.
+const redacted = "[REDACTED]"
+
+// LogConfig controls how queries are logged.
+type LogConfig struct {
+
+	// Args enables logging of the query arguments. Production systems may
+	// log the statements only.
+	Args bool
+
+	// Redact lists the columns whose arguments are always redacted.
+	Redact []string
+}
+
+// DefaultRedact are the columns redacted unless configured otherwise.
+var DefaultRedact = []string{"password", "password_hash", "token", "share_token"}
+
+var (
+	logMu  sync.RWMutex
+	logCfg = LogConfig{
+		Args:   true,
+		Redact: DefaultRedact,
+	}
+)
+
+// SetLogConfig changes how Log treats the query arguments. It is meant to be
+// called once at startup.
+func SetLogConfig(cfg LogConfig) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logCfg = cfg
+}
+
+// secret is an argument marked as sensitive.
+type secret struct {
+	value interface{}
+}
+
+// Secret marks an argument passed to Log as sensitive, so it is redacted
+// whatever column it belongs to.
+func Secret(arg interface{}) interface{} {
+	return secret{arg}
+}
+
+// Query is the structured form of a query for the logs.
+type Query struct {
+	SQL  string        `json:"sql"`
+	Args []interface{} `json:"args,omitempty"`
+}
+
+// Log provides the query and its parameters as a log field. Arguments marked
+// as Secret, binary arguments like password hashes and the arguments of the
+// redacted columns are replaced. The field is lazy, the query is only
+// formatted and redacted when the entry is written.
+func Log(query string, args ...interface{}) logger.Field {
+	return logger.Lazy("query", func() interface{} {
+		return redact(query, args)
+	})
+}
+
+// redact returns the query on a single line with its arguments as Log
+// describes.
+func redact(query string, args []interface{}) Query {
+	logMu.RLock()
+	cfg := logCfg
+	logMu.RUnlock()
+
+	q := Query{
+		SQL: strings.Join(strings.Fields(query), " "),
+	}
+	if !cfg.Args {
+		return q
+	}
+
+	columns := placeholders(q.SQL)
+	sensitive := func(n int) bool {
+		for _, c := range cfg.Redact {
+			if strings.EqualFold(columns[n], c) {
+				return true
+			}
+		}
+		return false
+	}
+
+	q.Args = make([]interface{}, len(args))
+	for i, arg := range args {
+		switch arg.(type) {
+		case secret, []byte:
+			q.Args[i] = redacted
+		default:
+			if sensitive(i + 1) {
+				q.Args[i] = redacted
+				continue
+			}
+			q.Args[i] = arg
+		}
+	}
+
+	return q
+}
+
+var (
+	// assignRE matches comparisons and assignments like "email" = $3.
+	assignRE = regexp.MustCompile(`"?(\w+)"?\s*(?:=|<>|!=|<=|>=|<|>)\s*\$(\d+)`)
+
+	// insertRE matches the column and value lists of an INSERT.
+	insertRE = regexp.MustCompile(`(?i)INSERT INTO\s+\S+\s*\(([^)]*)\)\s*VALUES\s*\(([^)]*)\)`)
+)
+
+// placeholders maps the numbers of the placeholders in the query to the
+// columns they are used with, as far as the query reveals them.
+func placeholders(query string) map[int]string {
+	columns := make(map[int]string)
+
+	for _, m := range assignRE.FindAllStringSubmatch(query, -1) {
+		if n, err := strconv.Atoi(m[2]); err == nil {
+			columns[n] = m[1]
+		}
+	}
+
+	if m := insertRE.FindStringSubmatch(query); m != nil {
+		names := strings.Split(m[1], ",")
+		values := strings.Split(m[2], ",")
+		for i := 0; i < len(names) && i < len(values); i++ {
+			v := strings.TrimSpace(values[i])
+			if !strings.HasPrefix(v, "$") {
+				continue
+			}
+			if n, err := strconv.Atoi(v[1:]); err == nil {
+				columns[n] = strings.Trim(strings.TrimSpace(names[i]), `"`)
+			}
+		}
+	}
+
+	return columns
+}
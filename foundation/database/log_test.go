@@ -0,0 +1,71 @@
+package database_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+)
+
+func TestLog(t *testing.T) {
+	t.Cleanup(func() {
+		database.SetLogConfig(database.LogConfig{Args: true, Redact: database.DefaultRedact})
+	})
+
+	const insert = `
+	INSERT INTO users
+		(user_id, name, password_hash, roles)
+	VALUES
+		($1, $2, $3, $4)`
+
+	const update = `
+	UPDATE
+		wishlists
+	SET
+		"share_token" = $2
+	WHERE
+		wishlist_id = $1`
+
+	tt := []struct {
+		name  string
+		query string
+		args  []interface{}
+		exp   []interface{}
+	}{
+		{"insert", insert, []interface{}{"1", "Gopher", "hash", "USER"}, []interface{}{"1", "Gopher", "[REDACTED]", "USER"}},
+		{"update", update, []interface{}{"1", "token"}, []interface{}{"1", "[REDACTED]"}},
+		{"secret", `SELECT * FROM users WHERE email = $1`, []interface{}{database.Secret("a@b.c")}, []interface{}{"[REDACTED]"}},
+		{"binary", `SELECT $1`, []interface{}{[]byte("hash")}, []interface{}{"[REDACTED]"}},
+	}
+
+	for _, tc := range tt {
+		q := logged(t, database.Log(tc.query, tc.args...))
+		if diff := cmp.Diff(tc.exp, q.Args); diff != "" {
+			t.Fatalf("%s: Should redact sensitive arguments. Diff:\n%s", tc.name, diff)
+		}
+	}
+
+	database.SetLogConfig(database.LogConfig{Args: false})
+	q := logged(t, database.Log(insert, "1", "Gopher", "hash", "USER"))
+	if q.Args != nil {
+		t.Fatalf("Should not log arguments when disabled : %v", q.Args)
+	}
+	if q.SQL != "INSERT INTO users (user_id, name, password_hash, roles) VALUES ($1, $2, $3, $4)" {
+		t.Fatalf("Should log the statement on a single line : %q", q.SQL)
+	}
+}
+
+// logged returns the query of the field as it is written to the log.
+func logged(t *testing.T, f logger.Field) database.Query {
+	data, err := json.Marshal(f.Value)
+	if err != nil {
+		t.Fatalf("Should be able to marshal the query : %s", err)
+	}
+	var q database.Query
+	if err := json.Unmarshal(data, &q); err != nil {
+		t.Fatalf("Should be able to unmarshal the query : %s", err)
+	}
+	return q
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer starts the spans of the queries. It resolves the globally
+// registered provider on every call, so it can be created before the
+// provider is configured.
+var tracer = otel.Tracer("foundation.database")
+
+// connector wraps a connector so every connection it opens traces its
+// queries.
+type connector struct {
+	driver.Connector
+}
+
+// Connect opens a traced connection.
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tracedConn{conn}, nil
+}
+
+// tracedConn starts a span around every query run through the connection,
+// including the queries of transactions.
+type tracedConn struct {
+	driver.Conn
+}
+
+// ExecContext runs a statement in a span.
+func (c tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startSpan(ctx, "foundation.database.exec", query)
+	defer span.End()
+
+	res, err := ec.ExecContext(ctx, query, args)
+	endSpan(span, err)
+	return res, err
+}
+
+// QueryContext runs a query in a span.
+func (c tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startSpan(ctx, "foundation.database.query", query)
+	defer span.End()
+
+	rows, err := qc.QueryContext(ctx, query, args)
+	endSpan(span, err)
+	return rows, err
+}
+
+// BeginTx starts a transaction on the wrapped connection.
+func (c tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// Ping checks the wrapped connection.
+func (c tracedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// startSpan starts the span of a query.
+func startSpan(ctx context.Context, name string, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgres,
+			semconv.DBStatementKey.String(strings.Join(strings.Fields(query), " ")),
+		),
+	)
+}
+
+// endSpan records the error of the query, if there is one.
+func endSpan(span trace.Span, err error) {
+	if err == nil || err == driver.ErrSkip {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
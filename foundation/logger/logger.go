@@ -0,0 +1,200 @@
+// Package logger provides a leveled logger writing one JSON object per entry.
+package logger
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// Level is the severity of a log entry.
+type Level int
+
+// Set of log levels.
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch l {
+	case Debug:
+		return "debug"
+	case Info:
+		return "info"
+	case Warn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// ParseLevel returns the level for its name.
+func ParseLevel(name string) (Level, error) {
+	for l := Debug; l <= Error; l++ {
+		if strings.EqualFold(name, l.String()) {
+			return l, nil
+		}
+	}
+	return 0, errors.Errorf("unknown log level %q", name)
+}
+
+// Field is a key value pair added to a log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Any constructs a field holding any value that can be marshaled to JSON.
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// String constructs a string field.
+func String(key string, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int constructs an integer field.
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Lazy constructs a field whose value is computed only when the entry is
+// written, for values costly to build like the redacted arguments of a
+// query. Entries below the level of the logger never call it.
+func Lazy(key string, value func() interface{}) Field {
+	return Field{Key: key, Value: lazy(value)}
+}
+
+// lazy is a field value computed when it is marshaled.
+type lazy func() interface{}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (f lazy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f())
+}
+
+// Duration constructs a field holding the duration in milliseconds.
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Value: float64(value) / float64(time.Millisecond)}
+}
+
+// Err constructs the error field of an entry.
+func Err(err error) Field {
+	return Field{Key: "error", Value: err.Error()}
+}
+
+// TraceID constructs the field identifying the request an entry belongs to.
+func TraceID(traceID string) Field {
+	return Field{Key: "trace_id", Value: traceID}
+}
+
+// Logger writes entries of its level and above to the output. A Logger is
+// safe for concurrent use, loggers derived with With share the output.
+type Logger struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	level  Level
+	fields []Field
+}
+
+// New constructs a Logger writing to out. The fields are added to every
+// entry, the service name is a good candidate.
+func New(out io.Writer, level Level, fields ...Field) *Logger {
+	return &Logger{
+		mu:     &sync.Mutex{},
+		out:    out,
+		level:  level,
+		fields: fields,
+	}
+}
+
+// With returns a Logger adding the fields to every entry.
+func (l *Logger) With(fields ...Field) *Logger {
+	wl := *l
+	wl.fields = append(append([]Field{}, l.fields...), fields...)
+	return &wl
+}
+
+// WithLevel returns a Logger writing entries of the level and above.
+func (l *Logger) WithLevel(level Level) *Logger {
+	wl := *l
+	wl.level = level
+	return &wl
+}
+
+// Enabled reports whether entries of the level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug writes a debug entry.
+func (l *Logger) Debug(msg string, fields ...Field) {
+	l.write(Debug, msg, fields)
+}
+
+// Info writes an info entry.
+func (l *Logger) Info(msg string, fields ...Field) {
+	l.write(Info, msg, fields)
+}
+
+// Warn writes a warning entry.
+func (l *Logger) Warn(msg string, fields ...Field) {
+	l.write(Warn, msg, fields)
+}
+
+// Error writes an error entry.
+func (l *Logger) Error(msg string, fields ...Field) {
+	l.write(Error, msg, fields)
+}
+
+// write encodes the entry as a JSON object on a single line. The keys are
+// written in order: time, level, message, the logger's fields and the
+// fields of the entry.
+func (l *Logger) write(level Level, msg string, fields []Field) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	encode(&b, time.Now().UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	encode(&b, level.String())
+	b.WriteString(`,"msg":`)
+	encode(&b, msg)
+	for _, fs := range [][]Field{l.fields, fields} {
+		for _, f := range fs {
+			b.WriteByte(',')
+			encode(&b, f.Key)
+			b.WriteByte(':')
+			encode(&b, f.Value)
+		}
+	}
+	b.WriteString("}\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(b.Bytes())
+}
+
+// encode writes the JSON form of the value. Values that cannot be marshaled
+// are written as their error so the entry stays valid JSON.
+func encode(b *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal("!" + err.Error())
+	}
+	b.Write(data)
+}
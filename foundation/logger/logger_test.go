@@ -0,0 +1,92 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+)
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	log := logger.New(&out, logger.Info, logger.String("service", "TEST"))
+
+	log.Debug("hidden")
+	log.With(logger.TraceID("42")).Warn("shown", logger.Int("status", 404), logger.Err(errors.New("not found")))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Should only write entries of the level and above : %q", out.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Should write an entry as JSON : %s", err)
+	}
+
+	exp := map[string]interface{}{
+		"level":    "warn",
+		"msg":      "shown",
+		"service":  "TEST",
+		"trace_id": "42",
+		"status":   float64(404),
+		"error":    "not found",
+	}
+	for k, v := range exp {
+		if entry[k] != v {
+			t.Fatalf("Should write %s as %v : got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Fatal("Should write the time of the entry")
+	}
+}
+
+func TestLazy(t *testing.T) {
+	var out bytes.Buffer
+	log := logger.New(&out, logger.Info)
+
+	type query struct {
+		SQL string `json:"sql"`
+	}
+
+	var calls int
+	field := logger.Lazy("query", func() interface{} {
+		calls++
+		return query{SQL: "SELECT 1"}
+	})
+
+	log.Debug("hidden", field)
+	if calls != 0 {
+		t.Fatalf("Should NOT compute the value of a hidden entry : %d calls", calls)
+	}
+
+	log.Info("shown", field)
+	if calls != 1 {
+		t.Fatalf("Should compute the value once for a written entry : %d calls", calls)
+	}
+
+	var entry struct {
+		Query query `json:"query"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Should write an entry as JSON : %s", err)
+	}
+	if entry.Query.SQL != "SELECT 1" {
+		t.Fatalf("Should write the computed value : %s", out.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := logger.ParseLevel("WARN")
+	if err != nil || level != logger.Warn {
+		t.Fatalf("Should parse level names in any case : %v %v", level, err)
+	}
+
+	if _, err := logger.ParseLevel("verbose"); err == nil {
+		t.Fatal("Should reject unknown levels")
+	}
+}
@@ -0,0 +1,107 @@
+// Package notify provides support for sending messages to customers.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+)
+
+// Message is a plain text message to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to customers.
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// Log is a Notifier that writes messages to a logger. It is meant for
+// development, where no mail server is available.
+type Log struct {
+	log *logger.Logger
+}
+
+// NewLog constructs a Log notifier.
+func NewLog(log *logger.Logger) Log {
+	return Log{log: log}
+}
+
+// Notify writes the message to the logger.
+func (l Log) Notify(ctx context.Context, m Message) error {
+	l.log.Info("notify",
+		logger.String("to", m.To),
+		logger.String("subject", m.Subject),
+		logger.String("body", m.Body),
+	)
+	return nil
+}
+
+// Permanent reports whether the error means the message can never be
+// delivered, like a mail server rejecting the recipient. Sending such a
+// message again fails the same way.
+func Permanent(err error) bool {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code >= 500
+	}
+	return false
+}
+
+// SMTPConfig is the required properties to send mail through a server.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	From     string
+}
+
+// SMTP is a Notifier sending messages as email.
+type SMTP struct {
+	cfg SMTPConfig
+}
+
+// NewSMTP constructs a SMTP notifier.
+func NewSMTP(cfg SMTPConfig) SMTP {
+	return SMTP{cfg: cfg}
+}
+
+// Notify sends the message as a plain text email.
+func (s SMTP) Notify(ctx context.Context, m Message) error {
+	var a smtp.Auth
+	if s.cfg.User != "" {
+		a = smtp.PlainAuth("", s.cfg.User, s.cfg.Password, s.cfg.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
+	return smtp.SendMail(addr, a, s.cfg.From, []string{headerValue(m.To)}, s.message(m))
+}
+
+// message returns the email of the message. Header values lose their line
+// breaks, so a recipient or subject taken from user input cannot add
+// headers, and the subject is encoded to carry any characters.
+func (s SMTP) message(m Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(s.cfg.From))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(m.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(m.Subject)))
+	fmt.Fprintf(&b, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(m.Body)
+	return []byte(b.String())
+}
+
+// headerValue removes the line breaks from a header value.
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestSMTPMessage(t *testing.T) {
+	s := NewSMTP(SMTPConfig{From: "shop@example.com"})
+
+	tt := []struct {
+		name    string
+		m       Message
+		to      string
+		subject string
+	}{
+		{"ascii", Message{To: "ann@example.com", Subject: "Back in stock"}, "ann@example.com", "Back in stock"},
+		{"utf-8", Message{To: "ann@example.com", Subject: "Товар снова в наличии"}, "ann@example.com", "=?utf-8?q?"},
+		{"injection", Message{To: "ann@example.com\r\nBcc: eve@example.com", Subject: "Hi\r\nBcc: eve@example.com"}, "ann@example.comBcc: eve@example.com", "HiBcc: eve@example.com"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := string(s.message(tc.m))
+			head := msg[:strings.Index(msg, "\r\n\r\n")]
+
+			var to, subject string
+			for _, line := range strings.Split(head, "\r\n") {
+				switch {
+				case strings.HasPrefix(line, "To: "):
+					to = strings.TrimPrefix(line, "To: ")
+				case strings.HasPrefix(line, "Subject: "):
+					subject = strings.TrimPrefix(line, "Subject: ")
+				case strings.HasPrefix(line, "Bcc:"):
+					t.Fatalf("Should not let the message add headers : %q", head)
+				}
+			}
+			if to != tc.to {
+				t.Fatalf("Should send to %q : %q", tc.to, to)
+			}
+			if !strings.HasPrefix(subject, tc.subject) {
+				t.Fatalf("Should have subject starting with %q : %q", tc.subject, subject)
+			}
+			if strings.ContainsAny(subject, "\r\n") || strings.IndexFunc(subject, func(r rune) bool { return r > 127 }) != -1 {
+				t.Fatalf("Should encode the subject as ASCII : %q", subject)
+			}
+		})
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	tt := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rejected", &textproto.Error{Code: 550, Msg: "no such user"}, true},
+		{"wrapped", fmt.Errorf("sending: %w", &textproto.Error{Code: 553, Msg: "bad address"}), true},
+		{"busy", &textproto.Error{Code: 451, Msg: "try again later"}, false},
+		{"network", errors.New("connection refused"), false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Permanent(tc.err); got != tc.want {
+				t.Fatalf("Should report %v as permanent %v, got %v.", tc.err, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Package ratelimit provides token bucket rate limiting.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per period Per. Clients may spend the whole budget
+// at once, after that tokens come back evenly over the period.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// rate is the number of tokens refilled per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Result is the state of a bucket after taking a token from it.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Time
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets of all keys. Take must be atomic per key when the
+// store is shared between instances, a Redis store can keep each bucket in
+// a hash of tokens and update time and take tokens in a Lua script using
+// the same arithmetic as Bucket.
+type Store interface {
+	Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error)
+}
+
+// Bucket is the state of a single token bucket.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Take refills the bucket for the time passed since it was last updated and
+// takes a token from it if one is left.
+func (b *Bucket) Take(l Limit, now time.Time) Result {
+	if b.Updated.IsZero() {
+		b.Tokens = float64(l.Requests)
+		b.Updated = now
+	}
+
+	if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(l.Requests), b.Tokens+elapsed*l.rate())
+		b.Updated = now
+	}
+
+	res := Result{
+		Limit: l.Requests,
+	}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.Tokens) / l.rate())
+	}
+
+	res.Remaining = int(b.Tokens)
+	res.Reset = now.Add(seconds((float64(l.Requests) - b.Tokens) / l.rate()))
+
+	return res
+}
+
+// full reports whether the bucket is refilled completely by now.
+func (b *Bucket) full(l Limit, now time.Time) bool {
+	return b.Tokens+now.Sub(b.Updated).Seconds()*l.rate() >= float64(l.Requests)
+}
+
+// seconds converts fractional seconds to a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// sweepEvery is how often the memory store drops buckets that are full.
+const sweepEvery = time.Minute
+
+// Memory is a Store that keeps the buckets in the memory of one instance.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*entry
+	swept   time.Time
+}
+
+// entry is a bucket with the limit it was last used with.
+type entry struct {
+	Bucket
+	limit Limit
+}
+
+// NewMemory constructs an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]*entry),
+	}
+}
+
+// Take takes a token from the bucket of the key.
+func (m *Memory) Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Full buckets hold no state worth keeping, drop them from time to
+	// time so the map does not grow with every client ever seen.
+	if now.Sub(m.swept) >= sweepEvery {
+		for k, e := range m.buckets {
+			if e.full(e.limit, now) {
+				delete(m.buckets, k)
+			}
+		}
+		m.swept = now
+	}
+
+	e, ok := m.buckets[key]
+	if !ok {
+		e = &entry{}
+		m.buckets[key] = e
+	}
+	e.limit = l
+
+	return e.Take(l, now), nil
+}
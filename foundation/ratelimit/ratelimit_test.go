@@ -0,0 +1,56 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/igorbelousov/shop-backend/foundation/ratelimit"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	store := ratelimit.NewMemory()
+	limit := ratelimit.Limit{Requests: 3, Per: 3 * time.Second}
+	now := time.Date(2020, time.February, 4, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		res, err := store.Take(ctx, "client", limit, now)
+		if err != nil {
+			t.Fatalf("Should be able to take a token : %s", err)
+		}
+		if !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("Should allow request %d with %d left : %+v", i, 2-i, res)
+		}
+	}
+
+	res, err := store.Take(ctx, "client", limit, now)
+	if err != nil {
+		t.Fatalf("Should be able to take a token : %s", err)
+	}
+	if res.Allowed {
+		t.Fatal("Should deny requests over the limit")
+	}
+	if res.RetryAfter != time.Second {
+		t.Fatalf("Should retry once a token is back : got %v", res.RetryAfter)
+	}
+	if !res.Reset.Equal(now.Add(3 * time.Second)) {
+		t.Fatalf("Should reset once the bucket is full : got %v", res.Reset)
+	}
+
+	res, err = store.Take(ctx, "other", limit, now)
+	if err != nil {
+		t.Fatalf("Should be able to take a token : %s", err)
+	}
+	if !res.Allowed {
+		t.Fatal("Should keep a bucket per key")
+	}
+
+	res, err = store.Take(ctx, "client", limit, now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Should be able to take a token : %s", err)
+	}
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("Should refill a token per second : %+v", res)
+	}
+}
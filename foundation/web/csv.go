@@ -0,0 +1,173 @@
+package web
+
+import (
+	"encoding/csv"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// encodeCSV writes a slice of structs as a table with a row per item, or a
+// struct as a table with a single row. The json tags of the fields are the
+// column headers, nested values are written as JSON.
+func encodeCSV(w io.Writer, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	var rows []reflect.Value
+	var t reflect.Type
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		t = rv.Type().Elem()
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, rv.Index(i))
+		}
+	case reflect.Struct:
+		t = rv.Type()
+		rows = append(rows, rv)
+	default:
+		return errors.Errorf("csv: cannot encode %T", v)
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return errors.Errorf("csv: cannot encode %T", v)
+	}
+
+	fields := fieldsOf(t)
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if row.Kind() == reflect.Ptr {
+			if row.IsNil() {
+				continue
+			}
+			row = row.Elem()
+		}
+		record := make([]string, len(fields))
+		for i, f := range fields {
+			cell, err := csvCell(fieldValue(row, f))
+			if err != nil {
+				return err
+			}
+			record[i] = cell
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell returns the text of a field. Strings, numbers and booleans are
+// written as is, anything else as its JSON encoding without the quotes of
+// a JSON string. Text is escaped so spreadsheets do not run it.
+func csvCell(v reflect.Value) (string, error) {
+	if !v.IsValid() {
+		return "", nil
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return csvText(v.String()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return "", err
+		}
+		return csvText(s), nil
+	}
+	return string(data), nil
+}
+
+// csvFormula holds the characters spreadsheets start a formula with.
+const csvFormula = "=+-@\t\r"
+
+// csvText escapes text a spreadsheet would take for a formula, customer
+// supplied titles like =HYPERLINK(...) included, by prefixing it with a
+// quote.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune(csvFormula, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// csvUnescape removes the quote csvText prefixes text with.
+func csvUnescape(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(csvFormula, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// decodeCSV reads a table with a header row into the value. A slice takes
+// every row, anything else exactly one.
+func decodeCSV(r io.Reader, v interface{}) error {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return errors.New("csv: missing header row")
+	}
+	header, records := records[0], records[1:]
+
+	rows := make([]*node, len(records))
+	for i, record := range records {
+		row := node{name: xmlItem}
+		for j, text := range record {
+			row.children = append(row.children, &node{name: header[j], text: csvUnescape(text)})
+		}
+		rows[i] = &row
+	}
+
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		return decodeNode(&node{children: rows}, v)
+	}
+
+	if len(rows) != 1 {
+		return errors.Errorf("csv: expected a single row, got %d", len(rows))
+	}
+	return decodeNode(rows[0], v)
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"bytes"
+	"encoding"
+	stdjson "encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// field is a struct field as the JSON encoding sees it.
+type field struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+// fieldsOf returns the fields of the struct type named by their json tags.
+// Fields tagged "-" and unexported fields are left out, the fields of
+// embedded structs without a tag are promoted, just like JSON does.
+func fieldsOf(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.SplitN(tag, ",", 2)[0]
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, f := range fieldsOf(ft) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, field{name: name, index: []int{i}, typ: sf.Type})
+	}
+	return fields
+}
+
+// fieldValue returns the value of the field, or an invalid value when the
+// field sits in a nil embedded struct.
+func fieldValue(v reflect.Value, f field) reflect.Value {
+	for i, x := range f.index {
+		if i > 0 {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return reflect.Value{}
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// node is an element of a document read from a format without types, like
+// an XML element or a CSV row. Its text is turned into the JSON value the
+// target type expects, so every format is decoded by the same rules.
+type node struct {
+	name     string
+	text     string
+	children []*node
+}
+
+// decodeNode decodes the node into the value as if it was sent as JSON.
+func decodeNode(n *node, v interface{}) error {
+	data, err := stdjson.Marshal(n.value(reflect.TypeOf(v)))
+	if err != nil {
+		return err
+	}
+	return decodeJSON(bytes.NewReader(data), v)
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*stdjson.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// value returns the JSON value of the node for the type. Text that does not
+// fit the type is kept as a string, so decoding reports the mismatch.
+func (n *node) value(t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		if n.text == "" && len(n.children) == 0 {
+			return nil
+		}
+		t = t.Elem()
+	}
+
+	// Types decoding themselves, like time.Time, take the text as is.
+	pt := reflect.PtrTo(t)
+	if pt.Implements(jsonUnmarshaler) || pt.Implements(textUnmarshaler) {
+		return n.text
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+		if len(n.children) == 0 {
+			if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+				return n.text
+			}
+
+			// Nested values written as JSON, like a CSV cell.
+			if stdjson.Valid([]byte(n.text)) {
+				return stdjson.RawMessage(n.text)
+			}
+			if t.Kind() == reflect.Interface {
+				return n.text
+			}
+		}
+
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(n.text); err == nil {
+			return b
+		}
+		return n.text
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(n.text, 64); err == nil {
+			return stdjson.Number(n.text)
+		}
+		return n.text
+
+	default:
+		return n.text
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		byName := make(map[string]reflect.Type)
+		for _, f := range fieldsOf(t) {
+			byName[f.name] = f.typ
+		}
+		m := make(map[string]interface{}, len(n.children))
+		for _, c := range n.children {
+			ft, ok := byName[c.name]
+			if !ok {
+				m[c.name] = c.text
+				continue
+			}
+			m[c.name] = c.value(ft)
+		}
+		return m
+
+	case reflect.Slice, reflect.Array:
+		s := make([]interface{}, 0, len(n.children))
+		for _, c := range n.children {
+			s = append(s, c.value(t.Elem()))
+		}
+		return s
+
+	case reflect.Map:
+		m := make(map[string]interface{}, len(n.children))
+		for _, c := range n.children {
+			m[c.name] = c.value(t.Elem())
+		}
+		return m
+	}
+
+	// An interface holding nested elements.
+	m := make(map[string]interface{}, len(n.children))
+	for _, c := range n.children {
+		m[c.name] = c.value(t)
+	}
+	return m
+}
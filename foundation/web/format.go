@@ -0,0 +1,164 @@
+package web
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoder writes a value to the response body in a format.
+type Encoder func(w io.Writer, v interface{}) error
+
+// Decoder reads a request body in a format into a value.
+type Decoder func(r io.Reader, v interface{}) error
+
+// format is a response format the client can ask for.
+type format struct {
+	name      string
+	mediaType string
+	encode    Encoder
+}
+
+// DefaultFormat is the format used when the client asks for none of the
+// registered ones.
+const DefaultFormat = "json"
+
+// formats holds the registered response formats in the order they were
+// registered, which decides between equally preferred media types.
+var formats []format
+
+// decoders maps media types to the decoder of request bodies sent in them.
+var decoders = make(map[string]Decoder)
+
+func init() {
+	RegisterEncoder("json", "application/json", encodeJSON)
+	RegisterEncoder("xml", "application/xml", encodeXML)
+	RegisterEncoder("csv", "text/csv", encodeCSV)
+
+	RegisterDecoder("application/json", decodeJSON)
+	RegisterDecoder("application/xml", decodeXML)
+	RegisterDecoder("text/xml", decodeXML)
+	RegisterDecoder("text/csv", decodeCSV)
+}
+
+// RegisterEncoder adds a response format clients can select with the media
+// type in the Accept header or the name in the format query parameter. A
+// format registered under an existing name replaces it. Formats must be
+// registered before the application starts serving.
+func RegisterEncoder(name string, mediaType string, enc Encoder) {
+	f := format{name: name, mediaType: mediaType, encode: enc}
+	for i := range formats {
+		if formats[i].name == name {
+			formats[i] = f
+			return
+		}
+	}
+	formats = append(formats, f)
+}
+
+// RegisterDecoder adds the decoder of request bodies sent with the media
+// type as Content-Type. Decoders must be registered before the application
+// starts serving.
+func RegisterDecoder(mediaType string, dec Decoder) {
+	decoders[mediaType] = dec
+}
+
+// lookupFormat returns the registered format with the name, falling back
+// to the default format.
+func lookupFormat(name string) format {
+	for _, f := range formats {
+		if f.name == name {
+			return f
+		}
+	}
+	for _, f := range formats {
+		if f.name == DefaultFormat {
+			return f
+		}
+	}
+	return format{name: DefaultFormat, mediaType: "application/json", encode: encodeJSON}
+}
+
+// negotiate returns the name of the format to respond to the request in.
+// The format query parameter wins over the Accept header. The default
+// format is used when the client asks for nothing that is registered.
+func negotiate(r *http.Request) string {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, f := range formats {
+			if f.name == name {
+				return f.name
+			}
+		}
+		return DefaultFormat
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return DefaultFormat
+	}
+
+	type mediaRange struct {
+		typ string
+		q   float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{typ, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, mr := range ranges {
+		if mr.typ == "*/*" {
+			return DefaultFormat
+		}
+		for _, f := range formats {
+			if matchMedia(mr.typ, f.mediaType) {
+				return f.name
+			}
+		}
+	}
+
+	return DefaultFormat
+}
+
+// matchMedia reports whether the media type falls in the media range, which
+// may have a wildcard subtype like text/*.
+func matchMedia(mediaRange string, mediaType string) bool {
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+	}
+	return mediaRange == mediaType
+}
+
+// encodeJSON writes the value as a JSON document.
+func encodeJSON(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// decodeJSON reads a JSON document rejecting fields the value does not
+// have.
+func decodeJSON(r io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// messages maps locales to the translations of error messages, keyed by the
+// English message.
+var messages = make(map[string]map[string]string)
+
+func init() {
+	RegisterMessages("ru", map[string]string{
+		"field validation error":                        "ошибка проверки полей",
+		http.StatusText(http.StatusInternalServerError): "Внутренняя ошибка сервера",
+	})
+}
+
+// RegisterMessages adds translations of error messages sent to clients,
+// keyed by the English message of the error. Errors are usually the
+// sentinel errors of the business packages, so one translation covers
+// every package using the same message. Messages must be registered before
+// the application starts serving.
+func RegisterMessages(locale string, msgs map[string]string) {
+	locale = strings.ToLower(locale)
+	m, ok := messages[locale]
+	if !ok {
+		m = make(map[string]string, len(msgs))
+		messages[locale] = m
+	}
+	for k, v := range msgs {
+		m[k] = v
+	}
+}
+
+// message returns the message of the error in the locale. A wrapped error
+// not translated as a whole gets the translation of its cause, anything
+// not translated keeps the English message.
+func message(locale string, err error) string {
+	msg := err.Error()
+	m := messages[locale]
+	if t, ok := m[msg]; ok {
+		return t
+	}
+	if t, ok := m[errors.Cause(err).Error()]; ok {
+		return t
+	}
+	return msg
+}
+
+// negotiateLocale returns the locale of the messages for the request,
+// chosen from the Accept-Language header among the locales of the
+// validator. Regional variants like ru-RU fall back to their language.
+func negotiateLocale(r *http.Request) string {
+	type language struct {
+		tag string
+		q   float64
+	}
+	var langs []language
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, language{tag, q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	var tags []string
+	for _, l := range langs {
+		tag := strings.ReplaceAll(l.tag, "-", "_")
+		tags = append(tags, tag)
+		if i := strings.Index(tag, "_"); i != -1 {
+			tags = append(tags, tag[:i])
+		}
+	}
+
+	trans, _ := translator.FindTranslator(tags...)
+	return trans.Locale()
+}
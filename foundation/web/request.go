@@ -2,6 +2,8 @@ package web
 
 import (
 	"errors"
+	"fmt"
+	"mime"
 	"net/http"
 	"reflect"
 	"strings"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/dimfeld/httptreemux/v5"
 	en "github.com/go-playground/locales/en"
+	ru "github.com/go-playground/locales/ru"
 	ut "github.com/go-playground/universal-translator"
 	validator "gopkg.in/go-playground/validator.v9"
 	en_translations "gopkg.in/go-playground/validator.v9/translations/en"
@@ -31,11 +34,15 @@ func init() {
 
 	// Create a value using English as the fallback locale (first argument).
 	// Provide one or more arguments for additional supported locales.
-	translator = ut.New(enLocale, enLocale)
+	translator = ut.New(enLocale, enLocale, ru.New())
 
-	// Register the english error messages for validation errors.
+	// Register the english and russian error messages for validation errors.
 	lang, _ := translator.GetTranslator("en")
 	en_translations.RegisterDefaultTranslations(validate, lang)
+	lang, _ = translator.GetTranslator("ru")
+	if err := registerRussian(validate, lang); err != nil {
+		panic(err)
+	}
 
 	// Use JSON tag names for errors instead of Go struct names.
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
@@ -52,40 +59,69 @@ func Params(r *http.Request) map[string]string {
 	return httptreemux.ContextParams(r.Context())
 }
 
+// Route returns the pattern of the route that matched the request.
+func Route(r *http.Request) string {
+	return httptreemux.ContextRoute(r.Context())
+}
+
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
-// Decode reads the body of an HTTP request looking for a JSON document. The
-// body is decoded into the provided value.
+// Decode reads the body of an HTTP request in the format named by its
+// Content-Type, JSON when it has none. The body is decoded into the
+// provided value.
 //
-// If the provided value is a struct then it is checked for validation tags.
+// If the provided value is a struct, or a slice of structs, then it is
+// checked for validation tags.
 func Decode(r *http.Request, val interface{}) error {
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
-	if err := decoder.Decode(val); err != nil {
-		return NewRequestError(err, http.StatusBadRequest)
+	mediaType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return NewRequestError(err, http.StatusUnsupportedMediaType)
+		}
+		mediaType = mt
 	}
 
-	if err := validate.Struct(val); err != nil {
+	decode, ok := decoders[mediaType]
+	if !ok {
+		return NewRequestError(fmt.Errorf("unsupported content type %q", mediaType), http.StatusUnsupportedMediaType)
+	}
+	if err := decode(r.Body, val); err != nil {
+		return NewRequestError(err, http.StatusBadRequest)
+	}
 
-		// Use a type assertion to get the real error value.
-		verrors, ok := err.(validator.ValidationErrors)
-		if !ok {
+	// lang controls the language of the error messages, the one the client
+	// prefers among the ones registered. Tags with no message in that
+	// language get the English one.
+	lang, _ := translator.GetTranslator(negotiateLocale(r))
+
+	// A slice of structs has every item checked, the fields of an item are
+	// named after its index like [0].qty.
+	var fields []FieldError
+	rv := reflect.Indirect(reflect.ValueOf(val))
+	switch {
+	case rv.Kind() == reflect.Struct:
+		fs, err := validateStruct(val, lang, "")
+		if err != nil {
 			return err
 		}
+		fields = fs
 
-		// lang controls the language of the error messages. You could look at the
-		// Accept-Language header if you intend to support multiple languages.
-		lang, _ := translator.GetTranslator("en")
-
-		var fields []FieldError
-		for _, verror := range verrors {
-			field := FieldError{
-				Field: verror.Field(),
-				Error: verror.Translate(lang),
+	case (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && isStruct(reflect.Zero(rv.Type().Elem()).Interface()):
+		for i := 0; i < rv.Len(); i++ {
+			item := rv.Index(i)
+			if item.Kind() == reflect.Ptr && item.IsNil() {
+				continue
+			}
+			fs, err := validateStruct(item.Interface(), lang, fmt.Sprintf("[%d].", i))
+			if err != nil {
+				return err
 			}
-			fields = append(fields, field)
+			fields = append(fields, fs...)
 		}
+	}
 
+	if len(fields) > 0 {
 		return &Error{
 			Err:    errors.New("field validation error"),
 			Status: http.StatusBadRequest,
@@ -95,3 +131,44 @@ func Decode(r *http.Request, val interface{}) error {
 
 	return nil
 }
+
+// validateStruct checks the validation tags of the struct and returns the
+// failed fields with their messages in the language, the names prefixed.
+func validateStruct(val interface{}, lang ut.Translator, prefix string) ([]FieldError, error) {
+	err := validate.Struct(val)
+	if err == nil {
+		return nil, nil
+	}
+
+	// Use a type assertion to get the real error value.
+	verrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return nil, err
+	}
+
+	fallback := translator.GetFallback()
+
+	var fields []FieldError
+	for _, verror := range verrors {
+		msg := verror.Translate(lang)
+		if msg == verror.(error).Error() {
+			msg = verror.Translate(fallback)
+		}
+		field := FieldError{
+			Field: prefix + verror.Field(),
+			Error: msg,
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// isStruct reports whether the value is a struct or points to one.
+func isStruct(val interface{}) bool {
+	t := reflect.TypeOf(val)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t != nil && t.Kind() == reflect.Struct
+}
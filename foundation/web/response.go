@@ -1,16 +1,16 @@
 package web
 
 import (
+	"bytes"
 	"context"
 	"net/http"
 
-	jsoniter "github.com/json-iterator/go"
-
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel/trace"
 )
 
-// Respond converts a Go value to JSON and sends it to the client.
+// Respond converts a Go value to the format negotiated with the client and
+// sends it to the client.
 func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statusCode int) error {
 	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "foundation.web.respond")
 	defer span.End()
@@ -30,35 +30,44 @@ func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 		return nil
 	}
 
-	var json = jsoniter.ConfigCompatibleWithStandardLibrary
-	// Convert the response value to JSON.
-	jsonData, err := json.Marshal(data)
-	if err != nil {
+	// Convert the response value to the format of the request.
+	f := lookupFormat(v.Format)
+	var body bytes.Buffer
+	if err := f.encode(&body, data); err != nil {
 		return err
 	}
 
 	// Set the content type and headers once we know marshaling has succeeded.
-	w.Header().Set("Content-Type", "application/json")
+	// The format depends on the Accept header so caches must keep them apart.
+	w.Header().Set("Content-Type", f.mediaType)
+	w.Header().Add("Vary", "Accept")
 
 	// Write the status code to the response.
 	w.WriteHeader(statusCode)
 
 	// Send the result back to the client.
-	if _, err := w.Write(jsonData); err != nil {
+	if _, err := w.Write(body.Bytes()); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// RespondError sends an error reponse back to the client.
+// RespondError sends an error reponse back to the client. The message is
+// translated to the language negotiated with the client when a translation
+// is registered.
 func RespondError(ctx context.Context, w http.ResponseWriter, err error) error {
+	var locale string
+	if v, ok := ctx.Value(KeyValues).(*Values); ok {
+		locale = v.Locale
+	}
+	w.Header().Add("Vary", "Accept-Language")
 
 	// If the error was of the type *Error, the handler has
 	// a specific status code and error to return.
 	if webErr, ok := errors.Cause(err).(*Error); ok {
 		er := ErrorResponse{
-			Error:  webErr.Err.Error(),
+			Error:  message(locale, webErr.Err),
 			Fields: webErr.Fields,
 		}
 		if err := Respond(ctx, w, er, webErr.Status); err != nil {
@@ -69,7 +78,7 @@ func RespondError(ctx context.Context, w http.ResponseWriter, err error) error {
 
 	// If not, the handler sent any arbitrary error value so use 500.
 	er := ErrorResponse{
-		Error: http.StatusText(http.StatusInternalServerError),
+		Error: message(locale, errors.New(http.StatusText(http.StatusInternalServerError))),
 	}
 	if err := Respond(ctx, w, er, http.StatusInternalServerError); err != nil {
 		return err
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/locales"
+	ut "github.com/go-playground/universal-translator"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// The validator ships no Russian translations, these cover the tags the
+// service validates with. Messages name the field as "поле {0}" so they
+// read right whatever the gender of the field's name.
+
+// ruMessages are the messages of the tags taking at most one parameter.
+var ruMessages = map[string]string{
+	"required": "поле {0} обязательно для заполнения",
+	"eq":       "поле {0} должно быть равно {1}",
+	"ne":       "поле {0} не должно быть равно {1}",
+	"eqfield":  "поле {0} должно совпадать с полем {1}",
+	"nefield":  "поле {0} не должно совпадать с полем {1}",
+	"gtfield":  "поле {0} должно быть больше поля {1}",
+	"gtefield": "поле {0} должно быть не меньше поля {1}",
+	"ltfield":  "поле {0} должно быть меньше поля {1}",
+	"ltefield": "поле {0} должно быть не больше поля {1}",
+	"oneof":    "поле {0} должно быть одним из значений [{1}]",
+	"email":    "поле {0} должно содержать корректный адрес электронной почты",
+	"url":      "поле {0} должно содержать корректный URL",
+	"uri":      "поле {0} должно содержать корректный URI",
+	"uuid":     "поле {0} должно содержать корректный UUID",
+	"uuid4":    "поле {0} должно содержать корректный UUID версии 4",
+	"numeric":  "поле {0} должно содержать число",
+	"number":   "поле {0} должно содержать число",
+	"alpha":    "поле {0} может содержать только буквы",
+	"alphanum": "поле {0} может содержать только буквы и цифры",
+	"unique":   "поле {0} должно содержать уникальные значения",
+}
+
+// ruSizes are the messages of the tags comparing the length of a string,
+// the number of items or a number with the parameter. A time is compared
+// with the current time.
+var ruSizes = map[string]struct {
+	str, items, number, datetime string
+}{
+	"len": {"поле {0} должно содержать {1}", "поле {0} должно содержать {1}", "поле {0} должно быть равно {1}", ""},
+	"min": {"поле {0} должно содержать не менее {1}", "поле {0} должно содержать не менее {1}", "поле {0} должно быть не меньше {1}", ""},
+	"max": {"поле {0} должно содержать не более {1}", "поле {0} должно содержать не более {1}", "поле {0} должно быть не больше {1}", ""},
+	"gt":  {"поле {0} должно содержать более {1}", "поле {0} должно содержать более {1}", "поле {0} должно быть больше {1}", "поле {0} должно быть позже текущего момента"},
+	"gte": {"поле {0} должно содержать не менее {1}", "поле {0} должно содержать не менее {1}", "поле {0} должно быть не меньше {1}", "поле {0} должно быть не раньше текущего момента"},
+	"lt":  {"поле {0} должно содержать менее {1}", "поле {0} должно содержать менее {1}", "поле {0} должно быть меньше {1}", "поле {0} должно быть раньше текущего момента"},
+	"lte": {"поле {0} должно содержать не более {1}", "поле {0} должно содержать не более {1}", "поле {0} должно быть не больше {1}", "поле {0} должно быть не позже текущего момента"},
+}
+
+// ruCounts are the plural forms of the counted characters and items. An
+// exact length takes the accusative, the comparisons the genitive.
+var ruCounts = map[string]map[locales.PluralRule]string{
+	"character": {
+		locales.PluralRuleOne:   "{0} символ",
+		locales.PluralRuleFew:   "{0} символа",
+		locales.PluralRuleMany:  "{0} символов",
+		locales.PluralRuleOther: "{0} символа",
+	},
+	"character-gen": {
+		locales.PluralRuleOne:   "{0} символа",
+		locales.PluralRuleFew:   "{0} символов",
+		locales.PluralRuleMany:  "{0} символов",
+		locales.PluralRuleOther: "{0} символа",
+	},
+	"item": {
+		locales.PluralRuleOne:   "{0} элемент",
+		locales.PluralRuleFew:   "{0} элемента",
+		locales.PluralRuleMany:  "{0} элементов",
+		locales.PluralRuleOther: "{0} элемента",
+	},
+	"item-gen": {
+		locales.PluralRuleOne:   "{0} элемента",
+		locales.PluralRuleFew:   "{0} элементов",
+		locales.PluralRuleMany:  "{0} элементов",
+		locales.PluralRuleOther: "{0} элемента",
+	},
+}
+
+// registerRussian registers the Russian messages of the validator with the
+// translator.
+func registerRussian(v *validator.Validate, trans ut.Translator) error {
+	for key, forms := range ruCounts {
+		for rule, text := range forms {
+			if err := trans.AddCardinal(key, text, rule, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	for tag, text := range ruMessages {
+		tag, text := tag, text
+		register := func(trans ut.Translator) error {
+			return trans.Add(tag, text, false)
+		}
+		translate := func(trans ut.Translator, fe validator.FieldError) string {
+			t, err := trans.T(tag, fe.Field(), fe.Param())
+			if err != nil {
+				return fe.(error).Error()
+			}
+			return t
+		}
+		if err := v.RegisterTranslation(tag, trans, register, translate); err != nil {
+			return err
+		}
+	}
+
+	for tag, msgs := range ruSizes {
+		tag, msgs := tag, msgs
+		register := func(trans ut.Translator) error {
+			for kind, text := range map[string]string{"string": msgs.str, "items": msgs.items, "number": msgs.number, "datetime": msgs.datetime} {
+				if text == "" {
+					continue
+				}
+				if err := trans.Add(tag+"-"+kind, text, false); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := v.RegisterTranslation(tag, trans, register, ruSize(tag)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ruSize returns the translation of a tag comparing sizes, which depends on
+// the kind of the field and on the parameter for the plural form.
+func ruSize(tag string) validator.TranslationFunc {
+	return func(trans ut.Translator, fe validator.FieldError) string {
+		t := fe.Type()
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if t == reflect.TypeOf(time.Time{}) {
+			if s, err := trans.T(tag+"-datetime", fe.Field()); err == nil {
+				return s
+			}
+			return fe.(error).Error()
+		}
+
+		f64, err := strconv.ParseFloat(fe.Param(), 64)
+		if err != nil {
+			return fe.(error).Error()
+		}
+		var digits uint64
+		if i := strings.Index(fe.Param(), "."); i != -1 {
+			digits = uint64(len(fe.Param()[i+1:]))
+		}
+		num := trans.FmtNumber(f64, digits)
+
+		// An exact length counts in the accusative, comparisons in the
+		// genitive: "5 символов" but "не менее 1 символа".
+		suffix := "-gen"
+		if tag == "len" {
+			suffix = ""
+		}
+
+		var s string
+		switch t.Kind() {
+		case reflect.String:
+			var c string
+			if c, err = trans.C("character"+suffix, f64, digits, num); err == nil {
+				s, err = trans.T(tag+"-string", fe.Field(), c)
+			}
+		case reflect.Slice, reflect.Map, reflect.Array:
+			var c string
+			if c, err = trans.C("item"+suffix, f64, digits, num); err == nil {
+				s, err = trans.T(tag+"-items", fe.Field(), c)
+			}
+		default:
+			s, err = trans.T(tag+"-number", fe.Field(), num)
+		}
+		if err != nil {
+			return fe.(error).Error()
+		}
+		return s
+	}
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/dimfeld/httptreemux/v5"
 	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ctxKey represents the type of value for the context key.
@@ -17,11 +23,21 @@ type ctxKey int
 // KeyValues is how request values are stored/retrieved.
 const KeyValues ctxKey = 1
 
-// Values represent state for each request.
+// RequestIDHeader carries the trace ID of a request. An ID sent by the
+// client or a proxy is kept, so a request can be followed across services.
+const RequestIDHeader = "X-Request-ID"
+
+// Values represent state for each request. UserID is set by the middleware
+// authenticating the request. Format names the format responses are sent
+// in and Locale the language of their messages, as negotiated with the
+// client.
 type Values struct {
 	TraceID    string
 	Now        time.Time
 	StatusCode int
+	UserID     string
+	Format     string
+	Locale     string
 }
 
 type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) error
@@ -29,22 +45,39 @@ type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) e
 type App struct {
 	*httptreemux.ContextMux
 	shutdown chan os.Signal
+	log      *logger.Logger
 	mw       []Middleware
+	options  map[string]bool
+	tracer   trace.Tracer
 }
 
-func NewApp(shutdown chan os.Signal, mw ...Middleware) *App {
+// NewApp creates an App that signals shutdown on the channel and logs the
+// errors no middleware handled.
+func NewApp(shutdown chan os.Signal, log *logger.Logger, mw ...Middleware) *App {
 	app := App{
 		ContextMux: httptreemux.NewContextMux(),
 		shutdown:   shutdown,
+		log:        log,
 		mw:         mw,
+		options:    make(map[string]bool),
+		tracer:     otel.Tracer("foundation.web"),
 	}
+
+	// Preflight requests do not follow redirects, so a path registered
+	// with a trailing slash answers OPTIONS either way.
+	app.ContextMux.RedirectMethodBehavior[http.MethodOptions] = httptreemux.UseHandler
+
 	return &app
 }
 
 // SignalShutdown is used to gracefully shutdown the app when an integrity
-// issue is identified.
+// issue is identified. A shutdown already in flight is not signalled twice,
+// so concurrent requests failing the same way never block.
 func (a *App) SignalShutdown() {
-	a.shutdown <- syscall.SIGTERM
+	select {
+	case a.shutdown <- syscall.SIGTERM:
+	default:
+	}
 }
 
 // Handle ...
@@ -56,30 +89,93 @@ func (a *App) Handle(method string, path string, handler Handler, mw ...Middlewa
 	// Add the application's general middleware to the handler chain.
 	handler = wrapMiddleware(a.mw, handler)
 
-	h := func(w http.ResponseWriter, r *http.Request) {
-		setupCORS(&w, r)
-		if (*r).Method == "OPTIONS" {
-			return
+	a.ContextMux.Handle(method, path, a.serve(handler))
+
+	// Every path answers OPTIONS so CORS preflight requests reach the
+	// application's middleware. Route specific middleware is left out as
+	// preflight requests carry no credentials. The mux keeps a path with
+	// and without a trailing slash on the same node, so they share one.
+	node := strings.TrimSuffix(path, "/")
+	if method != http.MethodOptions && !a.options[node] {
+		a.options[node] = true
+		options := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return Respond(ctx, w, nil, http.StatusNoContent)
 		}
+		a.ContextMux.Handle(http.MethodOptions, path, a.serve(wrapMiddleware(a.mw, options)))
+	}
+}
+
+// serve turns the handler chain into a function the mux can call.
+func (a *App) serve(handler Handler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Start the root span of the request, continuing the trace of
+		// the caller if it sent one.
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), r.Header)
+		ctx, span := a.tracer.Start(ctx, r.Method+" "+Route(r),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(r.Method),
+				semconv.HTTPRouteKey.String(Route(r)),
+				semconv.HTTPTargetKey.String(r.RequestURI),
+			),
+		)
+		defer span.End()
 
 		// Set the context with the required values to
 		// process the request.
 		v := Values{
-			TraceID: uuid.New().String(),
+			TraceID: requestID(r),
 			Now:     time.Now(),
+			Format:  negotiate(r),
+			Locale:  negotiateLocale(r),
 		}
-		ctx := context.WithValue(r.Context(), KeyValues, &v)
-
-		if err := handler(ctx, w, r); err != err {
-			a.SignalShutdown()
-			return
+		ctx = context.WithValue(ctx, KeyValues, &v)
+		w.Header().Set(RequestIDHeader, v.TraceID)
+
+		// Record the status once the middleware has responded.
+		defer func() {
+			span.SetAttributes(
+				label.String("http.request_id", v.TraceID),
+				semconv.HTTPStatusCodeKey.Int(v.StatusCode),
+			)
+			span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(v.StatusCode))
+		}()
+
+		if err := handler(ctx, w, r); err != nil {
+			if IsShutdown(err) {
+				a.log.Error("shutdown", logger.TraceID(v.TraceID), logger.Err(err))
+				a.SignalShutdown()
+				return
+			}
+
+			// Errors should be handled by the middleware, anything getting
+			// here slipped through and must not go unnoticed.
+			a.log.Error("unhandled", logger.TraceID(v.TraceID), logger.Err(err))
 		}
 	}
-	a.ContextMux.Handle(method, path, h)
 }
 
-func setupCORS(w *http.ResponseWriter, req *http.Request) {
-	(*w).Header().Set("Access-Control-Allow-Origin", "*")
-	(*w).Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-	(*w).Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+// maxRequestID is the longest request ID taken from a request.
+const maxRequestID = 128
+
+// requestID returns the ID sent with the request or a new one. IDs that are
+// too long or contain anything but letters, digits and -_.:/+= are replaced
+// so they cannot break the logs.
+func requestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > maxRequestID {
+		return uuid.New().String()
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("-_.:/+=", c):
+		default:
+			return uuid.New().String()
+		}
+	}
+
+	return id
 }
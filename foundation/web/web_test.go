@@ -0,0 +1,447 @@
+package web_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/oteltest"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// safeBuffer is a log destination that can be read while requests write to it.
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// serve runs the app like the service does: the server is shut down
+// gracefully once a signal arrives on the shutdown channel. The returned
+// channel receives the result of Serve.
+func serve(t *testing.T, app *web.App, shutdown chan os.Signal) (string, <-chan error) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %s", err)
+	}
+
+	api := http.Server{Handler: app}
+	served := make(chan error, 1)
+	go func() {
+		served <- api.Serve(l)
+	}()
+
+	go func() {
+		<-shutdown
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		api.Shutdown(ctx)
+	}()
+
+	t.Cleanup(func() { api.Close() })
+
+	return "http://" + l.Addr().String(), served
+}
+
+func TestShutdownError(t *testing.T) {
+	var logs safeBuffer
+	shutdown := make(chan os.Signal, 1)
+	app := web.NewApp(shutdown, logger.New(&logs, logger.Debug))
+
+	app.Handle(http.MethodGet, "/integrity", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusInternalServerError)
+		return web.NewShutdownError("integrity issue")
+	})
+
+	url, served := serve(t, app, shutdown)
+
+	resp, err := http.Get(url + "/integrity")
+	if err != nil {
+		t.Fatalf("Should be able to make the request : %s", err)
+	}
+	resp.Body.Close()
+
+	select {
+	case err := <-served:
+		if err != http.ErrServerClosed {
+			t.Fatalf("Should stop the server gracefully : %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Should stop the server after a shutdown error")
+	}
+
+	if !strings.Contains(logs.String(), "integrity issue") {
+		t.Fatalf("Should log the shutdown error : %q", logs.String())
+	}
+}
+
+func TestShutdownErrorDoesNotBlock(t *testing.T) {
+	shutdown := make(chan os.Signal, 1)
+	app := web.NewApp(shutdown, logger.New(&safeBuffer{}, logger.Debug))
+
+	// Nobody consumes the signal, later requests must still complete.
+	done := make(chan struct{})
+	go func() {
+		app.SignalShutdown()
+		app.SignalShutdown()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Should not block on a pending shutdown signal")
+	}
+}
+
+func TestUnhandledError(t *testing.T) {
+	var logs safeBuffer
+	shutdown := make(chan os.Signal, 1)
+	app := web.NewApp(shutdown, logger.New(&logs, logger.Debug))
+
+	app.Handle(http.MethodGet, "/broken", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusInternalServerError)
+		return errors.New("escaped the middleware")
+	})
+
+	url, served := serve(t, app, shutdown)
+
+	resp, err := http.Get(url + "/broken")
+	if err != nil {
+		t.Fatalf("Should be able to make the request : %s", err)
+	}
+	resp.Body.Close()
+
+	if !strings.Contains(logs.String(), `"msg":"unhandled","trace_id":`) {
+		t.Fatalf("Should log errors no middleware handled : %q", logs.String())
+	}
+
+	select {
+	case err := <-served:
+		t.Fatalf("Should keep serving after an ordinary error : %v", err)
+	case <-shutdown:
+		t.Fatal("Should not signal shutdown for an ordinary error")
+	default:
+	}
+}
+
+func TestOptions(t *testing.T) {
+	shutdown := make(chan os.Signal, 1)
+
+	// The application's middleware sees the preflight request, the route
+	// specific middleware does not.
+	var seen []string
+	app := web.NewApp(shutdown, logger.New(&safeBuffer{}, logger.Debug), func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			seen = append(seen, "app "+r.Method+" "+web.Route(r))
+			return handler(ctx, w, r)
+		}
+	})
+
+	deny := func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			seen = append(seen, "route "+r.Method)
+			return web.Respond(ctx, w, nil, http.StatusUnauthorized)
+		}
+	}
+	noop := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+	app.Handle(http.MethodGet, "/item/:id", noop, deny)
+	app.Handle(http.MethodDelete, "/item/:id", noop, deny)
+
+	url, _ := serve(t, app, shutdown)
+
+	req, err := http.NewRequest(http.MethodOptions, url+"/item/1", nil)
+	if err != nil {
+		t.Fatalf("Should be able to create the request : %s", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Should be able to make the request : %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Should answer OPTIONS with %d : got %d", http.StatusNoContent, resp.StatusCode)
+	}
+	if len(seen) != 1 || seen[0] != "app OPTIONS /item/:id" {
+		t.Fatalf("Should only run the application's middleware : %v", seen)
+	}
+
+	// A path with and without a trailing slash share one OPTIONS handler
+	// which answers both without redirecting.
+	app.Handle(http.MethodGet, "/list/", noop)
+	app.Handle(http.MethodPost, "/list", noop)
+
+	client := http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	for _, path := range []string{"/list", "/list/"} {
+		req, err := http.NewRequest(http.MethodOptions, url+path, nil)
+		if err != nil {
+			t.Fatalf("Should be able to create the request : %s", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Should be able to make the request : %s", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("%s: Should answer OPTIONS with %d : got %d", path, http.StatusNoContent, resp.StatusCode)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	shutdown := make(chan os.Signal, 1)
+	app := web.NewApp(shutdown, logger.New(&safeBuffer{}, logger.Debug))
+
+	var traceID string
+	app.Handle(http.MethodGet, "/trace", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		traceID = ctx.Value(web.KeyValues).(*web.Values).TraceID
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	})
+
+	url, _ := serve(t, app, shutdown)
+
+	tt := []struct {
+		name string
+		sent string
+		keep bool
+	}{
+		{"missing", "", false},
+		{"valid", "req-42.a:b", true},
+		{"invalid", "bad id {}", false},
+		{"long", strings.Repeat("a", 129), false},
+	}
+
+	for _, tc := range tt {
+		req, err := http.NewRequest(http.MethodGet, url+"/trace", nil)
+		if err != nil {
+			t.Fatalf("Should be able to create the request : %s", err)
+		}
+		if tc.sent != "" {
+			req.Header.Set(web.RequestIDHeader, tc.sent)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Should be able to make the request : %s", err)
+		}
+		resp.Body.Close()
+
+		got := resp.Header.Get(web.RequestIDHeader)
+		if got != traceID {
+			t.Fatalf("%s: Should echo the trace ID : got %q, want %q", tc.name, got, traceID)
+		}
+		if (got == tc.sent) != tc.keep {
+			t.Fatalf("%s: Should keep only valid request IDs : sent %q, got %q", tc.name, tc.sent, got)
+		}
+	}
+}
+
+func TestRootSpan(t *testing.T) {
+	var sr oteltest.StandardSpanRecorder
+	otel.SetTracerProvider(oteltest.NewTracerProvider(oteltest.WithSpanRecorder(&sr)))
+
+	shutdown := make(chan os.Signal, 1)
+	app := web.NewApp(shutdown, logger.New(&safeBuffer{}, logger.Debug))
+
+	app.Handle(http.MethodGet, "/item/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	})
+
+	url, _ := serve(t, app, shutdown)
+
+	resp, err := http.Get(url + "/item/1")
+	if err != nil {
+		t.Fatalf("Should be able to make the request : %s", err)
+	}
+	resp.Body.Close()
+
+	// The span ends after the response is written, give it a moment.
+	var roots []*oteltest.Span
+	for i := 0; i < 100 && len(roots) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		for _, span := range sr.Completed() {
+			if !span.ParentSpanID().IsValid() {
+				roots = append(roots, span)
+			}
+		}
+	}
+	if len(roots) != 1 {
+		t.Fatalf("Should record a root span per request : got %d", len(roots))
+	}
+
+	span := roots[0]
+	if span.Name() != "GET /item/:id" {
+		t.Fatalf("Should name the span after the route : got %q", span.Name())
+	}
+	attrs := span.Attributes()
+	if attrs[semconv.HTTPRouteKey].AsString() != "/item/:id" {
+		t.Fatalf("Should record the route : got %v", attrs[semconv.HTTPRouteKey])
+	}
+	if attrs[semconv.HTTPStatusCodeKey].AsInt64() != http.StatusNoContent {
+		t.Fatalf("Should record the status : got %v", attrs[semconv.HTTPStatusCodeKey])
+	}
+}
+
+func TestFormats(t *testing.T) {
+	type item struct {
+		ID    string   `json:"id"`
+		Price int      `json:"price" validate:"gte=0"`
+		Tags  []string `json:"tags"`
+		Note  *string  `json:"note,omitempty"`
+	}
+
+	shutdown := make(chan os.Signal, 1)
+	app := web.NewApp(shutdown, logger.New(&safeBuffer{}, logger.Debug))
+
+	app.Handle(http.MethodPost, "/items", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var items []item
+		if err := web.Decode(r, &items); err != nil {
+			return web.RespondError(ctx, w, err)
+		}
+		return web.Respond(ctx, w, items, http.StatusOK)
+	})
+
+	url, _ := serve(t, app, shutdown)
+
+	const (
+		jsonBody = `[{"id":"a","price":10,"tags":["x","y"]}]`
+		xmlBody  = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response><item><id>a</id><price>10</price><tags><item>x</item><item>y</item></tags><note></note></item></response>`
+		xmlResp  = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response><item><id>a</id><price>10</price><tags><item>x</item><item>y</item></tags></item></response>`
+		csvBody  = "id,price,tags,note\na,10,\"[\"\"x\"\",\"\"y\"\"]\",\n"
+	)
+
+	tt := []struct {
+		name        string
+		contentType string
+		body        string
+		query       string
+		accept      string
+		status      int
+		respType    string
+		resp        string
+	}{
+		{"json", "application/json", jsonBody, "", "", http.StatusOK, "application/json", `[{"id":"a","price":10,"tags":["x","y"]}]`},
+		{"xml accept", "application/json", jsonBody, "", "text/html, application/xml;q=0.9", http.StatusOK, "application/xml", xmlResp},
+		{"csv query", "application/json", jsonBody, "?format=csv", "application/xml", http.StatusOK, "text/csv", csvBody},
+		{"xml body", "application/xml; charset=utf-8", xmlBody, "", "", http.StatusOK, "application/json", `[{"id":"a","price":10,"tags":["x","y"]}]`},
+		{"csv body", "text/csv", csvBody, "", "", http.StatusOK, "application/json", `[{"id":"a","price":10,"tags":["x","y"]}]`},
+		{"wrong type", "text/xml", "<response><item><price>ten</price></item></response>", "", "", http.StatusBadRequest, "application/json", ""},
+		{"unknown type", "application/yaml", "- id: a", "", "", http.StatusUnsupportedMediaType, "application/json", ""},
+		{"invalid item", "application/json", `[{"id":"a","price":1},{"id":"b","price":-1}]`, "", "", http.StatusBadRequest, "application/json", `{"error":"field validation error","fields":[{"field":"[1].price","error":"price must be 0 or greater"}]}`},
+		{"csv escape", "application/json", `[{"id":"=1+2","price":0,"tags":["-x"]}]`, "?format=csv", "", http.StatusOK, "text/csv", "id,price,tags,note\n'=1+2,0,\"[\"\"-x\"\"]\",\n"},
+		{"csv unescape", "text/csv", "id,price,tags,note\n'=1+2,0,[],\n", "", "", http.StatusOK, "application/json", `[{"id":"=1+2","price":0,"tags":[]}]`},
+	}
+
+	for _, tc := range tt {
+		req, err := http.NewRequest(http.MethodPost, url+"/items"+tc.query, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatalf("Should be able to create the request : %s", err)
+		}
+		req.Header.Set("Content-Type", tc.contentType)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Should be able to make the request : %s", err)
+		}
+		var body bytes.Buffer
+		body.ReadFrom(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.status {
+			t.Fatalf("%s: Should receive status %d : got %d %s", tc.name, tc.status, resp.StatusCode, body.String())
+		}
+		if got := resp.Header.Get("Content-Type"); got != tc.respType {
+			t.Fatalf("%s: Should respond in %s : got %s", tc.name, tc.respType, got)
+		}
+		if tc.resp != "" && body.String() != tc.resp {
+			t.Fatalf("%s: Should respond with the items : got\n%s\nwant\n%s", tc.name, body.String(), tc.resp)
+		}
+	}
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	type order struct {
+		Name string   `json:"name" validate:"required"`
+		Code string   `json:"code" validate:"min=3"`
+		Qty  int      `json:"qty" validate:"gte=1"`
+		Tags []string `json:"tags" validate:"len=2"`
+	}
+
+	web.RegisterMessages("ru", map[string]string{"not found": "не найдено"})
+
+	shutdown := make(chan os.Signal, 1)
+	app := web.NewApp(shutdown, logger.New(&safeBuffer{}, logger.Debug))
+
+	app.Handle(http.MethodPost, "/orders", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var o order
+		if err := web.Decode(r, &o); err != nil {
+			return web.RespondError(ctx, w, err)
+		}
+		return web.RespondError(ctx, w, web.NewRequestError(errors.New("not found"), http.StatusNotFound))
+	})
+
+	url, _ := serve(t, app, shutdown)
+
+	tt := []struct {
+		name     string
+		language string
+		body     string
+		exp      string
+	}{
+		{"english", "", `{"code":"ab","tags":["a"]}`, `{"error":"field validation error","fields":[{"field":"name","error":"name is a required field"},{"field":"code","error":"code must be at least 3 characters in length"},{"field":"qty","error":"qty must be 1 or greater"},{"field":"tags","error":"tags must contain 2 items"}]}`},
+		{"russian", "ru-RU,ru;q=0.9,en;q=0.8", `{"code":"ab","tags":["a"]}`, `{"error":"ошибка проверки полей","fields":[{"field":"name","error":"поле name обязательно для заполнения"},{"field":"code","error":"поле code должно содержать не менее 3 символов"},{"field":"qty","error":"поле qty должно быть не меньше 1"},{"field":"tags","error":"поле tags должно содержать 2 элемента"}]}`},
+		{"preferred", "de, en;q=0.5, ru;q=0.7", `{"name":"a","code":"abc","qty":1,"tags":["a","b"]}`, `{"error":"не найдено"}`},
+		{"unknown", "de", `{"name":"a","code":"abc","qty":1,"tags":["a","b"]}`, `{"error":"not found"}`},
+	}
+
+	for _, tc := range tt {
+		req, err := http.NewRequest(http.MethodPost, url+"/orders", strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatalf("Should be able to create the request : %s", err)
+		}
+		if tc.language != "" {
+			req.Header.Set("Accept-Language", tc.language)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Should be able to make the request : %s", err)
+		}
+		var body bytes.Buffer
+		body.ReadFrom(resp.Body)
+		resp.Body.Close()
+
+		if body.String() != tc.exp {
+			t.Fatalf("%s: Should translate the errors : got\n%s\nwant\n%s", tc.name, body.String(), tc.exp)
+		}
+	}
+}
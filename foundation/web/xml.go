@@ -0,0 +1,160 @@
+package web
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// XML documents are written from the JSON encoding of the value, so the
+// elements are named by the json tags and values read the same in every
+// format. The document element is response and the elements of a list are
+// items.
+const (
+	xmlRoot = "response"
+	xmlItem = "item"
+)
+
+// encodeXML writes the value as an XML document.
+func encodeXML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	dec := stdjson.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err := writeXML(enc, dec, xmlRoot); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// writeXML writes the next JSON value as an element with the name.
+func writeXML(enc *xml.Encoder, dec *stdjson.Decoder, name string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch t := tok.(type) {
+	case stdjson.Delim:
+		for dec.More() {
+			child := xmlItem
+			if t == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child = key.(string)
+			}
+			if err := writeXML(enc, dec, child); err != nil {
+				return err
+			}
+		}
+
+		// Consume the closing delimiter.
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+
+	case string:
+		if err := enc.EncodeToken(xml.CharData(t)); err != nil {
+			return err
+		}
+
+	case stdjson.Number:
+		if err := enc.EncodeToken(xml.CharData(t.String())); err != nil {
+			return err
+		}
+
+	case bool:
+		text := "false"
+		if t {
+			text = "true"
+		}
+		if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// xmlName turns a JSON key into an element name by replacing the
+// characters names cannot hold.
+func xmlName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, key)
+
+	if name == "" || !(unicode.IsLetter(rune(name[0])) || name[0] == '_') {
+		name = "_" + name
+	}
+	return name
+}
+
+// decodeXML reads an XML document into the value. The document element
+// stands for the value and its child elements for the fields or items.
+func decodeXML(r io.Reader, v interface{}) error {
+	dec := xml.NewDecoder(r)
+
+	var stack []*node
+	var root *node
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := node{name: t.Name.Local}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, &n)
+			} else if root == nil {
+				root = &n
+			} else {
+				return errors.New("xml: more than one document element")
+			}
+			stack = append(stack, &n)
+
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			n.text = strings.TrimSpace(n.text)
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return errors.New("xml: no document element")
+	}
+	return decodeNode(root, v)
+}
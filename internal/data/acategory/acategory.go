@@ -3,11 +3,11 @@ package acategory
 import (
 	"context"
 	"database/sql"
-	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/internal/auth"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -26,12 +26,12 @@ var (
 
 //  ACategory manages the set of API's for article category access.
 type ACategory struct {
-	log *log.Logger
+	log *logger.Logger
 	db  *sqlx.DB
 }
 
 // New constructs a  arcicle category for api access.
-func New(log *log.Logger, db *sqlx.DB) ACategory {
+func New(log *logger.Logger, db *sqlx.DB) ACategory {
 	return ACategory{
 		log: log,
 		db:  db,
@@ -65,7 +65,7 @@ func (c ACategory) Create(ctx context.Context, traceID string, claims auth.Claim
 	VALUES
 		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
 
-	c.log.Printf("%s: %s: %s", traceID, "article-category.Create",
+	c.log.Debug("article-category.Create", logger.TraceID(traceID),
 		database.Log(q, cat.ID, cat.Title, cat.Slug, cat.Image, cat.Description, cat.MetaTitle, cat.MetaKeywords, cat.MetaDescription, cat.DateCreated, cat.DateUpdated),
 	)
 
@@ -126,7 +126,7 @@ func (c ACategory) Update(ctx context.Context, traceID string, claims auth.Claim
 	WHERE
 		category_id = $1`
 
-	c.log.Printf("%s: %s: %s", traceID, "carticle-ategory.Update",
+	c.log.Debug("carticle-ategory.Update", logger.TraceID(traceID),
 		database.Log(q, cat.ID, cat.Title, cat.Slug, cat.Image, cat.Description, cat.MetaTitle, cat.MetaKeywords, cat.MetaDescription, cat.DateUpdated),
 	)
 
@@ -152,7 +152,7 @@ func (c ACategory) Delete(ctx context.Context, traceID string, claims auth.Claim
 	WHERE
 		category_id = $1`
 
-	c.log.Printf("%s: %s: %s", traceID, "article-category.Delete",
+	c.log.Debug("article-category.Delete", logger.TraceID(traceID),
 		database.Log(q, categoryID),
 	)
 
@@ -178,7 +178,7 @@ func (c ACategory) QueryByID(ctx context.Context, traceID string, categoryID str
 	WHERE 
 		category_id = $1`
 
-	c.log.Printf("%s: %s: %s", traceID, "article-category.QueryByID",
+	c.log.Debug("article-category.QueryByID", logger.TraceID(traceID),
 		database.Log(q, categoryID),
 	)
 
@@ -204,7 +204,7 @@ func (c ACategory) QueryBySlug(ctx context.Context, traceID string, Slug string)
 	WHERE 
 		slug = $1`
 
-	c.log.Printf("%s: %s: %s", traceID, "article-category.QueryBySlug",
+	c.log.Debug("article-category.QueryBySlug", logger.TraceID(traceID),
 		database.Log(q, Slug),
 	)
 
@@ -230,7 +230,7 @@ func (c ACategory) Query(ctx context.Context, traceID string) ([]Info, error) {
 	ORDER BY
 		title`
 
-	c.log.Printf("%s: %s: %s", traceID, "article-categories.Query",
+	c.log.Debug("article-categories.Query", logger.TraceID(traceID),
 		database.Log(q),
 	)
 
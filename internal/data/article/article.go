@@ -3,11 +3,11 @@ package article
 import (
 	"context"
 	"database/sql"
-	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/internal/auth"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -26,12 +26,12 @@ var (
 
 // Article manages the set of API's for article access.
 type Article struct {
-	log *log.Logger
+	log *logger.Logger
 	db  *sqlx.DB
 }
 
 // New constructs a article for api access.
-func New(log *log.Logger, db *sqlx.DB) Article {
+func New(log *logger.Logger, db *sqlx.DB) Article {
 	return Article{
 		log: log,
 		db:  db,
@@ -66,7 +66,7 @@ func (a Article) Create(ctx context.Context, traceID string, claims auth.Claims,
 	VALUES
 		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
-	a.log.Printf("%s: %s: %s", traceID, "article.Create",
+	a.log.Debug("article.Create", logger.TraceID(traceID),
 		database.Log(q, art.ID, art.Title, art.Slug, art.CategoryID, art.Image, art.Description, art.MetaTitle, art.MetaKeywords, art.MetaDescription, art.DateCreated, art.DateUpdated),
 	)
 
@@ -131,7 +131,7 @@ func (a Article) Update(ctx context.Context, traceID string, claims auth.Claims,
 	WHERE
 		article_id = $1`
 
-	a.log.Printf("%s: %s: %s", traceID, "article.Update",
+	a.log.Debug("article.Update", logger.TraceID(traceID),
 		database.Log(q, art.ID, art.Title, art.Slug, art.CategoryID, art.Image, art.Description, art.MetaTitle, art.MetaKeywords, art.MetaDescription, art.DateUpdated),
 	)
 
@@ -157,7 +157,7 @@ func (a Article) Delete(ctx context.Context, traceID string, claims auth.Claims,
 	WHERE
 		article_id = $1`
 
-	a.log.Printf("%s: %s: %s", traceID, "article.Delete",
+	a.log.Debug("article.Delete", logger.TraceID(traceID),
 		database.Log(q, articleID),
 	)
 
@@ -183,7 +183,7 @@ func (a Article) QueryByID(ctx context.Context, traceID string, articleID string
 	WHERE 
 		article_id = $1`
 
-	a.log.Printf("%s: %s: %s", traceID, "article.QueryByID",
+	a.log.Debug("article.QueryByID", logger.TraceID(traceID),
 		database.Log(q, articleID),
 	)
 
@@ -209,7 +209,7 @@ func (a Article) QueryBySlug(ctx context.Context, traceID string, Slug string) (
 	WHERE 
 		slug = $1`
 
-	a.log.Printf("%s: %s: %s", traceID, "article.QueryBySlug",
+	a.log.Debug("article.QueryBySlug", logger.TraceID(traceID),
 		database.Log(q, Slug),
 	)
 
@@ -235,7 +235,7 @@ func (a Article) Query(ctx context.Context, traceID string) ([]Info, error) {
 	ORDER BY
 		title`
 
-	a.log.Printf("%s: %s: %s", traceID, "articles.Query",
+	a.log.Debug("articles.Query", logger.TraceID(traceID),
 		database.Log(q),
 	)
 
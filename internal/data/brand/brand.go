@@ -3,11 +3,11 @@ package brand
 import (
 	"context"
 	"database/sql"
-	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/internal/auth"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -26,12 +26,12 @@ var (
 
 // brand manages the set of API's for user access.
 type Brand struct {
-	log *log.Logger
+	log *logger.Logger
 	db  *sqlx.DB
 }
 
 // New constructs a brand for api access.
-func New(log *log.Logger, db *sqlx.DB) Brand {
+func New(log *logger.Logger, db *sqlx.DB) Brand {
 	return Brand{
 		log: log,
 		db:  db,
@@ -65,7 +65,7 @@ func (b Brand) Create(ctx context.Context, traceID string, claims auth.Claims, n
 	VALUES
 		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
 
-	b.log.Printf("%s: %s: %s", traceID, "brand.Create",
+	b.log.Debug("brand.Create", logger.TraceID(traceID),
 		database.Log(q, br.ID, br.Title, br.Slug, br.Image, br.Description, br.MetaTitle, br.MetaKeywords, br.MetaDescription, br.DateCreated, br.DateUpdated),
 	)
 
@@ -126,7 +126,7 @@ func (b Brand) Update(ctx context.Context, traceID string, claims auth.Claims, b
 	WHERE
 		brand_id = $1`
 
-	b.log.Printf("%s: %s: %s", traceID, "brand.Update",
+	b.log.Debug("brand.Update", logger.TraceID(traceID),
 		database.Log(q, br.ID, br.Title, br.Slug, br.Image, br.Description, br.MetaTitle, br.MetaKeywords, br.MetaDescription, br.DateUpdated),
 	)
 
@@ -152,7 +152,7 @@ func (b Brand) Delete(ctx context.Context, traceID string, claims auth.Claims, b
 	WHERE
 		brand_id = $1`
 
-	b.log.Printf("%s: %s: %s", traceID, "brand.Delete",
+	b.log.Debug("brand.Delete", logger.TraceID(traceID),
 		database.Log(q, brandID),
 	)
 
@@ -178,7 +178,7 @@ func (b Brand) QueryByID(ctx context.Context, traceID string, brandID string) (I
 	WHERE 
 		brand_id = $1`
 
-	b.log.Printf("%s: %s: %s", traceID, "brand.QueryByID",
+	b.log.Debug("brand.QueryByID", logger.TraceID(traceID),
 		database.Log(q, brandID),
 	)
 
@@ -204,7 +204,7 @@ func (b Brand) QueryBySlug(ctx context.Context, traceID string, Slug string) (In
 	WHERE 
 		slug = $1`
 
-	b.log.Printf("%s: %s: %s", traceID, "brand.QueryBySlug",
+	b.log.Debug("brand.QueryBySlug", logger.TraceID(traceID),
 		database.Log(q, Slug),
 	)
 
@@ -230,7 +230,7 @@ func (b Brand) Query(ctx context.Context, traceID string) ([]Info, error) {
 	ORDER BY
 		title`
 
-	b.log.Printf("%s: %s: %s", traceID, "brand.Query",
+	b.log.Debug("brand.Query", logger.TraceID(traceID),
 		database.Log(q),
 	)
 
@@ -0,0 +1,372 @@
+// Package bundle contains kits of products sold together for their own price.
+package bundle
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific Bundle is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+
+	// ErrUnknownProduct occurs when a component of a bundle does not exist.
+	ErrUnknownProduct = errors.New("bundle component does not exist")
+
+	// ErrSlugTaken occurs when the slug of a bundle is used by another one.
+	ErrSlugTaken = errors.New("slug is already taken")
+)
+
+// Bundle manages the set of API's for bundle access.
+type Bundle struct {
+	log *logger.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a Bundle for api access.
+func New(log *logger.Logger, db *sqlx.DB) Bundle {
+	return Bundle{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new bundle with its components into the database.
+func (b Bundle) Create(ctx context.Context, traceID string, claims auth.Claims, nb NewBundle, now time.Time) (Info, error) {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return Info{}, ErrForbidden
+	}
+
+	bnd := Info{
+		ID:          uuid.New().String(),
+		Title:       nb.Title,
+		Slug:        nb.Slug,
+		Price:       nb.Price,
+		Image:       nb.Image,
+		Description: nb.Description,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO bundles
+		(bundle_id, title, slug, price, image, description, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	b.log.Debug("bundle.Create", logger.TraceID(traceID),
+		database.Log(q, bnd.ID, bnd.Title, bnd.Slug, bnd.Price, bnd.Image, bnd.Description, bnd.DateCreated, bnd.DateUpdated),
+	)
+
+	tx, err := b.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Info{}, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, q, bnd.ID, bnd.Title, bnd.Slug, bnd.Price, bnd.Image, bnd.Description, bnd.DateCreated, bnd.DateUpdated); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return Info{}, ErrSlugTaken
+		}
+		return Info{}, errors.Wrap(err, "inserting bundle")
+	}
+
+	if err := b.setItems(ctx, traceID, tx, bnd.ID, nb.Items); err != nil {
+		return Info{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Info{}, errors.Wrap(err, "committing bundle")
+	}
+
+	return b.QueryByID(ctx, traceID, bnd.ID)
+}
+
+// Update modifies data about a bundle.
+func (b Bundle) Update(ctx context.Context, traceID string, claims auth.Claims, bundleID string, ub UpdateBundle, now time.Time) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+
+	bnd, err := b.QueryByID(ctx, traceID, bundleID)
+	if err != nil {
+		return err
+	}
+
+	if ub.Title != nil {
+		bnd.Title = *ub.Title
+	}
+	if ub.Slug != nil {
+		bnd.Slug = *ub.Slug
+	}
+	if ub.Price != nil {
+		bnd.Price = *ub.Price
+	}
+	if ub.Image != nil {
+		bnd.Image = *ub.Image
+	}
+	if ub.Description != nil {
+		bnd.Description = *ub.Description
+	}
+	bnd.DateUpdated = now
+
+	const q = `
+	UPDATE
+		bundles
+	SET
+		"title" = $2,
+		"slug" = $3,
+		"price" = $4,
+		"image" = $5,
+		"description" = $6,
+		"date_updated" = $7
+	WHERE
+		bundle_id = $1`
+
+	b.log.Debug("bundle.Update", logger.TraceID(traceID),
+		database.Log(q, bnd.ID, bnd.Title, bnd.Slug, bnd.Price, bnd.Image, bnd.Description, bnd.DateUpdated),
+	)
+
+	tx, err := b.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, q, bnd.ID, bnd.Title, bnd.Slug, bnd.Price, bnd.Image, bnd.Description, bnd.DateUpdated); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return ErrSlugTaken
+		}
+		return errors.Wrap(err, "updating bundle")
+	}
+
+	if ub.Items != nil {
+		if err := b.setItems(ctx, traceID, tx, bnd.ID, ub.Items); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a bundle from the database. Its components are not touched.
+func (b Bundle) Delete(ctx context.Context, traceID string, claims auth.Claims, bundleID string) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if _, err := uuid.Parse(bundleID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	DELETE FROM
+		bundles
+	WHERE
+		bundle_id = $1`
+
+	b.log.Debug("bundle.Delete", logger.TraceID(traceID),
+		database.Log(q, bundleID),
+	)
+
+	if _, err := b.db.ExecContext(ctx, q, bundleID); err != nil {
+		return errors.Wrapf(err, "deleting bundle %s", bundleID)
+	}
+
+	return nil
+}
+
+// Query retrieves the bundles with their components.
+func (b Bundle) Query(ctx context.Context, traceID string) ([]Info, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		bundles
+	ORDER BY
+		date_created`
+
+	b.log.Debug("bundle.Query", logger.TraceID(traceID),
+		database.Log(q),
+	)
+
+	bundles := []Info{}
+	if err := b.db.SelectContext(ctx, &bundles, q); err != nil {
+		return nil, errors.Wrap(err, "selecting bundles")
+	}
+
+	if err := b.loadItems(ctx, traceID, bundles); err != nil {
+		return nil, err
+	}
+
+	return bundles, nil
+}
+
+// QueryByID gets the specified bundle with its components.
+func (b Bundle) QueryByID(ctx context.Context, traceID string, bundleID string) (Info, error) {
+	if _, err := uuid.Parse(bundleID); err != nil {
+		return Info{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		bundles
+	WHERE
+		bundle_id = $1`
+
+	return b.query(ctx, traceID, "bundle.QueryByID", q, bundleID)
+}
+
+// QueryBySlug gets the specified bundle with its components.
+func (b Bundle) QueryBySlug(ctx context.Context, traceID string, slug string) (Info, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		bundles
+	WHERE
+		slug = $1`
+
+	return b.query(ctx, traceID, "bundle.QueryBySlug", q, slug)
+}
+
+// query gets a single bundle with its components.
+func (b Bundle) query(ctx context.Context, traceID string, name string, q string, arg string) (Info, error) {
+	b.log.Debug(name, logger.TraceID(traceID),
+		database.Log(q, arg),
+	)
+
+	var bnd Info
+	if err := b.db.GetContext(ctx, &bnd, q, arg); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting bundle %q", arg)
+	}
+
+	bundles := []Info{bnd}
+	if err := b.loadItems(ctx, traceID, bundles); err != nil {
+		return Info{}, err
+	}
+
+	return bundles[0], nil
+}
+
+// setItems replaces the components of a bundle.
+func (b Bundle) setItems(ctx context.Context, traceID string, tx sqlx.ExecerContext, bundleID string, items []NewItem) error {
+	const qd = `
+	DELETE FROM
+		bundle_items
+	WHERE
+		bundle_id = $1`
+
+	b.log.Debug("bundle.setItems", logger.TraceID(traceID),
+		database.Log(qd, bundleID),
+	)
+
+	if _, err := tx.ExecContext(ctx, qd, bundleID); err != nil {
+		return errors.Wrapf(err, "clearing items of bundle %s", bundleID)
+	}
+
+	const qi = `
+	INSERT INTO bundle_items
+		(bundle_id, product_id, qty)
+	VALUES
+		($1, $2, $3)
+	ON CONFLICT (bundle_id, product_id) DO UPDATE SET
+		qty = bundle_items.qty + EXCLUDED.qty`
+
+	for _, item := range items {
+		b.log.Debug("bundle.setItems", logger.TraceID(traceID),
+			database.Log(qi, bundleID, item.ProductID, item.Qty),
+		)
+
+		if _, err := tx.ExecContext(ctx, qi, bundleID, item.ProductID, item.Qty); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+				return ErrUnknownProduct
+			}
+			return errors.Wrapf(err, "inserting item %s of bundle %s", item.ProductID, bundleID)
+		}
+	}
+
+	return nil
+}
+
+// loadItems fills in the components of the bundles and derives their stock
+// and regular price. A bundle is in stock as many times as its scarcest
+// component allows.
+func (b Bundle) loadItems(ctx context.Context, traceID string, bundles []Info) error {
+	if len(bundles) == 0 {
+		return nil
+	}
+
+	ids := make(pq.StringArray, len(bundles))
+	for i, bnd := range bundles {
+		ids[i] = bnd.ID
+	}
+
+	const q = `
+	SELECT
+		bi.bundle_id, bi.product_id, bi.qty, p.title, COALESCE(p.category_id::text, '') AS category_id,
+		COALESCE(p.brand_id::text, '') AS brand_id, p.price, p.weight, p.stock,
+		p.availability, p.release_date, p.preorder_limit
+	FROM
+		bundle_items bi
+	JOIN
+		products p ON p.product_id = bi.product_id
+	WHERE
+		bi.bundle_id::text = ANY($1)
+	ORDER BY
+		p.title`
+
+	b.log.Debug("bundle.loadItems", logger.TraceID(traceID),
+		database.Log(q, ids),
+	)
+
+	items := []Item{}
+	if err := b.db.SelectContext(ctx, &items, q, ids); err != nil {
+		return errors.Wrap(err, "selecting bundle items")
+	}
+
+	for i := range bundles {
+		bnd := &bundles[i]
+		bnd.Items = []Item{}
+		bnd.Stock = 0
+		bnd.RegularPrice = 0
+
+		stock := math.MaxInt32
+		for _, item := range items {
+			if item.BundleID != bnd.ID {
+				continue
+			}
+			bnd.Items = append(bnd.Items, item)
+			bnd.RegularPrice += item.Price * float64(item.Qty)
+			if s := item.Stock / item.Qty; s < stock {
+				stock = s
+			}
+		}
+		if len(bnd.Items) > 0 {
+			bnd.Stock = stock
+		}
+		bnd.RegularPrice = math.Round(bnd.RegularPrice*100) / 100
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package bundle_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/bundle"
+	"github.com/igorbelousov/shop-backend/internal/data/product"
+	"github.com/igorbelousov/shop-backend/internal/tests"
+	"github.com/pkg/errors"
+)
+
+func TestBundle(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+	testID := 0
+	b := bundle.New(log, db)
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+	productID := "9097a8f9-c7c0-4e88-81da-72ec34a1dc79"
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "Shop backend",
+			Subject:   "00000000-0000-0000-0000-000000000000",
+			Audience:  "SHOP",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		Roles: []string{auth.RoleAdmin},
+	}
+
+	const q = `UPDATE products SET stock = 5 WHERE product_id = $1`
+	if _, err := db.ExecContext(ctx, q, productID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to stock the product : %s.", tests.Failed, testID, err)
+	}
+
+	nb := bundle.NewBundle{
+		Title: "Kit",
+		Slug:  "kit",
+		Price: 6000,
+		Items: []bundle.NewItem{{ProductID: productID, Qty: 2}},
+	}
+
+	bnd, err := b.Create(ctx, traceID, claims, nb, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create bundle : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to create bundle.", tests.Success, testID)
+
+	if bnd.Stock != 2 || bnd.RegularPrice != 7070.46 {
+		t.Fatalf("\t%s\tTest %d:\tShould derive stock and regular price from the components : %+v.", tests.Failed, testID, bnd)
+	}
+	t.Logf("\t%s\tTest %d:\tShould derive stock and regular price from the components.", tests.Success, testID)
+
+	comp := bnd.Items[0]
+	if err := comp.Available(comp.Qty*2, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to buy the component of two bundles : %s.", tests.Failed, testID, err)
+	}
+	if err := comp.Available(comp.Qty*3, now); errors.Cause(err) != product.ErrUnavailable {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to buy the component of three bundles : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould check the stock of the components.", tests.Success, testID)
+
+	const backorder = `UPDATE products SET availability = 'backorder' WHERE product_id = $1`
+	if _, err := db.ExecContext(ctx, backorder, productID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to backorder the product : %s.", tests.Failed, testID, err)
+	}
+	backordered, err := b.QueryByID(ctx, traceID, bnd.ID)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve bundle : %s.", tests.Failed, testID, err)
+	}
+	comp = backordered.Items[0]
+	if err := comp.Available(comp.Qty*3, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to backorder the component : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould follow the availability of the components.", tests.Success, testID)
+
+	if _, err := b.Create(ctx, traceID, claims, nb, now); err != bundle.ErrSlugTaken {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to reuse the slug of a bundle : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to reuse the slug of a bundle.", tests.Success, testID)
+
+	if err := product.New(log, db).Delete(ctx, traceID, claims, productID); err != product.ErrInBundle {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to delete a bundled product : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to delete a bundled product.", tests.Success, testID)
+
+	nb.Slug = "broken-kit"
+	nb.Items = []bundle.NewItem{{ProductID: "45b5fbd3-755f-4379-8f07-a58d4a30fa2f", Qty: 1}}
+	if _, err := b.Create(ctx, traceID, claims, nb, now); err != bundle.ErrUnknownProduct {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to bundle an unknown product : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to bundle an unknown product.", tests.Success, testID)
+
+	if err := b.Delete(ctx, traceID, claims, bnd.ID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to delete bundle : %s.", tests.Failed, testID, err)
+	}
+	if _, err := b.QueryBySlug(ctx, traceID, "kit"); err != bundle.ErrNotFound {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted bundle : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to delete bundle.", tests.Success, testID)
+}
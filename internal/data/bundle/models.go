@@ -0,0 +1,82 @@
+package bundle
+
+import (
+	"time"
+
+	"github.com/igorbelousov/shop-backend/internal/data/product"
+)
+
+// Info represents a Bundle of products sold together for its own price.
+// Stock and RegularPrice are derived from the components.
+type Info struct {
+	ID           string    `db:"bundle_id" json:"id"`
+	Title        string    `db:"title" json:"title"`
+	Slug         string    `db:"slug" json:"slug"`
+	Price        float64   `db:"price" json:"price"`
+	Image        string    `db:"image" json:"image"`
+	Description  string    `db:"description" json:"description"`
+	DateCreated  time.Time `db:"date_created" json:"date_created"`
+	DateUpdated  time.Time `db:"date_updated" json:"date_updated"`
+	Items        []Item    `db:"-" json:"items"`
+	Stock        int       `db:"-" json:"stock"`
+	RegularPrice float64   `db:"-" json:"regular_price"`
+}
+
+// Item is a component product of a Bundle with the quantity it comes in.
+// The availability of the product decides whether bundles can be bought
+// beyond its stock.
+type Item struct {
+	BundleID   string  `db:"bundle_id" json:"-"`
+	ProductID  string  `db:"product_id" json:"product_id"`
+	Qty        int     `db:"qty" json:"qty"`
+	Title      string  `db:"title" json:"title"`
+	CategoryID string  `db:"category_id" json:"category_id"`
+	BrandID    string  `db:"brand_id" json:"brand_id"`
+	Price      float64 `db:"price" json:"price"`
+	Weight     float64 `db:"weight" json:"weight"`
+	Stock      int     `db:"stock" json:"stock"`
+
+	Availability  string     `db:"availability" json:"availability"`
+	ReleaseDate   *time.Time `db:"release_date" json:"release_date"`
+	PreorderLimit int        `db:"preorder_limit" json:"preorder_limit"`
+}
+
+// Available checks the component can be bought in the quantity at the
+// time, following the rules of its product.
+func (i Item) Available(qty int, now time.Time) error {
+	p := product.Info{
+		Title:         i.Title,
+		Stock:         i.Stock,
+		Availability:  i.Availability,
+		ReleaseDate:   i.ReleaseDate,
+		PreorderLimit: i.PreorderLimit,
+	}
+	return p.Available(qty, now)
+}
+
+// NewBundle contains information needed to create a new Bundle.
+type NewBundle struct {
+	Title       string    `json:"title" validate:"required"`
+	Slug        string    `json:"slug" validate:"required"`
+	Price       float64   `json:"price" validate:"gte=0"`
+	Image       string    `json:"image"`
+	Description string    `json:"description"`
+	Items       []NewItem `json:"items" validate:"required,min=1,dive"`
+}
+
+// NewItem is a component of a new Bundle.
+type NewItem struct {
+	ProductID string `json:"product_id" validate:"required,uuid"`
+	Qty       int    `json:"qty" validate:"gt=0"`
+}
+
+// UpdateBundle defines what information may be provided to modify an
+// existing Bundle. Items replace all components when set.
+type UpdateBundle struct {
+	Title       *string   `json:"title"`
+	Slug        *string   `json:"slug"`
+	Price       *float64  `json:"price" validate:"omitempty,gte=0"`
+	Image       *string   `json:"image"`
+	Description *string   `json:"description"`
+	Items       []NewItem `json:"items" validate:"omitempty,min=1,dive"`
+}
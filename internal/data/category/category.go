@@ -3,11 +3,11 @@ package category
 import (
 	"context"
 	"database/sql"
-	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/internal/auth"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -26,12 +26,12 @@ var (
 
 // Category manages the set of API's for user access.
 type Category struct {
-	log *log.Logger
+	log *logger.Logger
 	db  *sqlx.DB
 }
 
 // New constructs a Category for api access.
-func New(log *log.Logger, db *sqlx.DB) Category {
+func New(log *logger.Logger, db *sqlx.DB) Category {
 	return Category{
 		log: log,
 		db:  db,
@@ -56,21 +56,22 @@ func (c Category) Create(ctx context.Context, traceID string, claims auth.Claims
 		MetaTitle:       nc.MetaTitle,
 		MetaKeywords:    nc.MetaKeywords,
 		MetaDescription: nc.MetaDescription,
+		TaxClassID:      nc.TaxClassID,
 		DateCreated:     now.UTC(),
 		DateUpdated:     now.UTC(),
 	}
 
 	const q = `
 	INSERT INTO categories
-		(category_id, title, slug, parrent_id, image, description, meta_title, meta_keywords, meta_description, date_created, date_updated)
+		(category_id, title, slug, parrent_id, image, description, meta_title, meta_keywords, meta_description, tax_class_id, date_created, date_updated)
 	VALUES
-		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 
-	c.log.Printf("%s: %s: %s", traceID, "category.Create",
-		database.Log(q, cat.ID, cat.Title, cat.Slug, cat.ParrentID, cat.Image, cat.Description, cat.MetaTitle, cat.MetaKeywords, cat.MetaDescription, cat.DateCreated, cat.DateUpdated),
+	c.log.Debug("category.Create", logger.TraceID(traceID),
+		database.Log(q, cat.ID, cat.Title, cat.Slug, cat.ParrentID, cat.Image, cat.Description, cat.MetaTitle, cat.MetaKeywords, cat.MetaDescription, cat.TaxClassID, cat.DateCreated, cat.DateUpdated),
 	)
 
-	if _, err := c.db.ExecContext(ctx, q, cat.ID, cat.Title, cat.Slug, cat.ParrentID, cat.Image, cat.Description, cat.MetaTitle, cat.MetaKeywords, cat.MetaDescription, cat.DateCreated, cat.DateUpdated); err != nil {
+	if _, err := c.db.ExecContext(ctx, q, cat.ID, cat.Title, cat.Slug, cat.ParrentID, cat.Image, cat.Description, cat.MetaTitle, cat.MetaKeywords, cat.MetaDescription, cat.TaxClassID, cat.DateCreated, cat.DateUpdated); err != nil {
 		return Info{}, errors.Wrap(err, "inserting category")
 	}
 
@@ -113,6 +114,9 @@ func (c Category) Update(ctx context.Context, traceID string, claims auth.Claims
 	if uc.MetaDescription != nil {
 		cat.MetaDescription = *uc.MetaDescription
 	}
+	if uc.TaxClassID != nil {
+		cat.TaxClassID = uc.TaxClassID
+	}
 	cat.DateUpdated = now
 
 	const q = `
@@ -127,15 +131,16 @@ func (c Category) Update(ctx context.Context, traceID string, claims auth.Claims
 		"meta_title" = $7, 
 		"meta_keywords" = $8, 
 		"meta_description" = $9,
-		"date_updated" = $10
+		"tax_class_id" = $10,
+		"date_updated" = $11
 	WHERE
 		category_id = $1`
 
-	c.log.Printf("%s: %s: %s", traceID, "category.Update",
-		database.Log(q, cat.ID, cat.Title, cat.Slug, cat.ParrentID, cat.Image, cat.Description, cat.MetaTitle, cat.MetaKeywords, cat.MetaDescription, cat.DateUpdated),
+	c.log.Debug("category.Update", logger.TraceID(traceID),
+		database.Log(q, cat.ID, cat.Title, cat.Slug, cat.ParrentID, cat.Image, cat.Description, cat.MetaTitle, cat.MetaKeywords, cat.MetaDescription, cat.TaxClassID, cat.DateUpdated),
 	)
 
-	if _, err = c.db.ExecContext(ctx, q, categoryID, cat.Title, cat.Slug, cat.ParrentID, cat.Image, cat.Description, cat.MetaTitle, cat.MetaKeywords, cat.MetaDescription, cat.DateUpdated); err != nil {
+	if _, err = c.db.ExecContext(ctx, q, categoryID, cat.Title, cat.Slug, cat.ParrentID, cat.Image, cat.Description, cat.MetaTitle, cat.MetaKeywords, cat.MetaDescription, cat.TaxClassID, cat.DateUpdated); err != nil {
 		return errors.Wrap(err, "updating category")
 	}
 
@@ -157,7 +162,7 @@ func (c Category) Delete(ctx context.Context, traceID string, claims auth.Claims
 	WHERE
 		category_id = $1`
 
-	c.log.Printf("%s: %s: %s", traceID, "category.Delete",
+	c.log.Debug("category.Delete", logger.TraceID(traceID),
 		database.Log(q, categoryID),
 	)
 
@@ -183,7 +188,7 @@ func (c Category) QueryByID(ctx context.Context, traceID string, categoryID stri
 	WHERE 
 		category_id = $1`
 
-	c.log.Printf("%s: %s: %s", traceID, "category.QueryByID",
+	c.log.Debug("category.QueryByID", logger.TraceID(traceID),
 		database.Log(q, categoryID),
 	)
 
@@ -209,7 +214,7 @@ func (c Category) QueryBySlug(ctx context.Context, traceID string, Slug string)
 	WHERE 
 		slug = $1`
 
-	c.log.Printf("%s: %s: %s", traceID, "category.QueryBySlug",
+	c.log.Debug("category.QueryBySlug", logger.TraceID(traceID),
 		database.Log(q, Slug),
 	)
 
@@ -235,7 +240,7 @@ func (c Category) Query(ctx context.Context, traceID string) ([]Info, error) {
 	ORDER BY
 		title`
 
-	c.log.Printf("%s: %s: %s", traceID, "categories.Query",
+	c.log.Debug("categories.Query", logger.TraceID(traceID),
 		database.Log(q),
 	)
 
@@ -15,20 +15,22 @@ type Info struct {
 	MetaTitle       string    `db:"meta_title" json:"meta_title"`
 	MetaKeywords    string    `db:"meta_keywords" json:"meta_keywords"`
 	MetaDescription string    `db:"meta_description" json:"meta_description"`
+	TaxClassID      *string   `db:"tax_class_id" json:"tax_class_id"`
 	DateCreated     time.Time `db:"date_created" json:"date_created"`
 	DateUpdated     time.Time `db:"date_updated" json:"date_updated"`
 }
 
 // NewCategory contains information needed to create a new Category.
 type NewCategory struct {
-	Title           string `json:"title"  validate:"required"`
-	Slug            string `json:"slug"  validate:"required"`
-	ParrentID       string `json:"parrent_id"`
-	Description     string `json:"description"`
-	Image           string `json:"image"`
-	MetaTitle       string `json:"meta_title"`
-	MetaKeywords    string `json:"meta_keywords"`
-	MetaDescription string `json:"meta_description"`
+	Title           string  `json:"title"  validate:"required"`
+	Slug            string  `json:"slug"  validate:"required"`
+	ParrentID       string  `json:"parrent_id"`
+	Description     string  `json:"description"`
+	Image           string  `json:"image"`
+	MetaTitle       string  `json:"meta_title"`
+	MetaKeywords    string  `json:"meta_keywords"`
+	MetaDescription string  `json:"meta_description"`
+	TaxClassID      *string `json:"tax_class_id" validate:"omitempty,uuid"`
 }
 
 // UpdateCategory in database
@@ -41,4 +43,5 @@ type UpdateCategory struct {
 	MetaTitle       *string `json:"meta_title"`
 	MetaKeywords    *string `json:"meta_keywords"`
 	MetaDescription *string `json:"meta_description"`
+	TaxClassID      *string `json:"tax_class_id" validate:"omitempty,uuid"`
 }
@@ -0,0 +1,343 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidAttribute occurs when an attribute definition or value does not
+// fit its type, or the attribute is not defined for the product's category.
+var ErrInvalidAttribute = errors.New("attribute is not valid")
+
+// CreateAttribute defines a new attribute for the products of a category.
+func (p Product) CreateAttribute(ctx context.Context, traceID string, claims auth.Claims, na NewAttribute, now time.Time) (Attribute, error) {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return Attribute{}, ErrForbidden
+	}
+
+	if na.Type == AttributeEnum && len(na.Options) == 0 {
+		return Attribute{}, errors.Wrap(ErrInvalidAttribute, "enum needs options")
+	}
+	if na.Type != AttributeEnum && len(na.Options) != 0 {
+		return Attribute{}, errors.Wrapf(ErrInvalidAttribute, "%s does not take options", na.Type)
+	}
+
+	a := Attribute{
+		ID:          uuid.New().String(),
+		CategoryID:  na.CategoryID,
+		Code:        strings.ToLower(na.Code),
+		Title:       na.Title,
+		Type:        na.Type,
+		Unit:        na.Unit,
+		Options:     pq.StringArray{},
+		Filterable:  na.Filterable,
+		DateCreated: now.UTC(),
+	}
+	if na.Options != nil {
+		a.Options = na.Options
+	}
+
+	const q = `
+	INSERT INTO attributes
+		(attribute_id, category_id, code, title, type, unit, options, filterable, date_created)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	p.log.Debug("product.CreateAttribute", logger.TraceID(traceID),
+		database.Log(q, a.ID, a.CategoryID, a.Code, a.Title, a.Type, a.Unit, a.Options, a.Filterable, a.DateCreated),
+	)
+
+	if _, err := p.db.ExecContext(ctx, q, a.ID, a.CategoryID, a.Code, a.Title, a.Type, a.Unit, a.Options, a.Filterable, a.DateCreated); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return Attribute{}, errors.Wrapf(ErrInvalidAttribute, "code %q already defined", a.Code)
+		}
+		return Attribute{}, errors.Wrap(err, "inserting attribute")
+	}
+
+	return a, nil
+}
+
+// DeleteAttribute removes an attribute definition and its values from the
+// database.
+func (p Product) DeleteAttribute(ctx context.Context, traceID string, claims auth.Claims, attributeID string) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if _, err := uuid.Parse(attributeID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	DELETE FROM
+		attributes
+	WHERE
+		attribute_id = $1`
+
+	p.log.Debug("product.DeleteAttribute", logger.TraceID(traceID),
+		database.Log(q, attributeID),
+	)
+
+	if _, err := p.db.ExecContext(ctx, q, attributeID); err != nil {
+		return errors.Wrapf(err, "deleting attribute %s", attributeID)
+	}
+
+	return nil
+}
+
+// QueryAttributes retrieves the attributes defined for a category.
+func (p Product) QueryAttributes(ctx context.Context, traceID string, categoryID string) ([]Attribute, error) {
+	if _, err := uuid.Parse(categoryID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		attributes
+	WHERE
+		category_id = $1
+	ORDER BY
+		title`
+
+	p.log.Debug("product.QueryAttributes", logger.TraceID(traceID),
+		database.Log(q, categoryID),
+	)
+
+	attrs := []Attribute{}
+	if err := p.db.SelectContext(ctx, &attrs, q, categoryID); err != nil {
+		return nil, errors.Wrapf(err, "selecting attributes of category %q", categoryID)
+	}
+
+	return attrs, nil
+}
+
+// SetAttributes replaces the attribute values of a product. Values are
+// checked against the attributes of the product's category, each attribute
+// can be set once.
+func (p Product) SetAttributes(ctx context.Context, traceID string, claims auth.Claims, productID string, values []NewAttributeValue) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+
+	prod, err := p.queryByID(ctx, traceID, productID)
+	if err != nil {
+		return err
+	}
+
+	defs := []Attribute{}
+	if prod.CategoryID != "" {
+		if defs, err = p.QueryAttributes(ctx, traceID, prod.CategoryID); err != nil {
+			return err
+		}
+	}
+	byCode := make(map[string]Attribute, len(defs))
+	for _, a := range defs {
+		byCode[a.Code] = a
+	}
+
+	type row struct {
+		attributeID string
+		value       string
+		number      *float64
+	}
+	rows := make([]row, 0, len(values))
+	set := make(map[string]bool, len(values))
+	for _, nv := range values {
+		code := strings.ToLower(nv.Code)
+		a, ok := byCode[code]
+		if !ok {
+			return errors.Wrapf(ErrInvalidAttribute, "%q is not defined for the category", nv.Code)
+		}
+		if set[code] {
+			return errors.Wrapf(ErrInvalidAttribute, "%q is set more than once", nv.Code)
+		}
+		set[code] = true
+
+		raw, number, err := a.parse(nv.Value)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row{attributeID: a.ID, value: raw, number: number})
+	}
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	const qd = `
+	DELETE FROM
+		product_attributes
+	WHERE
+		product_id = $1`
+
+	p.log.Debug("product.SetAttributes", logger.TraceID(traceID),
+		database.Log(qd, prod.ID),
+	)
+
+	if _, err := tx.ExecContext(ctx, qd, prod.ID); err != nil {
+		return errors.Wrapf(err, "clearing attributes of product %s", prod.ID)
+	}
+
+	const qi = `
+	INSERT INTO product_attributes
+		(product_id, attribute_id, value, number)
+	VALUES
+		($1, $2, $3, $4)`
+
+	for _, r := range rows {
+		p.log.Debug("product.SetAttributes", logger.TraceID(traceID),
+			database.Log(qi, prod.ID, r.attributeID, r.value, r.number),
+		)
+
+		if _, err := tx.ExecContext(ctx, qi, prod.ID, r.attributeID, r.value, r.number); err != nil {
+			return errors.Wrapf(err, "inserting attribute %s of product %s", r.attributeID, prod.ID)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// parse checks a value against the type of the attribute and returns its
+// stored text form, and the number for number attributes.
+func (a Attribute) parse(v interface{}) (string, *float64, error) {
+	invalid := errors.Wrapf(ErrInvalidAttribute, "%q needs a %s value, got %v", a.Code, a.Type, v)
+
+	switch a.Type {
+	case AttributeNumber:
+		n, ok := v.(float64)
+		if !ok {
+			return "", nil, invalid
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), &n, nil
+
+	case AttributeBoolean:
+		b, ok := v.(bool)
+		if !ok {
+			return "", nil, invalid
+		}
+		return strconv.FormatBool(b), nil, nil
+
+	case AttributeEnum:
+		s, ok := v.(string)
+		if !ok {
+			return "", nil, invalid
+		}
+		for _, o := range a.Options {
+			if o == s {
+				return s, nil, nil
+			}
+		}
+		return "", nil, errors.Wrapf(ErrInvalidAttribute, "%q must be one of %s", a.Code, strings.Join(a.Options, ", "))
+
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return "", nil, invalid
+		}
+		return s, nil, nil
+	}
+}
+
+// loadAttributes fills in the attribute values of the products.
+func (p Product) loadAttributes(ctx context.Context, traceID string, prods []Info) error {
+	if len(prods) == 0 {
+		return nil
+	}
+
+	ids := make(pq.StringArray, len(prods))
+	for i, prod := range prods {
+		ids[i] = prod.ID
+	}
+
+	const q = `
+	SELECT
+		a.attribute_id, pa.product_id, a.code, a.title, a.type, a.unit, pa.value
+	FROM
+		product_attributes pa
+	JOIN
+		attributes a ON a.attribute_id = pa.attribute_id
+	WHERE
+		pa.product_id::text = ANY($1)
+	ORDER BY
+		a.title`
+
+	p.log.Debug("product.loadAttributes", logger.TraceID(traceID),
+		database.Log(q, ids),
+	)
+
+	values := []AttributeValue{}
+	if err := p.db.SelectContext(ctx, &values, q, ids); err != nil {
+		return errors.Wrap(err, "selecting product attributes")
+	}
+
+	byProduct := make(map[string][]AttributeValue)
+	for _, av := range values {
+		switch av.Type {
+		case AttributeNumber:
+			av.Value, _ = strconv.ParseFloat(av.Raw, 64)
+		case AttributeBoolean:
+			av.Value, _ = strconv.ParseBool(av.Raw)
+		default:
+			av.Value = av.Raw
+		}
+		byProduct[av.ProductID] = append(byProduct[av.ProductID], av)
+	}
+
+	for i := range prods {
+		prods[i].Attributes = byProduct[prods[i].ID]
+		if prods[i].Attributes == nil {
+			prods[i].Attributes = []AttributeValue{}
+		}
+	}
+
+	return nil
+}
+
+// where builds the conditions of a product listing for the filter. Each
+// attribute filter is matched by its own EXISTS so several filters narrow
+// the listing down together.
+func (f Filter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.CategoryID != "" {
+		conds = append(conds, "category_id::text = "+arg(f.CategoryID))
+	}
+
+	for _, af := range f.Attributes {
+		cond := `EXISTS (SELECT 1 FROM product_attributes pa JOIN attributes a ON a.attribute_id = pa.attribute_id
+		WHERE pa.product_id = products.product_id AND a.filterable AND a.code = ` + arg(strings.ToLower(af.Code))
+		if len(af.Values) > 0 {
+			cond += " AND pa.value = ANY(" + arg(pq.StringArray(af.Values)) + ")"
+		}
+		if af.Min != nil {
+			cond += " AND pa.number >= " + arg(*af.Min)
+		}
+		if af.Max != nil {
+			cond += " AND pa.number <= " + arg(*af.Max)
+		}
+		conds = append(conds, cond+")")
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE\n\t\t" + strings.Join(conds, "\n\t\tAND "), args
+}
@@ -2,6 +2,8 @@ package product
 
 import (
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // Info represents an individual Product.
@@ -19,8 +21,28 @@ type Info struct {
 	MetaTitle        string    `db:"meta_title" json:"meta_title"`
 	MetaKeywords     string    `db:"meta_keywords" json:"meta_keywords"`
 	MetaDescription  string    `db:"meta_description" json:"meta_description"`
+	Weight           float64   `db:"weight" json:"weight"` // kilograms
+	Length           float64   `db:"length" json:"length"` // centimetres
+	Width            float64   `db:"width" json:"width"`   // centimetres
+	Height           float64   `db:"height" json:"height"` // centimetres
+	TaxClassID       *string   `db:"tax_class_id" json:"tax_class_id"`
+	Stock            int       `db:"stock" json:"stock"`
+	Rating           float64   `db:"rating" json:"rating"`             // average of approved reviews
+	RatingCount      int       `db:"rating_count" json:"rating_count"` // number of approved reviews
 	DateCreated      time.Time `db:"date_created" json:"date_created"`
 	DateUpdated      time.Time `db:"date_updated" json:"date_updated"`
+
+	// Availability decides whether the product can be bought without stock.
+	// PreorderLimit caps the quantity per cart, 0 leaves it unlimited.
+	Availability  string     `db:"availability" json:"availability"`
+	ReleaseDate   *time.Time `db:"release_date" json:"release_date"`
+	PreorderLimit int        `db:"preorder_limit" json:"preorder_limit"`
+
+	// SaleEndsAt is set when Price comes from an active price schedule.
+	SaleEndsAt *time.Time `db:"-" json:"sale_ends_at,omitempty"`
+
+	// Attributes are the specification values of the product.
+	Attributes []AttributeValue `db:"-" json:"attributes"`
 }
 
 // NewProduct contains information needed to create a new Product.
@@ -37,6 +59,16 @@ type NewProduct struct {
 	MetaTitle        string  `json:"meta_title"`
 	MetaKeywords     string  `json:"meta_keywords"`
 	MetaDescription  string  `json:"meta_description"`
+	Weight           float64 `json:"weight" validate:"gte=0"`
+	Length           float64 `json:"length" validate:"gte=0"`
+	Width            float64 `json:"width" validate:"gte=0"`
+	Height           float64 `json:"height" validate:"gte=0"`
+	TaxClassID       *string `json:"tax_class_id" validate:"omitempty,uuid"`
+	Stock            int     `json:"stock" validate:"gte=0"`
+
+	Availability  string     `json:"availability" validate:"omitempty,oneof=in_stock backorder preorder"`
+	ReleaseDate   *time.Time `json:"release_date"`
+	PreorderLimit int        `json:"preorder_limit" validate:"gte=0"`
 }
 
 // UpdateProduct in database
@@ -53,4 +85,153 @@ type UpdateProduct struct {
 	MetaTitle        *string  `json:"meta_title"`
 	MetaKeywords     *string  `json:"meta_keywords"`
 	MetaDescription  *string  `json:"meta_description"`
+	Weight           *float64 `json:"weight" validate:"omitempty,gte=0"`
+	Length           *float64 `json:"length" validate:"omitempty,gte=0"`
+	Width            *float64 `json:"width" validate:"omitempty,gte=0"`
+	Height           *float64 `json:"height" validate:"omitempty,gte=0"`
+	TaxClassID       *string  `json:"tax_class_id" validate:"omitempty,uuid"`
+	Stock            *int     `json:"stock" validate:"omitempty,gte=0"`
+
+	Availability  *string    `json:"availability" validate:"omitempty,oneof=in_stock backorder preorder"`
+	ReleaseDate   *time.Time `json:"release_date"`
+	PreorderLimit *int       `json:"preorder_limit" validate:"omitempty,gte=0"`
+}
+
+// Schedule is a sale price for a single product or a discount for every
+// product of a category, active from StartsAt until EndsAt.
+type Schedule struct {
+	ID          string     `db:"schedule_id" json:"id"`
+	ProductID   *string    `db:"product_id" json:"product_id"`
+	CategoryID  *string    `db:"category_id" json:"category_id"`
+	Price       float64    `db:"price" json:"price"`
+	Percent     float64    `db:"percent" json:"percent"`
+	StartsAt    time.Time  `db:"starts_at" json:"starts_at"`
+	EndsAt      *time.Time `db:"ends_at" json:"ends_at"`
+	DateCreated time.Time  `db:"date_created" json:"date_created"`
+}
+
+// NewSchedule contains information needed to schedule a price change. Either
+// ProductID or CategoryID must be set. A fixed Price can only be scheduled
+// for a product, Percent is taken off the regular price otherwise.
+type NewSchedule struct {
+	ProductID  *string    `json:"product_id" validate:"omitempty,uuid"`
+	CategoryID *string    `json:"category_id" validate:"omitempty,uuid"`
+	Price      float64    `json:"price" validate:"gte=0"`
+	Percent    float64    `json:"percent" validate:"gte=0,lte=100"`
+	StartsAt   time.Time  `json:"starts_at" validate:"required"`
+	EndsAt     *time.Time `json:"ends_at"`
+}
+
+// PricePoint is a price a product was sold at from From until To.
+type PricePoint struct {
+	Price    float64    `json:"price"`
+	OldPrice float64    `json:"old_price"`
+	From     time.Time  `json:"from"`
+	To       *time.Time `json:"to"`
+	Sale     bool       `json:"sale"`
+}
+
+// These are the availability modes of a product. In stock products can only
+// be bought while there is stock, backorder products also without it, and
+// preorder products before their release date.
+const (
+	AvailabilityInStock   = "in_stock"
+	AvailabilityBackorder = "backorder"
+	AvailabilityPreorder  = "preorder"
+)
+
+// These are the types of product attributes.
+const (
+	AttributeText    = "text"
+	AttributeNumber  = "number"
+	AttributeEnum    = "enum"
+	AttributeBoolean = "boolean"
+)
+
+// Attribute is the definition of a specification shared by the products of
+// a category, like the screen size of laptops.
+type Attribute struct {
+	ID          string         `db:"attribute_id" json:"id"`
+	CategoryID  string         `db:"category_id" json:"category_id"`
+	Code        string         `db:"code" json:"code"`
+	Title       string         `db:"title" json:"title"`
+	Type        string         `db:"type" json:"type"`
+	Unit        string         `db:"unit" json:"unit"`
+	Options     pq.StringArray `db:"options" json:"options"`
+	Filterable  bool           `db:"filterable" json:"filterable"`
+	DateCreated time.Time      `db:"date_created" json:"date_created"`
+}
+
+// NewAttribute contains information needed to define a new Attribute. Unit
+// only applies to numbers, Options lists the allowed values of an enum.
+type NewAttribute struct {
+	CategoryID string   `json:"category_id" validate:"required,uuid"`
+	Code       string   `json:"code" validate:"required,max=64"`
+	Title      string   `json:"title" validate:"required"`
+	Type       string   `json:"type" validate:"required,oneof=text number enum boolean"`
+	Unit       string   `json:"unit"`
+	Options    []string `json:"options"`
+	Filterable bool     `json:"filterable"`
+}
+
+// AttributeValue is the value of an Attribute for a single product. Value
+// holds a string, a float64 or a bool depending on the Type.
+type AttributeValue struct {
+	AttributeID string      `db:"attribute_id" json:"attribute_id"`
+	ProductID   string      `db:"product_id" json:"-"`
+	Code        string      `db:"code" json:"code"`
+	Title       string      `db:"title" json:"title"`
+	Type        string      `db:"type" json:"type"`
+	Unit        string      `db:"unit" json:"unit"`
+	Raw         string      `db:"value" json:"-"`
+	Value       interface{} `db:"-" json:"value"`
+}
+
+// NewAttributeValue sets the value of an attribute of a product, identified
+// by the attribute code. Value must fit the type of the attribute.
+type NewAttributeValue struct {
+	Code  string      `json:"code" validate:"required"`
+	Value interface{} `json:"value"`
+}
+
+// Filter narrows a product listing down to a category and attribute values.
+type Filter struct {
+	CategoryID string
+	Attributes []AttributeFilter
+}
+
+// AttributeFilter matches products whose attribute equals any of Values or,
+// for numbers, lies between Min and Max.
+type AttributeFilter struct {
+	Code   string
+	Values []string
+	Min    *float64
+	Max    *float64
+}
+
+// These are the kinds of curated product relations.
+const (
+	RelationRelated   = "related"
+	RelationCrossSell = "cross_sell"
+	RelationUpsell    = "upsell"
+	RelationAccessory = "accessory"
+)
+
+// NewRelation contains information needed to relate a product to another
+// one. Lower positions are shown first.
+type NewRelation struct {
+	RelatedID string `json:"related_id" validate:"required,uuid"`
+	Type      string `json:"type" validate:"required,oneof=related cross_sell upsell accessory"`
+	Position  int    `json:"position"`
+}
+
+// Relations are the products shown next to a product, grouped by kind.
+// Related falls back to products of the same category and brand when none
+// were curated, Automatic is set when it did.
+type Relations struct {
+	Related   []Info `json:"related"`
+	CrossSell []Info `json:"cross_sell"`
+	Upsell    []Info `json:"upsell"`
+	Accessory []Info `json:"accessory"`
+	Automatic bool   `json:"automatic"`
 }
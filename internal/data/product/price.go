@@ -0,0 +1,313 @@
+package product
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidSchedule occurs when a price schedule has no target, both
+	// targets, neither a price nor a percent or an empty time window.
+	ErrInvalidSchedule = errors.New("schedule needs exactly one of product or category, a price or percent and a valid time window")
+
+	// ErrSalePrice occurs when a schedule sets a product price which is not
+	// below its regular price.
+	ErrSalePrice = errors.New("sale price must be below the regular price")
+)
+
+// CreateSchedule inserts a new price schedule into the database.
+func (p Product) CreateSchedule(ctx context.Context, traceID string, claims auth.Claims, ns NewSchedule, now time.Time) (Schedule, error) {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return Schedule{}, ErrForbidden
+	}
+
+	if (ns.ProductID == nil) == (ns.CategoryID == nil) {
+		return Schedule{}, ErrInvalidSchedule
+	}
+	if ns.CategoryID != nil && ns.Price != 0 {
+		return Schedule{}, ErrInvalidSchedule
+	}
+	if ns.Price == 0 && ns.Percent == 0 {
+		return Schedule{}, ErrInvalidSchedule
+	}
+	if ns.EndsAt != nil && !ns.EndsAt.After(ns.StartsAt) {
+		return Schedule{}, ErrInvalidSchedule
+	}
+
+	// A fixed price is checked against the regular price at the time the
+	// schedule is created, a later price change does not void it.
+	if ns.ProductID != nil && ns.Price > 0 {
+		prod, err := p.queryByID(ctx, traceID, *ns.ProductID)
+		if err != nil {
+			return Schedule{}, err
+		}
+		if ns.Price >= prod.Price {
+			return Schedule{}, ErrSalePrice
+		}
+	}
+
+	s := Schedule{
+		ID:          uuid.New().String(),
+		ProductID:   ns.ProductID,
+		CategoryID:  ns.CategoryID,
+		Price:       ns.Price,
+		Percent:     ns.Percent,
+		StartsAt:    ns.StartsAt.UTC(),
+		EndsAt:      utc(ns.EndsAt),
+		DateCreated: now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO price_schedules
+		(schedule_id, product_id, category_id, price, percent, starts_at, ends_at, date_created)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	p.log.Debug("product.CreateSchedule", logger.TraceID(traceID),
+		database.Log(q, s.ID, s.ProductID, s.CategoryID, s.Price, s.Percent, s.StartsAt, s.EndsAt, s.DateCreated),
+	)
+
+	if _, err := p.db.ExecContext(ctx, q, s.ID, s.ProductID, s.CategoryID, s.Price, s.Percent, s.StartsAt, s.EndsAt, s.DateCreated); err != nil {
+		return Schedule{}, errors.Wrap(err, "inserting price schedule")
+	}
+
+	return s, nil
+}
+
+// DeleteSchedule removes a price schedule from the database.
+func (p Product) DeleteSchedule(ctx context.Context, traceID string, claims auth.Claims, scheduleID string) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if _, err := uuid.Parse(scheduleID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	DELETE FROM
+		price_schedules
+	WHERE
+		schedule_id = $1`
+
+	p.log.Debug("product.DeleteSchedule", logger.TraceID(traceID),
+		database.Log(q, scheduleID),
+	)
+
+	if _, err := p.db.ExecContext(ctx, q, scheduleID); err != nil {
+		return errors.Wrapf(err, "deleting price schedule %s", scheduleID)
+	}
+
+	return nil
+}
+
+// QuerySchedules retrieves the price schedules which have not ended yet.
+func (p Product) QuerySchedules(ctx context.Context, traceID string, now time.Time) ([]Schedule, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		price_schedules
+	WHERE
+		ends_at IS NULL OR ends_at > $1
+	ORDER BY
+		starts_at`
+
+	p.log.Debug("product.QuerySchedules", logger.TraceID(traceID),
+		database.Log(q, now.UTC()),
+	)
+
+	schedules := []Schedule{}
+	if err := p.db.SelectContext(ctx, &schedules, q, now.UTC()); err != nil {
+		return nil, errors.Wrap(err, "selecting price schedules")
+	}
+
+	return schedules, nil
+}
+
+// QueryPriceHistory returns the regular and sale prices the product has been
+// offered at, newest first.
+func (p Product) QueryPriceHistory(ctx context.Context, traceID string, productID string, now time.Time) ([]PricePoint, error) {
+	prod, err := p.queryByID(ctx, traceID, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+	SELECT
+		price, old_price, date_from
+	FROM
+		product_prices
+	WHERE
+		product_id = $1
+	ORDER BY
+		date_from`
+
+	p.log.Debug("product.QueryPriceHistory", logger.TraceID(traceID),
+		database.Log(q, productID),
+	)
+
+	var rows []struct {
+		Price    float64   `db:"price"`
+		OldPrice float64   `db:"old_price"`
+		DateFrom time.Time `db:"date_from"`
+	}
+	if err := p.db.SelectContext(ctx, &rows, q, productID); err != nil {
+		return nil, errors.Wrapf(err, "selecting price history of %q", productID)
+	}
+
+	history := make([]PricePoint, len(rows))
+	for i, row := range rows {
+		history[i] = PricePoint{
+			Price:    row.Price,
+			OldPrice: row.OldPrice,
+			From:     row.DateFrom,
+		}
+		if i+1 < len(rows) {
+			history[i].To = &rows[i+1].DateFrom
+		}
+	}
+
+	// The regular price in effect at a moment, used to resolve the sale
+	// price of percentage schedules.
+	regular := func(at time.Time) float64 {
+		price := prod.Price
+		for i := len(history) - 1; i >= 0; i-- {
+			if !history[i].From.After(at) {
+				return history[i].Price
+			}
+		}
+		return price
+	}
+
+	const qs = `
+	SELECT
+		*
+	FROM
+		price_schedules
+	WHERE
+		(product_id = $1 OR category_id = $2) AND starts_at <= $3`
+
+	p.log.Debug("product.QueryPriceHistory", logger.TraceID(traceID),
+		database.Log(qs, productID, prod.CategoryID, now.UTC()),
+	)
+
+	schedules := []Schedule{}
+	if err := p.db.SelectContext(ctx, &schedules, qs, productID, prod.CategoryID, now.UTC()); err != nil {
+		return nil, errors.Wrapf(err, "selecting price schedules of %q", productID)
+	}
+
+	for _, s := range schedules {
+		base := regular(s.StartsAt)
+		history = append(history, PricePoint{
+			Price:    s.price(base),
+			OldPrice: base,
+			From:     s.StartsAt,
+			To:       s.EndsAt,
+			Sale:     true,
+		})
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].From.After(history[j].From)
+	})
+
+	return history, nil
+}
+
+// price returns the sale price the schedule sets for a regular price.
+func (s Schedule) price(regular float64) float64 {
+	if s.ProductID != nil && s.Price > 0 {
+		return s.Price
+	}
+	return math.Round(regular*(100-s.Percent)) / 100
+}
+
+// utc returns a UTC copy of the optional time. The timestamp columns keep no
+// time zone, so every time is stored in UTC.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// active reports whether the schedule is in effect at now.
+func (s Schedule) active(now time.Time) bool {
+	return !s.StartsAt.After(now) && (s.EndsAt == nil || s.EndsAt.After(now))
+}
+
+// applySchedules replaces the regular price of the products with the price
+// of their active schedule. A product schedule wins over a category one and
+// the latest started schedule wins over earlier ones. The regular price is
+// kept in OldPrice so it can be shown struck through.
+func (p Product) applySchedules(ctx context.Context, traceID string, prods []Info, now time.Time) error {
+	if len(prods) == 0 {
+		return nil
+	}
+
+	schedules, err := p.QuerySchedules(ctx, traceID, now)
+	if err != nil {
+		return err
+	}
+
+	for i := range prods {
+		var match *Schedule
+		for j := range schedules {
+			s := &schedules[j]
+			if !s.active(now) {
+				continue
+			}
+			switch {
+			case s.ProductID != nil && *s.ProductID == prods[i].ID:
+				if match == nil || match.ProductID == nil || !s.StartsAt.Before(match.StartsAt) {
+					match = s
+				}
+			case s.CategoryID != nil && *s.CategoryID == prods[i].CategoryID:
+				if match == nil || (match.ProductID == nil && !s.StartsAt.Before(match.StartsAt)) {
+					match = s
+				}
+			}
+		}
+		if match == nil {
+			continue
+		}
+
+		regular := prods[i].Price
+		prods[i].Price = match.price(regular)
+		if prods[i].Price < regular {
+			prods[i].OldPrice = regular
+		}
+		prods[i].SaleEndsAt = match.EndsAt
+	}
+
+	return nil
+}
+
+// recordPrice appends the regular price of the product to its price history.
+func (p Product) recordPrice(ctx context.Context, traceID string, db sqlx.ExecerContext, prod Info, now time.Time) error {
+	const q = `
+	INSERT INTO product_prices
+		(product_id, price, old_price, date_from)
+	VALUES
+		($1, $2, $3, $4)`
+
+	p.log.Debug("product.recordPrice", logger.TraceID(traceID),
+		database.Log(q, prod.ID, prod.Price, prod.OldPrice, now.UTC()),
+	)
+
+	if _, err := db.ExecContext(ctx, q, prod.ID, prod.Price, prod.OldPrice, now.UTC()); err != nil {
+		return errors.Wrapf(err, "recording price of %q", prod.ID)
+	}
+
+	return nil
+}
@@ -3,13 +3,14 @@ package product
 import (
 	"context"
 	"database/sql"
-	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/internal/auth"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
@@ -22,16 +23,28 @@ var (
 
 	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
 	ErrForbidden = errors.New("attempted action is not allowed")
+
+	// ErrUnavailable occurs when a product cannot be bought in the requested
+	// quantity.
+	ErrUnavailable = errors.New("product is not available in this quantity")
+
+	// ErrInBundle occurs when deleting a product that is a component of a
+	// bundle.
+	ErrInBundle = errors.New("product is part of a bundle")
+
+	// ErrMissingRelease occurs when a product is put on preorder without a
+	// release date.
+	ErrMissingRelease = errors.New("preorder needs a release date")
 )
 
 // Product manages the set of API's for user access.
 type Product struct {
-	log *log.Logger
+	log *logger.Logger
 	db  *sqlx.DB
 }
 
 // New constructs a Category for api access.
-func New(log *log.Logger, db *sqlx.DB) Product {
+func New(log *logger.Logger, db *sqlx.DB) Product {
 	return Product{
 		log: log,
 		db:  db,
@@ -60,34 +73,66 @@ func (p Product) Create(ctx context.Context, traceID string, claims auth.Claims,
 		MetaTitle:        np.MetaTitle,
 		MetaKeywords:     np.MetaKeywords,
 		MetaDescription:  np.MetaDescription,
+		Weight:           np.Weight,
+		Length:           np.Length,
+		Width:            np.Width,
+		Height:           np.Height,
+		TaxClassID:       np.TaxClassID,
+		Stock:            np.Stock,
 		DateCreated:      now.UTC(),
 		DateUpdated:      now.UTC(),
+		Availability:     np.Availability,
+		ReleaseDate:      utc(np.ReleaseDate),
+		PreorderLimit:    np.PreorderLimit,
+		Attributes:       []AttributeValue{},
+	}
+	if prod.Availability == "" {
+		prod.Availability = AvailabilityInStock
+	}
+	if prod.Availability == AvailabilityPreorder && prod.ReleaseDate == nil {
+		return Info{}, ErrMissingRelease
 	}
 
 	const q = `
 	INSERT INTO products
-		(product_id, title, slug, category_id, brand_id, price, old_price, image, short_description, description, meta_title, meta_keywords, meta_description, date_created, date_updated)
+		(product_id, title, slug, category_id, brand_id, price, old_price, image, short_description, description, meta_title, meta_keywords, meta_description, weight, length, width, height, tax_class_id, stock, date_created, date_updated, availability, release_date, preorder_limit)
 	VALUES
-		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)`
 
-	p.log.Printf("%s: %s: %s", traceID, "product.Create",
-		database.Log(q, prod.ID, prod.Title, prod.Slug, prod.CategoryID, prod.BrandID, prod.Price, prod.OldPrice, prod.Image, prod.ShortDescription, prod.Description, prod.MetaTitle, prod.MetaKeywords, prod.MetaDescription, prod.DateCreated, prod.DateUpdated),
+	p.log.Debug("product.Create", logger.TraceID(traceID),
+		database.Log(q, prod.ID, prod.Title, prod.Slug, prod.CategoryID, prod.BrandID, prod.Price, prod.OldPrice, prod.Image, prod.ShortDescription, prod.Description, prod.MetaTitle, prod.MetaKeywords, prod.MetaDescription, prod.Weight, prod.Length, prod.Width, prod.Height, prod.TaxClassID, prod.Stock, prod.DateCreated, prod.DateUpdated, prod.Availability, prod.ReleaseDate, prod.PreorderLimit),
 	)
 
-	if _, err := p.db.ExecContext(ctx, q, prod.ID, prod.Title, prod.Slug, prod.CategoryID, prod.BrandID, prod.Price, prod.OldPrice, prod.Image, prod.ShortDescription, prod.Description, prod.MetaTitle, prod.MetaKeywords, prod.MetaDescription, prod.DateCreated, prod.DateUpdated); err != nil {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Info{}, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, q, prod.ID, prod.Title, prod.Slug, prod.CategoryID, prod.BrandID, prod.Price, prod.OldPrice, prod.Image, prod.ShortDescription, prod.Description, prod.MetaTitle, prod.MetaKeywords, prod.MetaDescription, prod.Weight, prod.Length, prod.Width, prod.Height, prod.TaxClassID, prod.Stock, prod.DateCreated, prod.DateUpdated, prod.Availability, prod.ReleaseDate, prod.PreorderLimit); err != nil {
 		return Info{}, errors.Wrap(err, "inserting product")
 	}
 
+	if err := p.recordPrice(ctx, traceID, tx, prod, now); err != nil {
+		return Info{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Info{}, errors.Wrap(err, "committing product")
+	}
+
 	return prod, nil
 }
 
 // Update replaces a product document in the database.
 func (p Product) Update(ctx context.Context, traceID string, claims auth.Claims, productID string, up UpdateProduct, now time.Time) error {
 
-	prod, err := p.QueryByID(ctx, traceID, productID)
+	// Read the stored product, the effective price must not be written back.
+	prod, err := p.queryByID(ctx, traceID, productID)
 	if err != nil {
 		return err
 	}
+	before := prod
 
 	if !claims.Authorized(auth.RoleAdmin) {
 		return ErrForbidden
@@ -129,8 +174,38 @@ func (p Product) Update(ctx context.Context, traceID string, claims auth.Claims,
 	if up.MetaDescription != nil {
 		prod.MetaDescription = *up.MetaDescription
 	}
+	if up.Weight != nil {
+		prod.Weight = *up.Weight
+	}
+	if up.Length != nil {
+		prod.Length = *up.Length
+	}
+	if up.Width != nil {
+		prod.Width = *up.Width
+	}
+	if up.Height != nil {
+		prod.Height = *up.Height
+	}
+	if up.TaxClassID != nil {
+		prod.TaxClassID = up.TaxClassID
+	}
+	if up.Stock != nil {
+		prod.Stock = *up.Stock
+	}
+	if up.Availability != nil {
+		prod.Availability = *up.Availability
+	}
+	if up.ReleaseDate != nil {
+		prod.ReleaseDate = utc(up.ReleaseDate)
+	}
+	if up.PreorderLimit != nil {
+		prod.PreorderLimit = *up.PreorderLimit
+	}
+	if prod.Availability == AvailabilityPreorder && prod.ReleaseDate == nil {
+		return ErrMissingRelease
+	}
 
-	prod.DateUpdated = now
+	prod.DateUpdated = now.UTC()
 
 	const q = `
 	UPDATE
@@ -148,18 +223,43 @@ func (p Product) Update(ctx context.Context, traceID string, claims auth.Claims,
 		"meta_title" = $11,
 		"meta_keywords" = $12,
 		"meta_description" = $13,
-		"date_updated" = $14
+		"weight" = $14,
+		"length" = $15,
+		"width" = $16,
+		"height" = $17,
+		"tax_class_id" = $18,
+		"stock" = $19,
+		"date_updated" = $20,
+		"availability" = $21,
+		"release_date" = $22,
+		"preorder_limit" = $23
 	WHERE
 		product_id = $1`
 
-	p.log.Printf("%s: %s: %s", traceID, "product.Update",
-		database.Log(q, prod.ID, prod.Title, prod.Slug, prod.CategoryID, prod.BrandID, prod.Price, prod.OldPrice, prod.Image, prod.ShortDescription, prod.Description, prod.MetaTitle, prod.MetaKeywords, prod.MetaDescription, prod.DateUpdated),
+	p.log.Debug("product.Update", logger.TraceID(traceID),
+		database.Log(q, prod.ID, prod.Title, prod.Slug, prod.CategoryID, prod.BrandID, prod.Price, prod.OldPrice, prod.Image, prod.ShortDescription, prod.Description, prod.MetaTitle, prod.MetaKeywords, prod.MetaDescription, prod.Weight, prod.Length, prod.Width, prod.Height, prod.TaxClassID, prod.Stock, prod.DateUpdated, prod.Availability, prod.ReleaseDate, prod.PreorderLimit),
 	)
 
-	if _, err = p.db.ExecContext(ctx, q, prod.ID, prod.Title, prod.Slug, prod.CategoryID, prod.BrandID, prod.Price, prod.OldPrice, prod.Image, prod.ShortDescription, prod.Description, prod.MetaTitle, prod.MetaKeywords, prod.MetaDescription, prod.DateUpdated); err != nil {
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, q, prod.ID, prod.Title, prod.Slug, prod.CategoryID, prod.BrandID, prod.Price, prod.OldPrice, prod.Image, prod.ShortDescription, prod.Description, prod.MetaTitle, prod.MetaKeywords, prod.MetaDescription, prod.Weight, prod.Length, prod.Width, prod.Height, prod.TaxClassID, prod.Stock, prod.DateUpdated, prod.Availability, prod.ReleaseDate, prod.PreorderLimit); err != nil {
 		return errors.Wrap(err, "updating product")
 	}
 
+	if prod.Price != before.Price || prod.OldPrice != before.OldPrice {
+		if err := p.recordPrice(ctx, traceID, tx, prod, now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing product")
+	}
+
 	return nil
 }
 
@@ -178,19 +278,41 @@ func (p Product) Delete(ctx context.Context, traceID string, claims auth.Claims,
 	WHERE
 		product_id = $1`
 
-	p.log.Printf("%s: %s: %s", traceID, "product.Delete",
+	p.log.Debug("product.Delete", logger.TraceID(traceID),
 		database.Log(q, productID),
 	)
 
 	if _, err := p.db.ExecContext(ctx, q, productID); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+			return ErrInBundle
+		}
 		return errors.Wrapf(err, "deleting product %s", productID)
 	}
 
 	return nil
 }
 
-// QueryByID gets the specified product from the database.
-func (p Product) QueryByID(ctx context.Context, traceID string, productID string) (Info, error) {
+// QueryByID gets the specified product from the database with the price of
+// its schedule active at now applied.
+func (p Product) QueryByID(ctx context.Context, traceID string, productID string, now time.Time) (Info, error) {
+	prod, err := p.queryByID(ctx, traceID, productID)
+	if err != nil {
+		return Info{}, err
+	}
+
+	prods := []Info{prod}
+	if err := p.applySchedules(ctx, traceID, prods, now); err != nil {
+		return Info{}, err
+	}
+	if err := p.loadAttributes(ctx, traceID, prods); err != nil {
+		return Info{}, err
+	}
+
+	return prods[0], nil
+}
+
+// queryByID gets the specified product as it is stored in the database.
+func (p Product) queryByID(ctx context.Context, traceID string, productID string) (Info, error) {
 
 	if _, err := uuid.Parse(productID); err != nil {
 		return Info{}, ErrInvalidID
@@ -204,7 +326,7 @@ func (p Product) QueryByID(ctx context.Context, traceID string, productID string
 	WHERE 
 		product_id = $1`
 
-	p.log.Printf("%s: %s: %s", traceID, "product.QueryByID",
+	p.log.Debug("product.QueryByID", logger.TraceID(traceID),
 		database.Log(q, productID),
 	)
 
@@ -219,8 +341,9 @@ func (p Product) QueryByID(ctx context.Context, traceID string, productID string
 	return cat, nil
 }
 
-// QueryBySlug gets the specified product from the database.
-func (p Product) QueryBySlug(ctx context.Context, traceID string, Slug string) (Info, error) {
+// QueryBySlug gets the specified product from the database with the price of
+// its schedule active at now applied.
+func (p Product) QueryBySlug(ctx context.Context, traceID string, Slug string, now time.Time) (Info, error) {
 
 	const q = `
 	SELECT
@@ -230,7 +353,7 @@ func (p Product) QueryBySlug(ctx context.Context, traceID string, Slug string) (
 	WHERE 
 		slug = $1`
 
-	p.log.Printf("%s: %s: %s", traceID, "product.QueryBySlug",
+	p.log.Debug("product.QueryBySlug", logger.TraceID(traceID),
 		database.Log(q, Slug),
 	)
 
@@ -242,28 +365,72 @@ func (p Product) QueryBySlug(ctx context.Context, traceID string, Slug string) (
 		return Info{}, errors.Wrapf(err, "selecting product %q", Slug)
 	}
 
-	return cat, nil
+	prods := []Info{cat}
+	if err := p.applySchedules(ctx, traceID, prods, now); err != nil {
+		return Info{}, err
+	}
+	if err := p.loadAttributes(ctx, traceID, prods); err != nil {
+		return Info{}, err
+	}
+
+	return prods[0], nil
 }
 
-// Query retrieves a list of existing product from the database.
-func (p Product) Query(ctx context.Context, traceID string) ([]Info, error) {
+// Query retrieves a list of existing product from the database matching the
+// filter, with the prices of their schedules active at now applied.
+func (p Product) Query(ctx context.Context, traceID string, filter Filter, now time.Time) ([]Info, error) {
 
-	const q = `
+	where, args := filter.where()
+
+	q := `
 	SELECT
 		*
 	FROM
 		products
+	` + where + `
 	ORDER BY
 		date_created`
 
-	p.log.Printf("%s: %s: %s", traceID, "products.Query",
-		database.Log(q),
+	p.log.Debug("products.Query", logger.TraceID(traceID),
+		database.Log(q, args...),
 	)
 
 	categories := []Info{}
-	if err := p.db.SelectContext(ctx, &categories, q); err != nil {
+	if err := p.db.SelectContext(ctx, &categories, q, args...); err != nil {
 		return nil, errors.Wrap(err, "selecting products")
 	}
 
+	if err := p.applySchedules(ctx, traceID, categories, now); err != nil {
+		return nil, err
+	}
+
+	if err := p.loadAttributes(ctx, traceID, categories); err != nil {
+		return nil, err
+	}
+
 	return categories, nil
 }
+
+// Available checks whether qty items of the product can be bought at now.
+// Lines that exceed the stock of a backorder or preorder product ship later.
+func (i Info) Available(qty int, now time.Time) error {
+	switch i.Availability {
+	case AvailabilityBackorder:
+		return nil
+
+	case AvailabilityPreorder:
+		if i.ReleaseDate != nil && !now.Before(*i.ReleaseDate) && qty > i.Stock {
+			return errors.Wrapf(ErrUnavailable, "%s was released, only %d left", i.Title, i.Stock)
+		}
+		if i.PreorderLimit > 0 && qty > i.PreorderLimit+i.Stock {
+			return errors.Wrapf(ErrUnavailable, "%s can be preordered %d times", i.Title, i.PreorderLimit)
+		}
+		return nil
+
+	default:
+		if qty > i.Stock {
+			return errors.Wrapf(ErrUnavailable, "%s has %d left", i.Title, i.Stock)
+		}
+		return nil
+	}
+}
@@ -54,7 +54,7 @@ func TestProduct(t *testing.T) {
 	}
 	t.Logf("\t%s\tTest %d:\tShould be able to create product.", tests.Success, testID)
 
-	saved, err := p.QueryByID(ctx, traceID, prod.ID)
+	saved, err := p.QueryByID(ctx, traceID, prod.ID, now)
 	if err != nil {
 		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", tests.Failed, testID, err)
 	}
@@ -65,6 +65,45 @@ func TestProduct(t *testing.T) {
 	}
 	t.Logf("\t%s\tTest %d:\tShould get back the same product.", tests.Success, testID)
 
+	if err := saved.Available(1, now); errors.Cause(err) != product.ErrUnavailable {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to buy a product without stock : %v.", tests.Failed, testID, err)
+	}
+	release := now.Add(24 * time.Hour)
+	saved.Availability = product.AvailabilityPreorder
+	saved.ReleaseDate = &release
+	saved.PreorderLimit = 2
+	if err := saved.Available(2, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to preorder a product : %s.", tests.Failed, testID, err)
+	}
+	if err := saved.Available(3, now); errors.Cause(err) != product.ErrUnavailable {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to preorder more than the limit : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould respect the availability of the product.", tests.Success, testID)
+
+	preorder := product.UpdateProduct{
+		Availability: tests.StringPointer(product.AvailabilityPreorder),
+	}
+	if err := p.Update(ctx, traceID, claims, prod.ID, preorder, now); err != product.ErrMissingRelease {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to preorder a product without release date : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to preorder a product without release date.", tests.Success, testID)
+
+	// The release date is sent in another time zone, it must still be the
+	// same instant once stored.
+	local := release.In(time.FixedZone("MSK", 3*60*60))
+	preorder.ReleaseDate = &local
+	if err := p.Update(ctx, traceID, claims, prod.ID, preorder, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to preorder a product : %s.", tests.Failed, testID, err)
+	}
+	saved, err = p.QueryByID(ctx, traceID, prod.ID, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", tests.Failed, testID, err)
+	}
+	if saved.ReleaseDate == nil || !saved.ReleaseDate.Equal(release) {
+		t.Fatalf("\t%s\tTest %d:\tShould keep the release date : %v.", tests.Failed, testID, saved.ReleaseDate)
+	}
+	t.Logf("\t%s\tTest %d:\tShould keep the release date.", tests.Success, testID)
+
 	upd := product.UpdateProduct{
 		Title: tests.StringPointer("Test Product Update"),
 		Slug:  tests.StringPointer("test-product-update"),
@@ -75,7 +114,7 @@ func TestProduct(t *testing.T) {
 	}
 	t.Logf("\t%s\tTest %d:\tShould be able to update product.", tests.Success, testID)
 
-	saved, err = p.QueryBySlug(ctx, traceID, *upd.Slug)
+	saved, err = p.QueryBySlug(ctx, traceID, *upd.Slug, now)
 	if err != nil {
 		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by Slug : %s.", tests.Failed, testID, err)
 	}
@@ -97,12 +136,149 @@ func TestProduct(t *testing.T) {
 		t.Logf("\t%s\tTest %d:\tShould be able to see updates to Slug.", tests.Success, testID)
 	}
 
+	na := product.NewAttribute{
+		CategoryID: np.CategoryID,
+		Code:       "screen",
+		Title:      "Screen size",
+		Type:       product.AttributeNumber,
+		Unit:       "in",
+		Filterable: true,
+	}
+	if _, err := p.CreateAttribute(ctx, traceID, claims, na, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to define an attribute : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to define an attribute.", tests.Success, testID)
+
+	if err := p.SetAttributes(ctx, traceID, claims, prod.ID, []product.NewAttributeValue{{Code: "screen", Value: "big"}}); errors.Cause(err) != product.ErrInvalidAttribute {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to set a text value on a number : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to set a text value on a number.", tests.Success, testID)
+
+	twice := []product.NewAttributeValue{{Code: "screen", Value: 13.3}, {Code: "SCREEN", Value: 14.0}}
+	if err := p.SetAttributes(ctx, traceID, claims, prod.ID, twice); errors.Cause(err) != product.ErrInvalidAttribute {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to set an attribute twice : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to set an attribute twice.", tests.Success, testID)
+
+	if err := p.SetAttributes(ctx, traceID, claims, prod.ID, twice[:1]); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to set attribute values : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to set attribute values.", tests.Success, testID)
+
+	min := 13.0
+	filter := product.Filter{
+		CategoryID: np.CategoryID,
+		Attributes: []product.AttributeFilter{{Code: "screen", Min: &min}},
+	}
+	prods, err := p.Query(ctx, traceID, filter, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to filter products : %s.", tests.Failed, testID, err)
+	}
+	if len(prods) != 1 || prods[0].ID != prod.ID || len(prods[0].Attributes) != 1 || prods[0].Attributes[0].Value != 13.3 {
+		t.Fatalf("\t%s\tTest %d:\tShould find the product by its screen size : %+v.", tests.Failed, testID, prods)
+	}
+	t.Logf("\t%s\tTest %d:\tShould find the product by its screen size.", tests.Success, testID)
+
+	seededID := "9097a8f9-c7c0-4e88-81da-72ec34a1dc79"
+	rel, err := p.QueryRelations(ctx, traceID, prod.ID, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve relations : %s.", tests.Failed, testID, err)
+	}
+	if !rel.Automatic || len(rel.Related) != 1 || rel.Related[0].ID != seededID {
+		t.Fatalf("\t%s\tTest %d:\tShould fall back to products of the same category and brand : %+v.", tests.Failed, testID, rel)
+	}
+	t.Logf("\t%s\tTest %d:\tShould fall back to products of the same category and brand.", tests.Success, testID)
+
+	nr := product.NewRelation{RelatedID: seededID, Type: product.RelationAccessory}
+	if err := p.AddRelation(ctx, traceID, claims, prod.ID, nr); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to add a relation : %s.", tests.Failed, testID, err)
+	}
+	rel, err = p.QueryRelations(ctx, traceID, prod.ID, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve relations : %s.", tests.Failed, testID, err)
+	}
+	if len(rel.Accessory) != 1 || rel.Accessory[0].ID != seededID {
+		t.Fatalf("\t%s\tTest %d:\tShould see the curated accessory : %+v.", tests.Failed, testID, rel)
+	}
+	t.Logf("\t%s\tTest %d:\tShould see the curated accessory.", tests.Success, testID)
+
+	start := now.Add(-time.Hour)
+	end := now.Add(time.Hour).In(time.FixedZone("MSK", 3*60*60))
+	ns := product.NewSchedule{
+		ProductID: &prod.ID,
+		StartsAt:  start,
+		EndsAt:    &end,
+	}
+
+	if _, err := p.CreateSchedule(ctx, traceID, claims, ns, now); err != product.ErrInvalidSchedule {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to schedule neither a price nor a percent : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to schedule neither a price nor a percent.", tests.Success, testID)
+
+	ns.Price = np.Price
+	if _, err := p.CreateSchedule(ctx, traceID, claims, ns, now); err != product.ErrSalePrice {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to schedule a price not below the regular one : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to schedule a price not below the regular one.", tests.Success, testID)
+
+	ns.Price = 199.99
+
+	if _, err := p.CreateSchedule(ctx, traceID, claims, ns, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to schedule a sale price : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to schedule a sale price.", tests.Success, testID)
+
+	saved, err = p.QueryByID(ctx, traceID, prod.ID, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", tests.Failed, testID, err)
+	}
+
+	if saved.Price != ns.Price || saved.OldPrice != np.Price {
+		t.Errorf("\t%s\tTest %d:\tShould see the scheduled sale price.", tests.Failed, testID)
+		t.Logf("\t\tTest %d:\tGot: %v / %v", testID, saved.Price, saved.OldPrice)
+		t.Logf("\t\tTest %d:\tExp: %v / %v", testID, ns.Price, np.Price)
+	} else {
+		t.Logf("\t%s\tTest %d:\tShould see the scheduled sale price.", tests.Success, testID)
+	}
+
+	if saved.SaleEndsAt == nil || !saved.SaleEndsAt.Equal(end) {
+		t.Fatalf("\t%s\tTest %d:\tShould see when the sale ends : %v.", tests.Failed, testID, saved.SaleEndsAt)
+	}
+	t.Logf("\t%s\tTest %d:\tShould see when the sale ends.", tests.Success, testID)
+
+	saved, err = p.QueryByID(ctx, traceID, prod.ID, end)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", tests.Failed, testID, err)
+	}
+	if saved.Price != np.Price || saved.SaleEndsAt != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould see the regular price once the sale ended : %v.", tests.Failed, testID, saved.Price)
+	}
+	t.Logf("\t%s\tTest %d:\tShould see the regular price once the sale ended.", tests.Success, testID)
+
+	saved, err = p.QueryByID(ctx, traceID, prod.ID, start.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", tests.Failed, testID, err)
+	}
+	if saved.Price != np.Price {
+		t.Fatalf("\t%s\tTest %d:\tShould see the regular price before the sale : %v.", tests.Failed, testID, saved.Price)
+	}
+	t.Logf("\t%s\tTest %d:\tShould see the regular price before the sale.", tests.Success, testID)
+
+	history, err := p.QueryPriceHistory(ctx, traceID, prod.ID, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve price history : %s.", tests.Failed, testID, err)
+	}
+	if len(history) != 2 || !history[0].Sale {
+		t.Fatalf("\t%s\tTest %d:\tShould see the sale price in the price history : %+v.", tests.Failed, testID, history)
+	}
+	t.Logf("\t%s\tTest %d:\tShould see the sale price in the price history.", tests.Success, testID)
+
 	if err := p.Delete(ctx, traceID, claims, prod.ID); err != nil {
 		t.Fatalf("\t%s\tTest %d:\tShould be able to delete product : %s.", tests.Failed, testID, err)
 	}
 	t.Logf("\t%s\tTest %d:\tShould be able to delete product.", tests.Success, testID)
 
-	_, err = p.QueryByID(ctx, traceID, prod.ID)
+	_, err = p.QueryByID(ctx, traceID, prod.ID, now)
 	if errors.Cause(err) != product.ErrNotFound {
 		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve product : %s.", tests.Failed, testID, err)
 	}
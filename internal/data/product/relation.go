@@ -0,0 +1,168 @@
+package product
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// fallbackRelated is the number of products picked automatically when a
+// product has no curated related products.
+const fallbackRelated = 8
+
+// AddRelation relates a product to another one. Adding an existing relation
+// again updates its position.
+func (p Product) AddRelation(ctx context.Context, traceID string, claims auth.Claims, productID string, nr NewRelation) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if _, err := uuid.Parse(productID); err != nil {
+		return ErrInvalidID
+	}
+	if productID == nr.RelatedID {
+		return ErrInvalidID
+	}
+
+	const q = `
+	INSERT INTO product_relations
+		(product_id, related_id, type, position)
+	VALUES
+		($1, $2, $3, $4)
+	ON CONFLICT (product_id, related_id, type) DO UPDATE SET
+		position = EXCLUDED.position`
+
+	p.log.Debug("product.AddRelation", logger.TraceID(traceID),
+		database.Log(q, productID, nr.RelatedID, nr.Type, nr.Position),
+	)
+
+	if _, err := p.db.ExecContext(ctx, q, productID, nr.RelatedID, nr.Type, nr.Position); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+			return ErrNotFound
+		}
+		return errors.Wrapf(err, "relating product %s to %s", productID, nr.RelatedID)
+	}
+
+	return nil
+}
+
+// RemoveRelation removes a relation of the given kind between two products.
+func (p Product) RemoveRelation(ctx context.Context, traceID string, claims auth.Claims, productID string, relatedID string, kind string) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if _, err := uuid.Parse(productID); err != nil {
+		return ErrInvalidID
+	}
+	if _, err := uuid.Parse(relatedID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	DELETE FROM
+		product_relations
+	WHERE
+		product_id = $1 AND related_id = $2 AND type = $3`
+
+	p.log.Debug("product.RemoveRelation", logger.TraceID(traceID),
+		database.Log(q, productID, relatedID, kind),
+	)
+
+	if _, err := p.db.ExecContext(ctx, q, productID, relatedID, kind); err != nil {
+		return errors.Wrapf(err, "removing relation of product %s to %s", productID, relatedID)
+	}
+
+	return nil
+}
+
+// QueryRelations retrieves the curated relations of a product. When no
+// related products were curated, products of the same category and brand
+// are used instead, followed by products sharing only one of them. Prices
+// are the ones at now.
+func (p Product) QueryRelations(ctx context.Context, traceID string, productID string, now time.Time) (Relations, error) {
+	prod, err := p.queryByID(ctx, traceID, productID)
+	if err != nil {
+		return Relations{}, err
+	}
+
+	const q = `
+	SELECT
+		p.*, r.type AS relation
+	FROM
+		product_relations r
+	JOIN
+		products p ON p.product_id = r.related_id
+	WHERE
+		r.product_id = $1
+	ORDER BY
+		r.position, p.title`
+
+	p.log.Debug("product.QueryRelations", logger.TraceID(traceID),
+		database.Log(q, prod.ID),
+	)
+
+	var rows []struct {
+		Info
+		Relation string `db:"relation"`
+	}
+	if err := p.db.SelectContext(ctx, &rows, q, prod.ID); err != nil {
+		return Relations{}, errors.Wrapf(err, "selecting relations of product %q", prod.ID)
+	}
+
+	rel := Relations{
+		Related:   []Info{},
+		CrossSell: []Info{},
+		Upsell:    []Info{},
+		Accessory: []Info{},
+	}
+	for _, row := range rows {
+		switch row.Relation {
+		case RelationRelated:
+			rel.Related = append(rel.Related, row.Info)
+		case RelationCrossSell:
+			rel.CrossSell = append(rel.CrossSell, row.Info)
+		case RelationUpsell:
+			rel.Upsell = append(rel.Upsell, row.Info)
+		case RelationAccessory:
+			rel.Accessory = append(rel.Accessory, row.Info)
+		}
+	}
+
+	if len(rel.Related) == 0 {
+		const qf = `
+		SELECT
+			*
+		FROM
+			products
+		WHERE
+			product_id <> $1 AND (category_id::text = $2 OR brand_id::text = $3)
+		ORDER BY
+			COALESCE((category_id::text = $2)::int, 0) + COALESCE((brand_id::text = $3)::int, 0) DESC, rating DESC, date_created DESC
+		LIMIT $4`
+
+		p.log.Debug("product.QueryRelations", logger.TraceID(traceID),
+			database.Log(qf, prod.ID, prod.CategoryID, prod.BrandID, fallbackRelated),
+		)
+
+		if err := p.db.SelectContext(ctx, &rel.Related, qf, prod.ID, prod.CategoryID, prod.BrandID, fallbackRelated); err != nil {
+			return Relations{}, errors.Wrapf(err, "selecting products like %q", prod.ID)
+		}
+		rel.Automatic = true
+	}
+
+	for _, prods := range [][]Info{rel.Related, rel.CrossSell, rel.Upsell, rel.Accessory} {
+		if err := p.applySchedules(ctx, traceID, prods, now); err != nil {
+			return Relations{}, err
+		}
+		if err := p.loadAttributes(ctx, traceID, prods); err != nil {
+			return Relations{}, err
+		}
+	}
+
+	return rel, nil
+}
@@ -0,0 +1,90 @@
+package promotion
+
+import (
+	"time"
+)
+
+// These are the supported values for Info.Type.
+const (
+	TypePercentage   = "percentage"
+	TypeFixed        = "fixed"
+	TypeFreeShipping = "free_shipping"
+	TypeBuyXGetY     = "buy_x_get_y"
+)
+
+// Info represents an individual Promotion. A promotion with a Code is a
+// coupon the customer has to enter, an Automatic one is applied to every
+// cart matching its conditions.
+type Info struct {
+	ID            string     `db:"promotion_id" json:"id"`
+	Title         string     `db:"title" json:"title"`
+	Code          *string    `db:"code" json:"code"`
+	Type          string     `db:"type" json:"type"`
+	Value         float64    `db:"value" json:"value"`
+	Automatic     bool       `db:"automatic" json:"automatic"`
+	MinSubtotal   float64    `db:"min_subtotal" json:"min_subtotal"`
+	CategoryID    *string    `db:"category_id" json:"category_id"`
+	BrandID       *string    `db:"brand_id" json:"brand_id"`
+	BuyQty        int        `db:"buy_qty" json:"buy_qty"`
+	GetQty        int        `db:"get_qty" json:"get_qty"`
+	UsageLimit    int        `db:"usage_limit" json:"usage_limit"`
+	CustomerLimit int        `db:"customer_limit" json:"customer_limit"`
+	StartsAt      *time.Time `db:"starts_at" json:"starts_at"`
+	EndsAt        *time.Time `db:"ends_at" json:"ends_at"`
+	DateCreated   time.Time  `db:"date_created" json:"date_created"`
+	DateUpdated   time.Time  `db:"date_updated" json:"date_updated"`
+}
+
+// NewPromotion contains information needed to create a new Promotion.
+type NewPromotion struct {
+	Title         string     `json:"title" validate:"required"`
+	Code          *string    `json:"code" validate:"omitempty,min=3"`
+	Type          string     `json:"type" validate:"required,oneof=percentage fixed free_shipping buy_x_get_y"`
+	Value         float64    `json:"value" validate:"gte=0"`
+	Automatic     bool       `json:"automatic"`
+	MinSubtotal   float64    `json:"min_subtotal" validate:"gte=0"`
+	CategoryID    *string    `json:"category_id" validate:"omitempty,uuid"`
+	BrandID       *string    `json:"brand_id" validate:"omitempty,uuid"`
+	BuyQty        int        `json:"buy_qty" validate:"gte=0"`
+	GetQty        int        `json:"get_qty" validate:"gte=0"`
+	UsageLimit    int        `json:"usage_limit" validate:"gte=0"`
+	CustomerLimit int        `json:"customer_limit" validate:"gte=0"`
+	StartsAt      *time.Time `json:"starts_at"`
+	EndsAt        *time.Time `json:"ends_at"`
+}
+
+// UpdatePromotion defines what information may be provided to modify an
+// existing Promotion. All fields are optional.
+type UpdatePromotion struct {
+	Title         *string    `json:"title"`
+	Code          *string    `json:"code" validate:"omitempty,min=3"`
+	Value         *float64   `json:"value" validate:"omitempty,gte=0"`
+	MinSubtotal   *float64   `json:"min_subtotal" validate:"omitempty,gte=0"`
+	CategoryID    *string    `json:"category_id" validate:"omitempty,uuid"`
+	BrandID       *string    `json:"brand_id" validate:"omitempty,uuid"`
+	BuyQty        *int       `json:"buy_qty" validate:"omitempty,gte=0"`
+	GetQty        *int       `json:"get_qty" validate:"omitempty,gte=0"`
+	UsageLimit    *int       `json:"usage_limit" validate:"omitempty,gte=0"`
+	CustomerLimit *int       `json:"customer_limit" validate:"omitempty,gte=0"`
+	StartsAt      *time.Time `json:"starts_at"`
+	EndsAt        *time.Time `json:"ends_at"`
+}
+
+// Line is a single cart position promotions are evaluated against.
+type Line struct {
+	ProductID  string
+	CategoryID string
+	BrandID    string
+	Price      float64
+	Qty        int
+}
+
+// Discount is a promotion applied to a cart.
+type Discount struct {
+	PromotionID  string  `json:"promotion_id"`
+	Title        string  `json:"title"`
+	Code         string  `json:"code,omitempty"`
+	Type         string  `json:"type"`
+	Amount       float64 `json:"amount"`
+	FreeShipping bool    `json:"free_shipping,omitempty"`
+}
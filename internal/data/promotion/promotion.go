@@ -0,0 +1,448 @@
+// Package promotion contains coupon codes and automatic cart promotions.
+package promotion
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific Promotion is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+
+	// ErrMissingCode occurs when a promotion is neither automatic nor has a coupon code.
+	ErrMissingCode = errors.New("promotion must have a code or be automatic")
+
+	// ErrInvalidCode occurs when a coupon code does not exist or is not active.
+	ErrInvalidCode = errors.New("coupon code is not valid")
+
+	// ErrUsageLimit occurs when a coupon has been used up overall or by the customer.
+	ErrUsageLimit = errors.New("coupon usage limit reached")
+
+	// ErrInvalidWindow occurs when a promotion does not end after it starts.
+	ErrInvalidWindow = errors.New("promotion must end after it starts")
+
+	// ErrCodeTaken occurs when the coupon code is used by another promotion.
+	ErrCodeTaken = errors.New("coupon code is already taken")
+)
+
+// Promotion manages the set of API's for promotion access.
+type Promotion struct {
+	log *logger.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a Promotion for api access.
+func New(log *logger.Logger, db *sqlx.DB) Promotion {
+	return Promotion{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new promotion into the database.
+func (p Promotion) Create(ctx context.Context, traceID string, claims auth.Claims, np NewPromotion, now time.Time) (Info, error) {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return Info{}, ErrForbidden
+	}
+
+	if np.Code == nil && !np.Automatic {
+		return Info{}, ErrMissingCode
+	}
+	if !validWindow(np.StartsAt, np.EndsAt) {
+		return Info{}, ErrInvalidWindow
+	}
+
+	prm := Info{
+		ID:            uuid.New().String(),
+		Title:         np.Title,
+		Code:          np.Code,
+		Type:          np.Type,
+		Value:         np.Value,
+		Automatic:     np.Automatic,
+		MinSubtotal:   np.MinSubtotal,
+		CategoryID:    np.CategoryID,
+		BrandID:       np.BrandID,
+		BuyQty:        np.BuyQty,
+		GetQty:        np.GetQty,
+		UsageLimit:    np.UsageLimit,
+		CustomerLimit: np.CustomerLimit,
+		StartsAt:      utc(np.StartsAt),
+		EndsAt:        utc(np.EndsAt),
+		DateCreated:   now.UTC(),
+		DateUpdated:   now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO promotions
+		(promotion_id, title, code, type, value, automatic, min_subtotal, category_id, brand_id, buy_qty, get_qty, usage_limit, customer_limit, starts_at, ends_at, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`
+
+	p.log.Debug("promotion.Create", logger.TraceID(traceID),
+		database.Log(q, prm.ID, prm.Title, prm.Code, prm.Type, prm.Value, prm.Automatic, prm.MinSubtotal, prm.CategoryID, prm.BrandID, prm.BuyQty, prm.GetQty, prm.UsageLimit, prm.CustomerLimit, prm.StartsAt, prm.EndsAt, prm.DateCreated, prm.DateUpdated),
+	)
+
+	if _, err := p.db.ExecContext(ctx, q, prm.ID, prm.Title, prm.Code, prm.Type, prm.Value, prm.Automatic, prm.MinSubtotal, prm.CategoryID, prm.BrandID, prm.BuyQty, prm.GetQty, prm.UsageLimit, prm.CustomerLimit, prm.StartsAt, prm.EndsAt, prm.DateCreated, prm.DateUpdated); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return Info{}, ErrCodeTaken
+		}
+		return Info{}, errors.Wrap(err, "inserting promotion")
+	}
+
+	return prm, nil
+}
+
+// Update replaces a promotion document in the database.
+func (p Promotion) Update(ctx context.Context, traceID string, claims auth.Claims, promotionID string, up UpdatePromotion, now time.Time) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+
+	prm, err := p.QueryByID(ctx, traceID, promotionID)
+	if err != nil {
+		return err
+	}
+
+	if up.Title != nil {
+		prm.Title = *up.Title
+	}
+	if up.Code != nil {
+		prm.Code = up.Code
+	}
+	if up.Value != nil {
+		prm.Value = *up.Value
+	}
+	if up.MinSubtotal != nil {
+		prm.MinSubtotal = *up.MinSubtotal
+	}
+	if up.CategoryID != nil {
+		prm.CategoryID = up.CategoryID
+	}
+	if up.BrandID != nil {
+		prm.BrandID = up.BrandID
+	}
+	if up.BuyQty != nil {
+		prm.BuyQty = *up.BuyQty
+	}
+	if up.GetQty != nil {
+		prm.GetQty = *up.GetQty
+	}
+	if up.UsageLimit != nil {
+		prm.UsageLimit = *up.UsageLimit
+	}
+	if up.CustomerLimit != nil {
+		prm.CustomerLimit = *up.CustomerLimit
+	}
+	if up.StartsAt != nil {
+		prm.StartsAt = utc(up.StartsAt)
+	}
+	if up.EndsAt != nil {
+		prm.EndsAt = utc(up.EndsAt)
+	}
+	if !validWindow(prm.StartsAt, prm.EndsAt) {
+		return ErrInvalidWindow
+	}
+	prm.DateUpdated = now.UTC()
+
+	const q = `
+	UPDATE
+		promotions
+	SET
+		"title" = $2,
+		"code" = $3,
+		"value" = $4,
+		"min_subtotal" = $5,
+		"category_id" = $6,
+		"brand_id" = $7,
+		"buy_qty" = $8,
+		"get_qty" = $9,
+		"usage_limit" = $10,
+		"customer_limit" = $11,
+		"starts_at" = $12,
+		"ends_at" = $13,
+		"date_updated" = $14
+	WHERE
+		promotion_id = $1`
+
+	p.log.Debug("promotion.Update", logger.TraceID(traceID),
+		database.Log(q, prm.ID, prm.Title, prm.Code, prm.Value, prm.MinSubtotal, prm.CategoryID, prm.BrandID, prm.BuyQty, prm.GetQty, prm.UsageLimit, prm.CustomerLimit, prm.StartsAt, prm.EndsAt, prm.DateUpdated),
+	)
+
+	if _, err := p.db.ExecContext(ctx, q, prm.ID, prm.Title, prm.Code, prm.Value, prm.MinSubtotal, prm.CategoryID, prm.BrandID, prm.BuyQty, prm.GetQty, prm.UsageLimit, prm.CustomerLimit, prm.StartsAt, prm.EndsAt, prm.DateUpdated); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return ErrCodeTaken
+		}
+		return errors.Wrap(err, "updating promotion")
+	}
+
+	return nil
+}
+
+// Delete removes a promotion from the database.
+func (p Promotion) Delete(ctx context.Context, traceID string, claims auth.Claims, promotionID string) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if _, err := uuid.Parse(promotionID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	DELETE FROM
+		promotions
+	WHERE
+		promotion_id = $1`
+
+	p.log.Debug("promotion.Delete", logger.TraceID(traceID),
+		database.Log(q, promotionID),
+	)
+
+	if _, err := p.db.ExecContext(ctx, q, promotionID); err != nil {
+		return errors.Wrapf(err, "deleting promotion %s", promotionID)
+	}
+
+	return nil
+}
+
+// Query retrieves a list of existing promotions from the database.
+func (p Promotion) Query(ctx context.Context, traceID string) ([]Info, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		promotions
+	ORDER BY
+		date_created`
+
+	p.log.Debug("promotion.Query", logger.TraceID(traceID),
+		database.Log(q),
+	)
+
+	promotions := []Info{}
+	if err := p.db.SelectContext(ctx, &promotions, q); err != nil {
+		return nil, errors.Wrap(err, "selecting promotions")
+	}
+
+	return promotions, nil
+}
+
+// QueryByID gets the specified promotion from the database.
+func (p Promotion) QueryByID(ctx context.Context, traceID string, promotionID string) (Info, error) {
+	if _, err := uuid.Parse(promotionID); err != nil {
+		return Info{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		promotions
+	WHERE
+		promotion_id = $1`
+
+	p.log.Debug("promotion.QueryByID", logger.TraceID(traceID),
+		database.Log(q, promotionID),
+	)
+
+	var prm Info
+	if err := p.db.GetContext(ctx, &prm, q, promotionID); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting promotion %q", promotionID)
+	}
+
+	return prm, nil
+}
+
+// QueryByCode gets the coupon with the specified code, ignoring case.
+func (p Promotion) QueryByCode(ctx context.Context, traceID string, code string) (Info, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		promotions
+	WHERE
+		upper(code) = upper($1)`
+
+	p.log.Debug("promotion.QueryByCode", logger.TraceID(traceID),
+		database.Log(q, code),
+	)
+
+	var prm Info
+	if err := p.db.GetContext(ctx, &prm, q, code); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting promotion %q", code)
+	}
+
+	return prm, nil
+}
+
+// Apply evaluates the automatic promotions and the optional coupon code
+// against the cart lines and returns the itemized discounts. The userID may
+// be empty for anonymous carts, in which case per customer limits are only
+// enforced on Redeem.
+func (p Promotion) Apply(ctx context.Context, traceID string, userID string, code string, lines []Line, now time.Time) ([]Discount, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		promotions
+	WHERE
+		automatic = TRUE AND
+		(starts_at IS NULL OR starts_at <= $1) AND
+		(ends_at IS NULL OR ends_at > $1)
+	ORDER BY
+		date_created`
+
+	p.log.Debug("promotion.Apply", logger.TraceID(traceID),
+		database.Log(q, now.UTC()),
+	)
+
+	automatic := []Info{}
+	if err := p.db.SelectContext(ctx, &automatic, q, now.UTC()); err != nil {
+		return nil, errors.Wrap(err, "selecting automatic promotions")
+	}
+
+	// Automatic promotions used up overall or by the customer are left out.
+	candidates := []Info{}
+	for _, prm := range automatic {
+		switch err := p.checkLimits(ctx, traceID, p.db, prm, userID); err {
+		case nil:
+			candidates = append(candidates, prm)
+		case ErrUsageLimit:
+		default:
+			return nil, err
+		}
+	}
+
+	if code = strings.TrimSpace(code); code != "" {
+		prm, err := p.QueryByCode(ctx, traceID, code)
+		if err != nil {
+			if err == ErrNotFound {
+				return nil, ErrInvalidCode
+			}
+			return nil, err
+		}
+		if !prm.Active(now) {
+			return nil, ErrInvalidCode
+		}
+		if err := p.checkLimits(ctx, traceID, p.db, prm, userID); err != nil {
+			return nil, err
+		}
+
+		// The code of an automatic promotion does not apply it twice.
+		applied := false
+		for _, c := range candidates {
+			if c.ID == prm.ID {
+				applied = true
+				break
+			}
+		}
+		if !applied {
+			candidates = append(candidates, prm)
+		}
+	}
+
+	return Evaluate(candidates, lines), nil
+}
+
+// Redeem records the use of a promotion by a customer once the order it was
+// applied to is placed. The usage limits are checked inside the same
+// transaction so concurrent orders cannot exceed them.
+func (p Promotion) Redeem(ctx context.Context, traceID string, claims auth.Claims, promotionID string, now time.Time) error {
+	prm, err := p.QueryByID(ctx, traceID, promotionID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	// Serialize redemptions of the same promotion.
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM promotions WHERE promotion_id = $1 FOR UPDATE`, prm.ID); err != nil {
+		return errors.Wrapf(err, "locking promotion %s", prm.ID)
+	}
+
+	if err := p.checkLimits(ctx, traceID, tx, prm, claims.Subject); err != nil {
+		return err
+	}
+
+	const q = `
+	INSERT INTO promotion_redemptions
+		(redemption_id, promotion_id, user_id, date_created)
+	VALUES
+		($1, $2, $3, $4)`
+
+	id := uuid.New().String()
+
+	p.log.Debug("promotion.Redeem", logger.TraceID(traceID),
+		database.Log(q, id, prm.ID, claims.Subject, now.UTC()),
+	)
+
+	if _, err := tx.ExecContext(ctx, q, id, prm.ID, claims.Subject, now.UTC()); err != nil {
+		return errors.Wrapf(err, "redeeming promotion %s", prm.ID)
+	}
+
+	return tx.Commit()
+}
+
+// checkLimits returns ErrUsageLimit if the promotion has been used up overall
+// or by the specified user.
+func (p Promotion) checkLimits(ctx context.Context, traceID string, db sqlx.QueryerContext, prm Info, userID string) error {
+	if prm.UsageLimit == 0 && (prm.CustomerLimit == 0 || userID == "") {
+		return nil
+	}
+
+	const q = `
+	SELECT
+		count(*) AS total,
+		count(*) FILTER (WHERE user_id::text = $2) AS customer
+	FROM
+		promotion_redemptions
+	WHERE
+		promotion_id = $1`
+
+	p.log.Debug("promotion.checkLimits", logger.TraceID(traceID),
+		database.Log(q, prm.ID, userID),
+	)
+
+	var used struct {
+		Total    int `db:"total"`
+		Customer int `db:"customer"`
+	}
+	if err := sqlx.GetContext(ctx, db, &used, q, prm.ID, userID); err != nil {
+		return errors.Wrapf(err, "counting redemptions of %s", prm.ID)
+	}
+
+	if prm.UsageLimit > 0 && used.Total >= prm.UsageLimit {
+		return ErrUsageLimit
+	}
+	if prm.CustomerLimit > 0 && userID != "" && used.Customer >= prm.CustomerLimit {
+		return ErrUsageLimit
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package promotion_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/promotion"
+	"github.com/igorbelousov/shop-backend/internal/tests"
+	"github.com/pkg/errors"
+)
+
+func TestPromotion(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+	testID := 0
+	p := promotion.New(log, db)
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "Shop backend",
+			Subject:   tests.UserID,
+			Audience:  "SHOP",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		Roles: []string{auth.RoleAdmin},
+	}
+
+	np := promotion.NewPromotion{
+		Title:         "Ten percent off",
+		Code:          tests.StringPointer("TEN"),
+		Type:          promotion.TypePercentage,
+		Value:         10,
+		MinSubtotal:   100,
+		CustomerLimit: 1,
+	}
+
+	prm, err := p.Create(ctx, traceID, claims, np, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create promotion : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to create promotion.", tests.Success, testID)
+
+	np.Code = tests.StringPointer("ten")
+	if _, err := p.Create(ctx, traceID, claims, np, now); err != promotion.ErrCodeTaken {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to reuse a coupon code : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to reuse a coupon code.", tests.Success, testID)
+
+	saved, err := p.QueryByCode(ctx, traceID, "ten")
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve promotion by code: %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to retrieve promotion by code.", tests.Success, testID)
+
+	if diff := cmp.Diff(prm, saved); diff != "" {
+		t.Fatalf("\t%s\tTest %d:\tShould get back the same promotion. Diff:\n%s", tests.Failed, testID, diff)
+	}
+	t.Logf("\t%s\tTest %d:\tShould get back the same promotion.", tests.Success, testID)
+
+	lines := []promotion.Line{
+		{ProductID: "9097a8f9-c7c0-4e88-81da-72ec34a1dc79", Price: 60, Qty: 2},
+	}
+
+	discounts, err := p.Apply(ctx, traceID, tests.UserID, "TEN", lines, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to apply coupon : %s.", tests.Failed, testID, err)
+	}
+	if len(discounts) != 1 || discounts[0].Amount != 12 {
+		t.Fatalf("\t%s\tTest %d:\tShould get a discount of 12 : %+v.", tests.Failed, testID, discounts)
+	}
+	t.Logf("\t%s\tTest %d:\tShould get a discount of 12.", tests.Success, testID)
+
+	if err := p.Redeem(ctx, traceID, claims, prm.ID, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to redeem coupon : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to redeem coupon.", tests.Success, testID)
+
+	if _, err := p.Apply(ctx, traceID, tests.UserID, "TEN", lines, now); errors.Cause(err) != promotion.ErrUsageLimit {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to apply coupon twice : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to apply coupon twice.", tests.Success, testID)
+
+	before := now.Add(-time.Hour)
+	upd := promotion.UpdatePromotion{
+		StartsAt: &now,
+		EndsAt:   &before,
+	}
+
+	if err := p.Update(ctx, traceID, claims, prm.ID, upd, now); err != promotion.ErrInvalidWindow {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to end a promotion before it starts : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to end a promotion before it starts.", tests.Success, testID)
+
+	// The end is sent in another time zone, it must still be the same
+	// instant once stored.
+	end := now.In(time.FixedZone("MSK", 3*60*60))
+	upd = promotion.UpdatePromotion{
+		EndsAt: &end,
+	}
+
+	if err := p.Update(ctx, traceID, claims, prm.ID, upd, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to update promotion : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to update promotion.", tests.Success, testID)
+
+	if _, err := p.Apply(ctx, traceID, "", "TEN", lines, now); errors.Cause(err) != promotion.ErrInvalidCode {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to apply expired coupon : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to apply expired coupon.", tests.Success, testID)
+
+	nb := promotion.NewPromotion{
+		Title:         "Buy 2 get 1",
+		Code:          tests.StringPointer("FREE"),
+		Type:          promotion.TypeBuyXGetY,
+		Automatic:     true,
+		BuyQty:        2,
+		GetQty:        1,
+		CustomerLimit: 1,
+	}
+
+	auto, err := p.Create(ctx, traceID, claims, nb, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create automatic promotion : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to create automatic promotion.", tests.Success, testID)
+
+	lines = []promotion.Line{
+		{ProductID: "9097a8f9-c7c0-4e88-81da-72ec34a1dc79", Price: 60, Qty: 2},
+		{ProductID: "00000000-0000-0000-0000-000000000001", Price: 15, Qty: 1},
+	}
+
+	discounts, err = p.Apply(ctx, traceID, "", "", lines, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to apply automatic promotions : %s.", tests.Failed, testID, err)
+	}
+	if len(discounts) != 1 || discounts[0].Amount != 15 {
+		t.Fatalf("\t%s\tTest %d:\tShould get the cheapest item for free : %+v.", tests.Failed, testID, discounts)
+	}
+	t.Logf("\t%s\tTest %d:\tShould get the cheapest item for free.", tests.Success, testID)
+
+	discounts, err = p.Apply(ctx, traceID, tests.UserID, "FREE", lines, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to enter the code of an automatic promotion : %s.", tests.Failed, testID, err)
+	}
+	if len(discounts) != 1 || discounts[0].Amount != 15 {
+		t.Fatalf("\t%s\tTest %d:\tShould apply an automatic promotion once : %+v.", tests.Failed, testID, discounts)
+	}
+	t.Logf("\t%s\tTest %d:\tShould apply an automatic promotion once.", tests.Success, testID)
+
+	if err := p.Redeem(ctx, traceID, claims, auto.ID, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to redeem automatic promotion : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to redeem automatic promotion.", tests.Success, testID)
+
+	discounts, err = p.Apply(ctx, traceID, tests.UserID, "", lines, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to apply automatic promotions : %s.", tests.Failed, testID, err)
+	}
+	if len(discounts) != 0 {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT apply a used up automatic promotion : %+v.", tests.Failed, testID, discounts)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT apply a used up automatic promotion.", tests.Success, testID)
+
+	if err := p.Delete(ctx, traceID, claims, prm.ID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to delete promotion : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to delete promotion.", tests.Success, testID)
+
+	_, err = p.QueryByID(ctx, traceID, prm.ID)
+	if errors.Cause(err) != promotion.ErrNotFound {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve promotion : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve promotion.", tests.Success, testID)
+}
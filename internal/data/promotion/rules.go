@@ -0,0 +1,139 @@
+package promotion
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Active reports whether the promotion's validity window contains now.
+func (prm Info) Active(now time.Time) bool {
+	if prm.StartsAt != nil && now.Before(*prm.StartsAt) {
+		return false
+	}
+	if prm.EndsAt != nil && !now.Before(*prm.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// validWindow reports whether a validity window ends after it starts. An
+// open end is always valid.
+func validWindow(starts, ends *time.Time) bool {
+	return starts == nil || ends == nil || ends.After(*starts)
+}
+
+// utc returns a UTC copy of the optional time. The timestamp columns keep no
+// time zone, so every time is stored in UTC.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// eligible reports whether a cart line satisfies the category and brand
+// conditions of the promotion.
+func (prm Info) eligible(l Line) bool {
+	if prm.CategoryID != nil && *prm.CategoryID != l.CategoryID {
+		return false
+	}
+	if prm.BrandID != nil && *prm.BrandID != l.BrandID {
+		return false
+	}
+	return true
+}
+
+// Evaluate applies the promotions to the cart lines and returns a discount
+// for every promotion whose conditions are met. The sum of the discounts
+// never exceeds the cart subtotal.
+func Evaluate(promotions []Info, lines []Line) []Discount {
+	var subtotal float64
+	for _, l := range lines {
+		subtotal += l.Price * float64(l.Qty)
+	}
+
+	discounts := []Discount{}
+	remaining := subtotal
+	for _, prm := range promotions {
+		if subtotal < prm.MinSubtotal {
+			continue
+		}
+
+		d, ok := prm.discount(lines)
+		if !ok {
+			continue
+		}
+
+		d.Amount = math.Min(round(d.Amount), remaining)
+		remaining -= d.Amount
+		discounts = append(discounts, d)
+	}
+
+	return discounts
+}
+
+// discount calculates the discount a single promotion grants on the lines.
+func (prm Info) discount(lines []Line) (Discount, bool) {
+	d := Discount{
+		PromotionID: prm.ID,
+		Title:       prm.Title,
+		Type:        prm.Type,
+	}
+	if prm.Code != nil {
+		d.Code = *prm.Code
+	}
+
+	var eligible float64
+	var units []float64
+	for _, l := range lines {
+		if !prm.eligible(l) {
+			continue
+		}
+		eligible += l.Price * float64(l.Qty)
+		for i := 0; i < l.Qty; i++ {
+			units = append(units, l.Price)
+		}
+	}
+	if len(units) == 0 {
+		return Discount{}, false
+	}
+
+	switch prm.Type {
+	case TypePercentage:
+		d.Amount = eligible * math.Min(prm.Value, 100) / 100
+
+	case TypeFixed:
+		d.Amount = math.Min(prm.Value, eligible)
+
+	case TypeFreeShipping:
+		d.FreeShipping = true
+
+	case TypeBuyXGetY:
+
+		// For every BuyQty+GetQty units in the cart the cheapest GetQty
+		// units are free.
+		if prm.BuyQty <= 0 || prm.GetQty <= 0 {
+			return Discount{}, false
+		}
+		free := len(units) / (prm.BuyQty + prm.GetQty) * prm.GetQty
+		if free == 0 {
+			return Discount{}, false
+		}
+		sort.Float64s(units)
+		for _, price := range units[:free] {
+			d.Amount += price
+		}
+
+	default:
+		return Discount{}, false
+	}
+
+	return d, true
+}
+
+// round rounds an amount of money to cents.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,52 @@
+package review
+
+import (
+	"time"
+)
+
+// These are the moderation states of a review. Only approved reviews are
+// shown to shoppers and count towards the product rating.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// These are the supported orderings of product reviews.
+const (
+	SortHelpful = "helpful"
+	SortNewest  = "newest"
+	SortRating  = "rating"
+)
+
+// Info represents an individual Review.
+type Info struct {
+	ID          string    `db:"review_id" json:"id"`
+	ProductID   string    `db:"product_id" json:"product_id"`
+	UserID      string    `db:"user_id" json:"user_id"`
+	Rating      int       `db:"rating" json:"rating"`
+	Title       string    `db:"title" json:"title"`
+	Body        string    `db:"body" json:"body"`
+	Status      string    `db:"status" json:"status"`
+	Verified    bool      `db:"verified" json:"verified"`
+	Helpful     int       `db:"helpful" json:"helpful"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+}
+
+// NewReview contains information needed to create a new Review.
+type NewReview struct {
+	Rating int    `json:"rating" validate:"required,min=1,max=5"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+// Moderation contains the new moderation state of a Review.
+type Moderation struct {
+	Status string `json:"status" validate:"required,oneof=pending approved rejected"`
+}
+
+// Vote is a shopper's opinion on whether a Review was helpful.
+type Vote struct {
+	Helpful bool `json:"helpful"`
+}
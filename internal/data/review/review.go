@@ -0,0 +1,348 @@
+// Package review contains product reviews, their moderation and ratings.
+package review
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific Review is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+
+	// ErrAlreadyReviewed occurs when a user reviews the same product twice.
+	ErrAlreadyReviewed = errors.New("product already reviewed")
+)
+
+// orderings maps the supported sort orders to their ORDER BY clause.
+var orderings = map[string]string{
+	SortHelpful: "helpful DESC, date_created DESC",
+	SortNewest:  "date_created DESC",
+	SortRating:  "rating DESC, date_created DESC",
+}
+
+// Review manages the set of API's for review access.
+type Review struct {
+	log *logger.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a Review for api access.
+func New(log *logger.Logger, db *sqlx.DB) Review {
+	return Review{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new review of the product by the claims' user. New
+// reviews wait for moderation before they are shown.
+func (rv Review) Create(ctx context.Context, traceID string, claims auth.Claims, productID string, nr NewReview, now time.Time) (Info, error) {
+	if _, err := uuid.Parse(productID); err != nil {
+		return Info{}, ErrInvalidID
+	}
+
+	// Verified is reserved for customers with a delivered order line for the
+	// product. There are no orders to check yet, so it stays false.
+	rev := Info{
+		ID:          uuid.New().String(),
+		ProductID:   productID,
+		UserID:      claims.Subject,
+		Rating:      nr.Rating,
+		Title:       nr.Title,
+		Body:        nr.Body,
+		Status:      StatusPending,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO reviews
+		(review_id, product_id, user_id, rating, title, body, status, verified, helpful, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	rv.log.Debug("review.Create", logger.TraceID(traceID),
+		database.Log(q, rev.ID, rev.ProductID, rev.UserID, rev.Rating, rev.Title, rev.Body, rev.Status, rev.Verified, rev.Helpful, rev.DateCreated, rev.DateUpdated),
+	)
+
+	if _, err := rv.db.ExecContext(ctx, q, rev.ID, rev.ProductID, rev.UserID, rev.Rating, rev.Title, rev.Body, rev.Status, rev.Verified, rev.Helpful, rev.DateCreated, rev.DateUpdated); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code.Name() {
+			case "unique_violation":
+				return Info{}, ErrAlreadyReviewed
+			case "foreign_key_violation":
+				return Info{}, ErrNotFound
+			}
+		}
+		return Info{}, errors.Wrap(err, "inserting review")
+	}
+
+	return rev, nil
+}
+
+// Moderate sets the moderation status of a review and updates the rating of
+// its product.
+func (rv Review) Moderate(ctx context.Context, traceID string, claims auth.Claims, reviewID string, m Moderation, now time.Time) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+
+	rev, err := rv.QueryByID(ctx, traceID, reviewID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := rv.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	UPDATE
+		reviews
+	SET
+		"status" = $2,
+		"date_updated" = $3
+	WHERE
+		review_id = $1`
+
+	rv.log.Debug("review.Moderate", logger.TraceID(traceID),
+		database.Log(q, rev.ID, m.Status, now),
+	)
+
+	if _, err := tx.ExecContext(ctx, q, rev.ID, m.Status, now); err != nil {
+		return errors.Wrapf(err, "moderating review %s", rev.ID)
+	}
+
+	if err := rv.refreshRating(ctx, traceID, tx, rev.ProductID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a review from the database. Admins can delete any review,
+// users only their own.
+func (rv Review) Delete(ctx context.Context, traceID string, claims auth.Claims, reviewID string) error {
+	rev, err := rv.QueryByID(ctx, traceID, reviewID)
+	if err != nil {
+		return err
+	}
+
+	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != rev.UserID {
+		return ErrForbidden
+	}
+
+	tx, err := rv.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	DELETE FROM
+		reviews
+	WHERE
+		review_id = $1`
+
+	rv.log.Debug("review.Delete", logger.TraceID(traceID),
+		database.Log(q, rev.ID),
+	)
+
+	if _, err := tx.ExecContext(ctx, q, rev.ID); err != nil {
+		return errors.Wrapf(err, "deleting review %s", rev.ID)
+	}
+
+	if err := rv.refreshRating(ctx, traceID, tx, rev.ProductID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Vote records whether the claims' user found a review helpful. Voting again
+// replaces the previous vote.
+func (rv Review) Vote(ctx context.Context, traceID string, claims auth.Claims, reviewID string, vote Vote) error {
+	rev, err := rv.QueryByID(ctx, traceID, reviewID)
+	if err != nil {
+		return err
+	}
+
+	if rev.Status != StatusApproved {
+		return ErrNotFound
+	}
+
+	tx, err := rv.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	INSERT INTO review_votes
+		(review_id, user_id, helpful)
+	VALUES
+		($1, $2, $3)
+	ON CONFLICT (review_id, user_id) DO UPDATE SET
+		helpful = EXCLUDED.helpful`
+
+	rv.log.Debug("review.Vote", logger.TraceID(traceID),
+		database.Log(q, rev.ID, claims.Subject, vote.Helpful),
+	)
+
+	if _, err := tx.ExecContext(ctx, q, rev.ID, claims.Subject, vote.Helpful); err != nil {
+		return errors.Wrapf(err, "voting on review %s", rev.ID)
+	}
+
+	const qh = `
+	UPDATE
+		reviews
+	SET
+		"helpful" = (SELECT count(*) FROM review_votes WHERE review_id = $1 AND helpful)
+	WHERE
+		review_id = $1`
+
+	rv.log.Debug("review.Vote", logger.TraceID(traceID),
+		database.Log(qh, rev.ID),
+	)
+
+	if _, err := tx.ExecContext(ctx, qh, rev.ID); err != nil {
+		return errors.Wrapf(err, "counting votes of review %s", rev.ID)
+	}
+
+	return tx.Commit()
+}
+
+// QueryByID gets the specified review from the database.
+func (rv Review) QueryByID(ctx context.Context, traceID string, reviewID string) (Info, error) {
+	if _, err := uuid.Parse(reviewID); err != nil {
+		return Info{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		reviews
+	WHERE
+		review_id = $1`
+
+	rv.log.Debug("review.QueryByID", logger.TraceID(traceID),
+		database.Log(q, reviewID),
+	)
+
+	var rev Info
+	if err := rv.db.GetContext(ctx, &rev, q, reviewID); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting review %q", reviewID)
+	}
+
+	return rev, nil
+}
+
+// QueryByProduct retrieves a page of the approved reviews of a product in
+// the requested order. Unknown orders fall back to SortHelpful.
+func (rv Review) QueryByProduct(ctx context.Context, traceID string, productID string, sort string, pageNumber int, rowsPerPage int) ([]Info, error) {
+	if _, err := uuid.Parse(productID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	orderBy, ok := orderings[sort]
+	if !ok {
+		orderBy = orderings[SortHelpful]
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		reviews
+	WHERE
+		product_id = $1 AND status = $2
+	ORDER BY
+		` + orderBy + `
+	OFFSET $3 ROWS FETCH NEXT $4 ROWS ONLY`
+
+	offset := (pageNumber - 1) * rowsPerPage
+
+	rv.log.Debug("review.QueryByProduct", logger.TraceID(traceID),
+		database.Log(q, productID, StatusApproved, offset, rowsPerPage),
+	)
+
+	reviews := []Info{}
+	if err := rv.db.SelectContext(ctx, &reviews, q, productID, StatusApproved, offset, rowsPerPage); err != nil {
+		return nil, errors.Wrapf(err, "selecting reviews of %q", productID)
+	}
+
+	return reviews, nil
+}
+
+// QueryByStatus retrieves a page of reviews in a moderation state, oldest
+// first.
+func (rv Review) QueryByStatus(ctx context.Context, traceID string, status string, pageNumber int, rowsPerPage int) ([]Info, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		reviews
+	WHERE
+		status = $1
+	ORDER BY
+		date_created
+	OFFSET $2 ROWS FETCH NEXT $3 ROWS ONLY`
+
+	offset := (pageNumber - 1) * rowsPerPage
+
+	rv.log.Debug("review.QueryByStatus", logger.TraceID(traceID),
+		database.Log(q, status, offset, rowsPerPage),
+	)
+
+	reviews := []Info{}
+	if err := rv.db.SelectContext(ctx, &reviews, q, status, offset, rowsPerPage); err != nil {
+		return nil, errors.Wrap(err, "selecting reviews")
+	}
+
+	return reviews, nil
+}
+
+// refreshRating recalculates the average rating and review count stored on
+// the product from its approved reviews.
+func (rv Review) refreshRating(ctx context.Context, traceID string, tx sqlx.ExecerContext, productID string) error {
+	const q = `
+	UPDATE
+		products
+	SET
+		"rating" = COALESCE((SELECT avg(rating) FROM reviews WHERE product_id = $1 AND status = $2), 0),
+		"rating_count" = (SELECT count(*) FROM reviews WHERE product_id = $1 AND status = $2)
+	WHERE
+		product_id = $1`
+
+	rv.log.Debug("review.refreshRating", logger.TraceID(traceID),
+		database.Log(q, productID, StatusApproved),
+	)
+
+	if _, err := tx.ExecContext(ctx, q, productID, StatusApproved); err != nil {
+		return errors.Wrapf(err, "updating rating of product %s", productID)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package review_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/review"
+	"github.com/igorbelousov/shop-backend/internal/tests"
+)
+
+func TestReview(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+	testID := 0
+	rv := review.New(log, db)
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+	productID := "9097a8f9-c7c0-4e88-81da-72ec34a1dc79"
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "Shop backend",
+			Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+			Audience:  "SHOP",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		Roles: []string{auth.RoleAdmin},
+	}
+
+	rev, err := rv.Create(ctx, traceID, claims, productID, review.NewReview{Rating: 4, Title: "Good"}, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create review : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to create review.", tests.Success, testID)
+
+	if _, err := rv.Create(ctx, traceID, claims, productID, review.NewReview{Rating: 5}, now); err != review.ErrAlreadyReviewed {
+		t.Fatalf("\t%s\tTest %d:\tShould not be able to review twice : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould not be able to review twice.", tests.Success, testID)
+
+	reviews, err := rv.QueryByProduct(ctx, traceID, productID, review.SortNewest, 1, 10)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to query reviews : %s.", tests.Failed, testID, err)
+	}
+	if len(reviews) != 0 {
+		t.Fatalf("\t%s\tTest %d:\tShould not see pending reviews : %d.", tests.Failed, testID, len(reviews))
+	}
+	t.Logf("\t%s\tTest %d:\tShould not see pending reviews.", tests.Success, testID)
+
+	if err := rv.Moderate(ctx, traceID, claims, rev.ID, review.Moderation{Status: review.StatusApproved}, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to approve review : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to approve review.", tests.Success, testID)
+
+	var rating struct {
+		Rating float64 `db:"rating"`
+		Count  int     `db:"rating_count"`
+	}
+	if err := db.GetContext(ctx, &rating, `SELECT rating, rating_count FROM products WHERE product_id = $1`, productID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to read product rating : %s.", tests.Failed, testID, err)
+	}
+	if rating.Rating != 4 || rating.Count != 1 {
+		t.Fatalf("\t%s\tTest %d:\tShould get rating 4 of 1 review : %+v.", tests.Failed, testID, rating)
+	}
+	t.Logf("\t%s\tTest %d:\tShould get rating 4 of 1 review.", tests.Success, testID)
+
+	if err := rv.Vote(ctx, traceID, claims, rev.ID, review.Vote{Helpful: true}); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to vote : %s.", tests.Failed, testID, err)
+	}
+	if err := rv.Vote(ctx, traceID, claims, rev.ID, review.Vote{Helpful: true}); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to vote again : %s.", tests.Failed, testID, err)
+	}
+
+	saved, err := rv.QueryByID(ctx, traceID, rev.ID)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve review : %s.", tests.Failed, testID, err)
+	}
+	if saved.Helpful != 1 {
+		t.Fatalf("\t%s\tTest %d:\tShould count one helpful vote : %d.", tests.Failed, testID, saved.Helpful)
+	}
+	t.Logf("\t%s\tTest %d:\tShould count one helpful vote.", tests.Success, testID)
+
+	if err := rv.Delete(ctx, traceID, claims, rev.ID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to delete review : %s.", tests.Failed, testID, err)
+	}
+	if _, err := rv.QueryByID(ctx, traceID, rev.ID); err != review.ErrNotFound {
+		t.Fatalf("\t%s\tTest %d:\tShould not be able to retrieve deleted review : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to delete review.", tests.Success, testID)
+}
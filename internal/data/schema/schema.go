@@ -155,4 +155,348 @@ CREATE TABLE articles (
 	FOREIGN KEY (category_id) REFERENCES article_categories(category_id) ON DELETE SET NULL
 	);`,
 	},
+	{
+		Version:     1.7,
+		Description: "Create table Promotions",
+		Script: `
+CREATE TABLE promotions (
+	promotion_id       UUID,
+	title          TEXT NOT NULL,
+	code         TEXT,
+	type         TEXT NOT NULL,
+	value NUMERIC(15,2) NOT NULL DEFAULT 0.00,
+	automatic BOOLEAN NOT NULL DEFAULT FALSE,
+	min_subtotal NUMERIC(15,2) NOT NULL DEFAULT 0.00,
+	category_id   UUID,
+	brand_id   UUID,
+	buy_qty INT NOT NULL DEFAULT 0,
+	get_qty INT NOT NULL DEFAULT 0,
+	usage_limit INT NOT NULL DEFAULT 0,
+	customer_limit INT NOT NULL DEFAULT 0,
+	starts_at  TIMESTAMP,
+	ends_at  TIMESTAMP,
+	date_created  TIMESTAMP,
+	date_updated  TIMESTAMP,
+
+	PRIMARY KEY (promotion_id),
+	FOREIGN KEY (category_id) REFERENCES categories(category_id) ON DELETE CASCADE,
+	FOREIGN KEY (brand_id) REFERENCES brands(brand_id) ON DELETE CASCADE
+	);
+CREATE UNIQUE INDEX promotions_code_idx ON promotions (upper(code)) WHERE code IS NOT NULL;
+
+CREATE TABLE promotion_redemptions (
+	redemption_id       UUID,
+	promotion_id       UUID NOT NULL,
+	user_id       UUID NOT NULL,
+	date_created  TIMESTAMP,
+
+	PRIMARY KEY (redemption_id),
+	FOREIGN KEY (promotion_id) REFERENCES promotions(promotion_id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+	);`,
+	},
+	{
+		Version:     1.8,
+		Description: "Create tables Price Schedules and Product Prices",
+		Script: `
+CREATE TABLE price_schedules (
+	schedule_id       UUID,
+	product_id   UUID,
+	category_id   UUID,
+	price NUMERIC(15,2) NOT NULL DEFAULT 0.00,
+	percent NUMERIC(5,2) NOT NULL DEFAULT 0.00,
+	starts_at  TIMESTAMP NOT NULL,
+	ends_at  TIMESTAMP,
+	date_created  TIMESTAMP,
+
+	PRIMARY KEY (schedule_id),
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+	FOREIGN KEY (category_id) REFERENCES categories(category_id) ON DELETE CASCADE,
+	CHECK ((product_id IS NULL) <> (category_id IS NULL))
+	);
+
+CREATE TABLE product_prices (
+	product_id   UUID NOT NULL,
+	price NUMERIC(15,2) NOT NULL,
+	old_price NUMERIC(15,2) NOT NULL,
+	date_from  TIMESTAMP NOT NULL,
+
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+	);
+CREATE INDEX product_prices_product_idx ON product_prices (product_id, date_from);`,
+	},
+	{
+		Version:     1.9,
+		Description: "Create tables Shipping Zones, Methods and Rates",
+		Script: `
+ALTER TABLE products
+	ADD COLUMN weight NUMERIC(10,3) NOT NULL DEFAULT 0.000,
+	ADD COLUMN length NUMERIC(10,2) NOT NULL DEFAULT 0.00,
+	ADD COLUMN width NUMERIC(10,2) NOT NULL DEFAULT 0.00,
+	ADD COLUMN height NUMERIC(10,2) NOT NULL DEFAULT 0.00;
+
+CREATE TABLE shipping_zones (
+	zone_id       UUID,
+	title          TEXT NOT NULL,
+	countries         TEXT[] NOT NULL,
+	regions         TEXT[] NOT NULL DEFAULT '{}',
+	date_created  TIMESTAMP,
+	date_updated  TIMESTAMP,
+
+	PRIMARY KEY (zone_id)
+	);
+
+CREATE TABLE shipping_methods (
+	method_id       UUID,
+	zone_id       UUID NOT NULL,
+	title          TEXT NOT NULL,
+	kind          TEXT NOT NULL,
+	basis          TEXT NOT NULL,
+	free_threshold NUMERIC(15,2) NOT NULL DEFAULT 0.00,
+	date_created  TIMESTAMP,
+	date_updated  TIMESTAMP,
+
+	PRIMARY KEY (method_id),
+	FOREIGN KEY (zone_id) REFERENCES shipping_zones(zone_id) ON DELETE CASCADE
+	);
+
+CREATE TABLE shipping_rates (
+	method_id       UUID NOT NULL,
+	min NUMERIC(15,3) NOT NULL DEFAULT 0.000,
+	max NUMERIC(15,3) NOT NULL DEFAULT 0.000,
+	price NUMERIC(15,2) NOT NULL DEFAULT 0.00,
+
+	FOREIGN KEY (method_id) REFERENCES shipping_methods(method_id) ON DELETE CASCADE
+	);`,
+	},
+	{
+		Version:     2.0,
+		Description: "Create tables Tax Classes and Tax Rates",
+		Script: `
+CREATE TABLE tax_classes (
+	tax_class_id       UUID,
+	title          TEXT NOT NULL,
+	date_created  TIMESTAMP,
+	date_updated  TIMESTAMP,
+
+	PRIMARY KEY (tax_class_id)
+	);
+
+CREATE TABLE tax_rates (
+	rate_id       UUID,
+	tax_class_id       UUID NOT NULL,
+	title          TEXT NOT NULL,
+	country          TEXT NOT NULL,
+	region          TEXT NOT NULL DEFAULT '',
+	rate NUMERIC(5,2) NOT NULL,
+	date_created  TIMESTAMP,
+
+	PRIMARY KEY (rate_id),
+	FOREIGN KEY (tax_class_id) REFERENCES tax_classes(tax_class_id) ON DELETE CASCADE,
+	UNIQUE (tax_class_id, country, region)
+	);
+
+ALTER TABLE products
+	ADD COLUMN tax_class_id UUID REFERENCES tax_classes(tax_class_id) ON DELETE SET NULL;
+ALTER TABLE categories
+	ADD COLUMN tax_class_id UUID REFERENCES tax_classes(tax_class_id) ON DELETE SET NULL;`,
+	},
+	{
+		Version:     2.1,
+		Description: "Create tables Reviews and Review Votes",
+		Script: `
+CREATE TABLE reviews (
+	review_id       UUID,
+	product_id   UUID NOT NULL,
+	user_id   UUID NOT NULL,
+	rating INT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+	title          TEXT NOT NULL DEFAULT '',
+	body          TEXT NOT NULL DEFAULT '',
+	status          TEXT NOT NULL DEFAULT 'pending',
+	verified BOOLEAN NOT NULL DEFAULT FALSE,
+	helpful INT NOT NULL DEFAULT 0,
+	date_created  TIMESTAMP,
+	date_updated  TIMESTAMP,
+
+	PRIMARY KEY (review_id),
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+	UNIQUE (product_id, user_id)
+	);
+CREATE INDEX reviews_product_status_idx ON reviews (product_id, status);
+
+CREATE TABLE review_votes (
+	review_id       UUID NOT NULL,
+	user_id   UUID NOT NULL,
+	helpful BOOLEAN NOT NULL,
+
+	PRIMARY KEY (review_id, user_id),
+	FOREIGN KEY (review_id) REFERENCES reviews(review_id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+	);
+
+ALTER TABLE products
+	ADD COLUMN rating NUMERIC(3,2) NOT NULL DEFAULT 0.00,
+	ADD COLUMN rating_count INT NOT NULL DEFAULT 0;`,
+	},
+	{
+		Version:     2.2,
+		Description: "Create tables Wishlists and Wishlist Items",
+		Script: `
+CREATE TABLE wishlists (
+	wishlist_id       UUID,
+	user_id   UUID NOT NULL,
+	title          TEXT NOT NULL,
+	share_token          TEXT UNIQUE,
+	date_created  TIMESTAMP,
+	date_updated  TIMESTAMP,
+
+	PRIMARY KEY (wishlist_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+	);
+
+CREATE TABLE wishlist_items (
+	wishlist_id       UUID NOT NULL,
+	product_id   UUID NOT NULL,
+	date_added  TIMESTAMP,
+
+	PRIMARY KEY (wishlist_id, product_id),
+	FOREIGN KEY (wishlist_id) REFERENCES wishlists(wishlist_id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+	);`,
+	},
+	{
+		Version:     2.3,
+		Description: "Create tables Attributes and Product Attributes",
+		Script: `
+CREATE TABLE attributes (
+	attribute_id       UUID,
+	category_id   UUID NOT NULL,
+	code          TEXT NOT NULL,
+	title          TEXT NOT NULL,
+	type          TEXT NOT NULL,
+	unit          TEXT NOT NULL DEFAULT '',
+	options TEXT[] NOT NULL DEFAULT '{}',
+	filterable BOOLEAN NOT NULL DEFAULT FALSE,
+	date_created  TIMESTAMP,
+
+	PRIMARY KEY (attribute_id),
+	FOREIGN KEY (category_id) REFERENCES categories(category_id) ON DELETE CASCADE,
+	UNIQUE (category_id, code)
+	);
+
+CREATE TABLE product_attributes (
+	product_id   UUID NOT NULL,
+	attribute_id       UUID NOT NULL,
+	value          TEXT NOT NULL,
+	number DOUBLE PRECISION,
+
+	PRIMARY KEY (product_id, attribute_id),
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+	FOREIGN KEY (attribute_id) REFERENCES attributes(attribute_id) ON DELETE CASCADE
+	);
+CREATE INDEX product_attributes_value_idx ON product_attributes (attribute_id, value);`,
+	},
+	{
+		Version:     2.4,
+		Description: "Create table Product Relations",
+		Script: `
+CREATE TABLE product_relations (
+	product_id   UUID NOT NULL,
+	related_id   UUID NOT NULL,
+	type          TEXT NOT NULL,
+	position INT NOT NULL DEFAULT 0,
+
+	PRIMARY KEY (product_id, related_id, type),
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+	FOREIGN KEY (related_id) REFERENCES products(product_id) ON DELETE CASCADE
+	);`,
+	},
+	{
+		Version:     2.5,
+		Description: "Create table Viewed Products",
+		Script: `
+CREATE TABLE viewed_products (
+	visitor          TEXT NOT NULL,
+	product_id   UUID NOT NULL,
+	date_viewed  TIMESTAMP,
+
+	PRIMARY KEY (visitor, product_id),
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+	);`,
+	},
+	{
+		Version:     2.6,
+		Description: "Add product stock, create tables Bundles and Bundle Items",
+		Script: `
+ALTER TABLE products
+	ADD COLUMN stock INT NOT NULL DEFAULT 0;
+
+CREATE TABLE bundles (
+	bundle_id       UUID,
+	title          TEXT NOT NULL,
+	slug          TEXT NOT NULL UNIQUE,
+	price NUMERIC(15,2) NOT NULL,
+	image          TEXT NOT NULL DEFAULT '',
+	description          TEXT NOT NULL DEFAULT '',
+	date_created  TIMESTAMP,
+	date_updated  TIMESTAMP,
+
+	PRIMARY KEY (bundle_id)
+	);
+
+CREATE TABLE bundle_items (
+	bundle_id       UUID NOT NULL,
+	product_id   UUID NOT NULL,
+	qty INT NOT NULL CHECK (qty > 0),
+
+	PRIMARY KEY (bundle_id, product_id),
+	FOREIGN KEY (bundle_id) REFERENCES bundles(bundle_id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE RESTRICT
+	);`,
+	},
+	{
+		Version:     2.7,
+		Description: "Create table Subscriptions",
+		Script: `
+CREATE TABLE subscriptions (
+	subscription_id       UUID,
+	product_id   UUID NOT NULL,
+	email          TEXT NOT NULL,
+	kind          TEXT NOT NULL,
+	price NUMERIC(15,2) NOT NULL,
+	token          TEXT NOT NULL UNIQUE,
+	date_created  TIMESTAMP,
+
+	PRIMARY KEY (subscription_id),
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+	UNIQUE (product_id, email, kind)
+	);`,
+	},
+	{
+		Version:     2.8,
+		Description: "Add product availability modes",
+		Script: `
+ALTER TABLE products
+	ADD COLUMN availability TEXT NOT NULL DEFAULT 'in_stock',
+	ADD COLUMN release_date TIMESTAMP,
+	ADD COLUMN preorder_limit INT NOT NULL DEFAULT 0;
+
+-- Existing products stay in stock and follow the stock tracked since 2.6,
+-- so subscriptions to sold out products keep working. Products sold
+-- without counting stock must be switched to backorder by an admin.`,
+	},
+	{
+		Version:     2.9,
+		Description: "Create table Login Attempts",
+		Script: `
+CREATE TABLE login_attempts (
+	email          TEXT,
+	failures       INT NOT NULL,
+	locked_until   TIMESTAMP,
+	date_updated   TIMESTAMP,
+
+	PRIMARY KEY (email)
+	);`,
+	},
 }
@@ -43,8 +43,8 @@ INSERT INTO users (user_id, name, email, roles, password_hash, date_created, dat
 	('84fc7ad7-0f6c-4938-9cec-bb8f55953709', 'Brand Title', 'brand-title', 'description text', 'link-to-image', '','','', '2020-02-04 00:00:00', '2020-02-04 00:00:00')
 	ON CONFLICT DO NOTHING;
 	INSERT INTO products
-	(product_id, title, slug, category_id, brand_id, price,  description, short_description, image, meta_description, meta_title, meta_keywords,  date_created, date_updated) VALUES
-	('9097a8f9-c7c0-4e88-81da-72ec34a1dc79', 'Product Title', 'product-title', '00000000-0000-0000-0000-000000000000', '84fc7ad7-0f6c-4938-9cec-bb8f55953709', '3535.23', 'description text','', 'link-to-image', '','','','2020-02-04 00:00:00', '2020-02-04 00:00:00')
+	(product_id, title, slug, category_id, brand_id, price,  description, short_description, image, meta_description, meta_title, meta_keywords, availability, date_created, date_updated) VALUES
+	('9097a8f9-c7c0-4e88-81da-72ec34a1dc79', 'Product Title', 'product-title', '00000000-0000-0000-0000-000000000000', '84fc7ad7-0f6c-4938-9cec-bb8f55953709', '3535.23', 'description text','', 'link-to-image', '','','', 'backorder', '2020-02-04 00:00:00', '2020-02-04 00:00:00')
 	ON CONFLICT DO NOTHING;
 	INSERT INTO article_categories
 	(category_id, title, slug,  image, description, meta_title, meta_keywords, meta_description, date_created, date_updated)
@@ -75,10 +75,23 @@ func DeleteAll(db *sqlx.DB) error {
 // deleteAll is used to clean the database between tests.
 const deleteAll = `
 DELETE FROM users;
+DELETE FROM bundles;
 DELETE FROM categories;
 DELETE FROM products;
 DELETE FROM brands;
 DELETE FROM articles;
 DELETE FROM article_categories;
 DELETE FROM slides;
+DELETE FROM promotion_redemptions;
+DELETE FROM promotions;
+DELETE FROM price_schedules;
+DELETE FROM product_prices;
+DELETE FROM shipping_zones;
+DELETE FROM tax_classes;
+DELETE FROM reviews;
+DELETE FROM wishlists;
+DELETE FROM attributes;
+DELETE FROM viewed_products;
+DELETE FROM subscriptions;
+DELETE FROM login_attempts;
 `
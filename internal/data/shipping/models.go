@@ -0,0 +1,109 @@
+package shipping
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// These are the supported values for Method.Kind.
+const (
+	KindCourier = "courier"
+	KindPickup  = "pickup"
+	KindPost    = "post"
+)
+
+// These are the supported values for Method.Basis, the cart value the rate
+// table of a method is looked up by.
+const (
+	BasisWeight   = "weight"
+	BasisSubtotal = "subtotal"
+)
+
+// Zone represents a set of countries, optionally narrowed down to regions,
+// sharing the same shipping methods.
+type Zone struct {
+	ID          string         `db:"zone_id" json:"id"`
+	Title       string         `db:"title" json:"title"`
+	Countries   pq.StringArray `db:"countries" json:"countries"`
+	Regions     pq.StringArray `db:"regions" json:"regions"`
+	DateCreated time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated time.Time      `db:"date_updated" json:"date_updated"`
+	Methods     []Method       `db:"-" json:"methods"`
+}
+
+// NewZone contains information needed to create a new Zone.
+type NewZone struct {
+	Title     string   `json:"title" validate:"required"`
+	Countries []string `json:"countries" validate:"required,min=1,dive,len=2"`
+	Regions   []string `json:"regions"`
+}
+
+// UpdateZone defines what information may be provided to modify an existing
+// Zone. All fields are optional, an empty list of regions makes the zone
+// cover the whole countries.
+type UpdateZone struct {
+	Title     *string  `json:"title"`
+	Countries []string `json:"countries" validate:"omitempty,min=1,dive,len=2"`
+	Regions   []string `json:"regions"`
+}
+
+// Method represents a way of delivery available in a zone.
+type Method struct {
+	ID            string    `db:"method_id" json:"id"`
+	ZoneID        string    `db:"zone_id" json:"zone_id"`
+	Title         string    `db:"title" json:"title"`
+	Kind          string    `db:"kind" json:"kind"`
+	Basis         string    `db:"basis" json:"basis"`
+	FreeThreshold float64   `db:"free_threshold" json:"free_threshold"`
+	DateCreated   time.Time `db:"date_created" json:"date_created"`
+	DateUpdated   time.Time `db:"date_updated" json:"date_updated"`
+	Rates         []Rate    `db:"-" json:"rates"`
+}
+
+// NewMethod contains information needed to create a new Method.
+type NewMethod struct {
+	ZoneID        string  `json:"zone_id" validate:"required,uuid"`
+	Title         string  `json:"title" validate:"required"`
+	Kind          string  `json:"kind" validate:"required,oneof=courier pickup post"`
+	Basis         string  `json:"basis" validate:"required,oneof=weight subtotal"`
+	FreeThreshold float64 `json:"free_threshold" validate:"gte=0"`
+	Rates         []Rate  `json:"rates" validate:"required,min=1,dive"`
+}
+
+// UpdateMethod defines what information may be provided to modify an
+// existing Method. All fields are optional.
+type UpdateMethod struct {
+	ZoneID        *string  `json:"zone_id" validate:"omitempty,uuid"`
+	Title         *string  `json:"title"`
+	Kind          *string  `json:"kind" validate:"omitempty,oneof=courier pickup post"`
+	Basis         *string  `json:"basis" validate:"omitempty,oneof=weight subtotal"`
+	FreeThreshold *float64 `json:"free_threshold" validate:"omitempty,gte=0"`
+	Rates         []Rate   `json:"rates" validate:"omitempty,min=1,dive"`
+}
+
+// Rate is a row of the rate table of a method. It applies to carts whose
+// weight or subtotal is at least Min and below Max, a Max of 0 has no upper
+// bound.
+type Rate struct {
+	MethodID string  `db:"method_id" json:"-"`
+	Min      float64 `db:"min" json:"min" validate:"gte=0"`
+	Max      float64 `db:"max" json:"max" validate:"gte=0"`
+	Price    float64 `db:"price" json:"price" validate:"gte=0"`
+}
+
+// Address is where a cart is shipped to. Country is an ISO 3166-1 alpha-2
+// code.
+type Address struct {
+	Country string `json:"country" validate:"required,len=2"`
+	Region  string `json:"region"`
+}
+
+// Quote is the price of delivering a cart with a method.
+type Quote struct {
+	MethodID string  `json:"method_id"`
+	Title    string  `json:"title"`
+	Kind     string  `json:"kind"`
+	Price    float64 `json:"price"`
+	Free     bool    `json:"free"`
+}
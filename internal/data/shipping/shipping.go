@@ -0,0 +1,602 @@
+// Package shipping contains shipping zones, methods and rate calculation.
+package shipping
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific Zone or Method is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+
+	// ErrUnknownZone occurs when a method is added to a zone which does not exist.
+	ErrUnknownZone = errors.New("shipping zone does not exist")
+)
+
+// Shipping manages the set of API's for shipping access.
+type Shipping struct {
+	log *logger.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a Shipping for api access.
+func New(log *logger.Logger, db *sqlx.DB) Shipping {
+	return Shipping{
+		log: log,
+		db:  db,
+	}
+}
+
+// CreateZone inserts a new shipping zone into the database.
+func (s Shipping) CreateZone(ctx context.Context, traceID string, claims auth.Claims, nz NewZone, now time.Time) (Zone, error) {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return Zone{}, ErrForbidden
+	}
+
+	z := Zone{
+		ID:          uuid.New().String(),
+		Title:       nz.Title,
+		Countries:   upper(nz.Countries),
+		Regions:     pq.StringArray{},
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+		Methods:     []Method{},
+	}
+	if nz.Regions != nil {
+		z.Regions = nz.Regions
+	}
+
+	const q = `
+	INSERT INTO shipping_zones
+		(zone_id, title, countries, regions, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5, $6)`
+
+	s.log.Debug("shipping.CreateZone", logger.TraceID(traceID),
+		database.Log(q, z.ID, z.Title, z.Countries, z.Regions, z.DateCreated, z.DateUpdated),
+	)
+
+	if _, err := s.db.ExecContext(ctx, q, z.ID, z.Title, z.Countries, z.Regions, z.DateCreated, z.DateUpdated); err != nil {
+		return Zone{}, errors.Wrap(err, "inserting shipping zone")
+	}
+
+	return z, nil
+}
+
+// UpdateZone modifies a shipping zone.
+func (s Shipping) UpdateZone(ctx context.Context, traceID string, claims auth.Claims, zoneID string, uz UpdateZone, now time.Time) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+
+	z, err := s.queryZone(ctx, traceID, zoneID)
+	if err != nil {
+		return err
+	}
+
+	if uz.Title != nil {
+		z.Title = *uz.Title
+	}
+	if uz.Countries != nil {
+		z.Countries = upper(uz.Countries)
+	}
+	if uz.Regions != nil {
+		z.Regions = uz.Regions
+	}
+	z.DateUpdated = now.UTC()
+
+	const q = `
+	UPDATE
+		shipping_zones
+	SET
+		"title" = $2,
+		"countries" = $3,
+		"regions" = $4,
+		"date_updated" = $5
+	WHERE
+		zone_id = $1`
+
+	s.log.Debug("shipping.UpdateZone", logger.TraceID(traceID),
+		database.Log(q, z.ID, z.Title, z.Countries, z.Regions, z.DateUpdated),
+	)
+
+	if _, err := s.db.ExecContext(ctx, q, z.ID, z.Title, z.Countries, z.Regions, z.DateUpdated); err != nil {
+		return errors.Wrapf(err, "updating shipping zone %s", z.ID)
+	}
+
+	return nil
+}
+
+// DeleteZone removes a shipping zone and its methods from the database.
+func (s Shipping) DeleteZone(ctx context.Context, traceID string, claims auth.Claims, zoneID string) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if _, err := uuid.Parse(zoneID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	DELETE FROM
+		shipping_zones
+	WHERE
+		zone_id = $1`
+
+	s.log.Debug("shipping.DeleteZone", logger.TraceID(traceID),
+		database.Log(q, zoneID),
+	)
+
+	if _, err := s.db.ExecContext(ctx, q, zoneID); err != nil {
+		return errors.Wrapf(err, "deleting shipping zone %s", zoneID)
+	}
+
+	return nil
+}
+
+// QueryZones retrieves the shipping zones with their methods and rates.
+func (s Shipping) QueryZones(ctx context.Context, traceID string) ([]Zone, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		shipping_zones
+	ORDER BY
+		title`
+
+	s.log.Debug("shipping.QueryZones", logger.TraceID(traceID),
+		database.Log(q),
+	)
+
+	zones := []Zone{}
+	if err := s.db.SelectContext(ctx, &zones, q); err != nil {
+		return nil, errors.Wrap(err, "selecting shipping zones")
+	}
+
+	if err := s.loadMethods(ctx, traceID, zones); err != nil {
+		return nil, err
+	}
+
+	return zones, nil
+}
+
+// CreateMethod inserts a new shipping method with its rate table.
+func (s Shipping) CreateMethod(ctx context.Context, traceID string, claims auth.Claims, nm NewMethod, now time.Time) (Method, error) {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return Method{}, ErrForbidden
+	}
+
+	m := Method{
+		ID:            uuid.New().String(),
+		ZoneID:        nm.ZoneID,
+		Title:         nm.Title,
+		Kind:          nm.Kind,
+		Basis:         nm.Basis,
+		FreeThreshold: nm.FreeThreshold,
+		DateCreated:   now.UTC(),
+		DateUpdated:   now.UTC(),
+		Rates:         nm.Rates,
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Method{}, errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	INSERT INTO shipping_methods
+		(method_id, zone_id, title, kind, basis, free_threshold, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	s.log.Debug("shipping.CreateMethod", logger.TraceID(traceID),
+		database.Log(q, m.ID, m.ZoneID, m.Title, m.Kind, m.Basis, m.FreeThreshold, m.DateCreated, m.DateUpdated),
+	)
+
+	if _, err := tx.ExecContext(ctx, q, m.ID, m.ZoneID, m.Title, m.Kind, m.Basis, m.FreeThreshold, m.DateCreated, m.DateUpdated); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+			return Method{}, ErrUnknownZone
+		}
+		return Method{}, errors.Wrap(err, "inserting shipping method")
+	}
+
+	if err := s.setRates(ctx, traceID, tx, m.ID, m.Rates); err != nil {
+		return Method{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Method{}, errors.Wrap(err, "committing shipping method")
+	}
+
+	return m, nil
+}
+
+// UpdateMethod modifies a shipping method. Rates, when given, replace the
+// whole rate table of the method.
+func (s Shipping) UpdateMethod(ctx context.Context, traceID string, claims auth.Claims, methodID string, um UpdateMethod, now time.Time) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+
+	m, err := s.queryMethod(ctx, traceID, methodID)
+	if err != nil {
+		return err
+	}
+
+	if um.ZoneID != nil {
+		m.ZoneID = *um.ZoneID
+	}
+	if um.Title != nil {
+		m.Title = *um.Title
+	}
+	if um.Kind != nil {
+		m.Kind = *um.Kind
+	}
+	if um.Basis != nil {
+		m.Basis = *um.Basis
+	}
+	if um.FreeThreshold != nil {
+		m.FreeThreshold = *um.FreeThreshold
+	}
+	m.DateUpdated = now.UTC()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	UPDATE
+		shipping_methods
+	SET
+		"zone_id" = $2,
+		"title" = $3,
+		"kind" = $4,
+		"basis" = $5,
+		"free_threshold" = $6,
+		"date_updated" = $7
+	WHERE
+		method_id = $1`
+
+	s.log.Debug("shipping.UpdateMethod", logger.TraceID(traceID),
+		database.Log(q, m.ID, m.ZoneID, m.Title, m.Kind, m.Basis, m.FreeThreshold, m.DateUpdated),
+	)
+
+	if _, err := tx.ExecContext(ctx, q, m.ID, m.ZoneID, m.Title, m.Kind, m.Basis, m.FreeThreshold, m.DateUpdated); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+			return ErrUnknownZone
+		}
+		return errors.Wrapf(err, "updating shipping method %s", m.ID)
+	}
+
+	if um.Rates != nil {
+		const qd = `
+		DELETE FROM
+			shipping_rates
+		WHERE
+			method_id = $1`
+
+		s.log.Debug("shipping.UpdateMethod", logger.TraceID(traceID),
+			database.Log(qd, m.ID),
+		)
+
+		if _, err := tx.ExecContext(ctx, qd, m.ID); err != nil {
+			return errors.Wrapf(err, "deleting rates of shipping method %s", m.ID)
+		}
+
+		if err := s.setRates(ctx, traceID, tx, m.ID, um.Rates); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing shipping method")
+	}
+
+	return nil
+}
+
+// DeleteMethod removes a shipping method from the database.
+func (s Shipping) DeleteMethod(ctx context.Context, traceID string, claims auth.Claims, methodID string) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if _, err := uuid.Parse(methodID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	DELETE FROM
+		shipping_methods
+	WHERE
+		method_id = $1`
+
+	s.log.Debug("shipping.DeleteMethod", logger.TraceID(traceID),
+		database.Log(q, methodID),
+	)
+
+	if _, err := s.db.ExecContext(ctx, q, methodID); err != nil {
+		return errors.Wrapf(err, "deleting shipping method %s", methodID)
+	}
+
+	return nil
+}
+
+// Quote returns the methods available for the address with their price for
+// a cart of the given weight and subtotal. When freeShipping is set, for
+// example by a promotion, every available method is free.
+func (s Shipping) Quote(ctx context.Context, traceID string, addr Address, weight float64, subtotal float64, freeShipping bool) ([]Quote, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		shipping_zones
+	WHERE
+		$1 = ANY(countries)`
+
+	country := strings.ToUpper(addr.Country)
+
+	s.log.Debug("shipping.Quote", logger.TraceID(traceID),
+		database.Log(q, country),
+	)
+
+	zones := []Zone{}
+	if err := s.db.SelectContext(ctx, &zones, q, country); err != nil {
+		return nil, errors.Wrap(err, "selecting shipping zones")
+	}
+
+	zone, ok := match(zones, addr.Region)
+	if !ok {
+		return []Quote{}, nil
+	}
+
+	matched := []Zone{zone}
+	if err := s.loadMethods(ctx, traceID, matched); err != nil {
+		return nil, err
+	}
+
+	quotes := []Quote{}
+	for _, m := range matched[0].Methods {
+		price, ok := m.price(weight, subtotal)
+		if !ok {
+			continue
+		}
+
+		free := freeShipping || (m.FreeThreshold > 0 && subtotal >= m.FreeThreshold)
+		if free {
+			price = 0
+		}
+
+		quotes = append(quotes, Quote{
+			MethodID: m.ID,
+			Title:    m.Title,
+			Kind:     m.Kind,
+			Price:    price,
+			Free:     free,
+		})
+	}
+
+	return quotes, nil
+}
+
+// queryZone gets the specified shipping zone without its methods.
+func (s Shipping) queryZone(ctx context.Context, traceID string, zoneID string) (Zone, error) {
+	if _, err := uuid.Parse(zoneID); err != nil {
+		return Zone{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		shipping_zones
+	WHERE
+		zone_id = $1`
+
+	s.log.Debug("shipping.queryZone", logger.TraceID(traceID),
+		database.Log(q, zoneID),
+	)
+
+	var z Zone
+	if err := s.db.GetContext(ctx, &z, q, zoneID); err != nil {
+		if err == sql.ErrNoRows {
+			return Zone{}, ErrNotFound
+		}
+		return Zone{}, errors.Wrapf(err, "selecting shipping zone %q", zoneID)
+	}
+
+	return z, nil
+}
+
+// queryMethod gets the specified shipping method without its rates.
+func (s Shipping) queryMethod(ctx context.Context, traceID string, methodID string) (Method, error) {
+	if _, err := uuid.Parse(methodID); err != nil {
+		return Method{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		shipping_methods
+	WHERE
+		method_id = $1`
+
+	s.log.Debug("shipping.queryMethod", logger.TraceID(traceID),
+		database.Log(q, methodID),
+	)
+
+	var m Method
+	if err := s.db.GetContext(ctx, &m, q, methodID); err != nil {
+		if err == sql.ErrNoRows {
+			return Method{}, ErrNotFound
+		}
+		return Method{}, errors.Wrapf(err, "selecting shipping method %q", methodID)
+	}
+
+	return m, nil
+}
+
+// setRates inserts the rate table of the method.
+func (s Shipping) setRates(ctx context.Context, traceID string, tx *sqlx.Tx, methodID string, rates []Rate) error {
+	const q = `
+	INSERT INTO shipping_rates
+		(method_id, min, max, price)
+	VALUES
+		($1, $2, $3, $4)`
+
+	for i := range rates {
+		rates[i].MethodID = methodID
+		r := rates[i]
+
+		s.log.Debug("shipping.setRates", logger.TraceID(traceID),
+			database.Log(q, r.MethodID, r.Min, r.Max, r.Price),
+		)
+
+		if _, err := tx.ExecContext(ctx, q, r.MethodID, r.Min, r.Max, r.Price); err != nil {
+			return errors.Wrap(err, "inserting shipping rate")
+		}
+	}
+
+	return nil
+}
+
+// loadMethods attaches the methods and their rates to the zones.
+func (s Shipping) loadMethods(ctx context.Context, traceID string, zones []Zone) error {
+	if len(zones) == 0 {
+		return nil
+	}
+
+	ids := make(pq.StringArray, len(zones))
+	for i, z := range zones {
+		ids[i] = z.ID
+	}
+
+	const qm = `
+	SELECT
+		*
+	FROM
+		shipping_methods
+	WHERE
+		zone_id::text = ANY($1)
+	ORDER BY
+		title`
+
+	s.log.Debug("shipping.loadMethods", logger.TraceID(traceID),
+		database.Log(qm, ids),
+	)
+
+	methods := []Method{}
+	if err := s.db.SelectContext(ctx, &methods, qm, ids); err != nil {
+		return errors.Wrap(err, "selecting shipping methods")
+	}
+
+	const qr = `
+	SELECT
+		r.*
+	FROM
+		shipping_rates r
+	JOIN
+		shipping_methods m ON m.method_id = r.method_id
+	WHERE
+		m.zone_id::text = ANY($1)
+	ORDER BY
+		r.min`
+
+	s.log.Debug("shipping.loadMethods", logger.TraceID(traceID),
+		database.Log(qr, ids),
+	)
+
+	rates := []Rate{}
+	if err := s.db.SelectContext(ctx, &rates, qr, ids); err != nil {
+		return errors.Wrap(err, "selecting shipping rates")
+	}
+
+	for i := range methods {
+		methods[i].Rates = []Rate{}
+		for _, r := range rates {
+			if r.MethodID == methods[i].ID {
+				methods[i].Rates = append(methods[i].Rates, r)
+			}
+		}
+	}
+
+	for i := range zones {
+		zones[i].Methods = []Method{}
+		for _, m := range methods {
+			if m.ZoneID == zones[i].ID {
+				zones[i].Methods = append(zones[i].Methods, m)
+			}
+		}
+	}
+
+	return nil
+}
+
+// match picks the zone for a region from the zones of its country. A zone
+// listing the region wins over a zone covering the whole country.
+func match(zones []Zone, region string) (Zone, bool) {
+	var country *Zone
+	for i, z := range zones {
+		if len(z.Regions) == 0 {
+			if country == nil {
+				country = &zones[i]
+			}
+			continue
+		}
+		for _, r := range z.Regions {
+			if region != "" && strings.EqualFold(r, region) {
+				return z, true
+			}
+		}
+	}
+
+	if country == nil {
+		return Zone{}, false
+	}
+	return *country, true
+}
+
+// price looks up the rate for the cart in the rate table of the method. It
+// returns false if no row of the table applies.
+func (m Method) price(weight float64, subtotal float64) (float64, bool) {
+	v := weight
+	if m.Basis == BasisSubtotal {
+		v = subtotal
+	}
+
+	for _, r := range m.Rates {
+		if v >= r.Min && (r.Max == 0 || v < r.Max) {
+			return r.Price, true
+		}
+	}
+
+	return 0, false
+}
+
+// upper returns the country codes in upper case.
+func upper(codes []string) pq.StringArray {
+	out := make(pq.StringArray, len(codes))
+	for i, c := range codes {
+		out[i] = strings.ToUpper(c)
+	}
+	return out
+}
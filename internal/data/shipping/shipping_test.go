@@ -0,0 +1,146 @@
+package shipping_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/shipping"
+	"github.com/igorbelousov/shop-backend/internal/tests"
+)
+
+func TestShipping(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+	testID := 0
+	s := shipping.New(log, db)
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "Shop backend",
+			Subject:   "00000000-0000-0000-0000-000000000000",
+			Audience:  "SHOP",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		Roles: []string{auth.RoleAdmin},
+	}
+
+	country, err := s.CreateZone(ctx, traceID, claims, shipping.NewZone{Title: "Russia", Countries: []string{"ru"}}, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create zone : %s.", tests.Failed, testID, err)
+	}
+	moscow, err := s.CreateZone(ctx, traceID, claims, shipping.NewZone{Title: "Moscow", Countries: []string{"RU"}, Regions: []string{"Moscow"}}, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create zone : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to create zones.", tests.Success, testID)
+
+	nm := shipping.NewMethod{
+		ZoneID: country.ID,
+		Title:  "Post",
+		Kind:   shipping.KindPost,
+		Basis:  shipping.BasisWeight,
+		Rates: []shipping.Rate{
+			{Min: 0, Max: 2, Price: 300},
+			{Min: 2, Max: 20, Price: 700},
+		},
+	}
+	post, err := s.CreateMethod(ctx, traceID, claims, nm, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create method : %s.", tests.Failed, testID, err)
+	}
+
+	nm = shipping.NewMethod{
+		ZoneID:        moscow.ID,
+		Title:         "Courier",
+		Kind:          shipping.KindCourier,
+		Basis:         shipping.BasisSubtotal,
+		FreeThreshold: 5000,
+		Rates: []shipping.Rate{
+			{Min: 0, Price: 400},
+		},
+	}
+	if _, err := s.CreateMethod(ctx, traceID, claims, nm, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create method : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to create methods.", tests.Success, testID)
+
+	quotes, err := s.Quote(ctx, traceID, shipping.Address{Country: "RU", Region: "Tver"}, 3, 1000, false)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to quote shipping : %s.", tests.Failed, testID, err)
+	}
+	if len(quotes) != 1 || quotes[0].Price != 700 {
+		t.Fatalf("\t%s\tTest %d:\tShould get the post rate for 3kg : %+v.", tests.Failed, testID, quotes)
+	}
+	t.Logf("\t%s\tTest %d:\tShould get the post rate for 3kg.", tests.Success, testID)
+
+	quotes, err = s.Quote(ctx, traceID, shipping.Address{Country: "RU", Region: "Moscow"}, 3, 6000, false)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to quote shipping : %s.", tests.Failed, testID, err)
+	}
+	if len(quotes) != 1 || !quotes[0].Free || quotes[0].Price != 0 {
+		t.Fatalf("\t%s\tTest %d:\tShould get free courier delivery over the threshold : %+v.", tests.Failed, testID, quotes)
+	}
+	t.Logf("\t%s\tTest %d:\tShould get free courier delivery over the threshold.", tests.Success, testID)
+
+	nm.ZoneID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+	if _, err := s.CreateMethod(ctx, traceID, claims, nm, now); err != shipping.ErrUnknownZone {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to add a method to an unknown zone : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to add a method to an unknown zone.", tests.Success, testID)
+
+	um := shipping.UpdateMethod{
+		Rates: []shipping.Rate{{Min: 0, Price: 500}},
+	}
+	if err := s.UpdateMethod(ctx, traceID, claims, post.ID, um, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to update method : %s.", tests.Failed, testID, err)
+	}
+	quotes, err = s.Quote(ctx, traceID, shipping.Address{Country: "RU", Region: "Tver"}, 3, 1000, false)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to quote shipping : %s.", tests.Failed, testID, err)
+	}
+	if len(quotes) != 1 || quotes[0].Price != 500 {
+		t.Fatalf("\t%s\tTest %d:\tShould get the updated post rate : %+v.", tests.Failed, testID, quotes)
+	}
+	t.Logf("\t%s\tTest %d:\tShould get the updated post rate.", tests.Success, testID)
+
+	uz := shipping.UpdateZone{
+		Regions: []string{"Moscow", "Tver"},
+	}
+	if err := s.UpdateZone(ctx, traceID, claims, moscow.ID, uz, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to update zone : %s.", tests.Failed, testID, err)
+	}
+	quotes, err = s.Quote(ctx, traceID, shipping.Address{Country: "RU", Region: "Tver"}, 3, 1000, false)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to quote shipping : %s.", tests.Failed, testID, err)
+	}
+	if len(quotes) != 1 || quotes[0].Kind != shipping.KindCourier || quotes[0].Price != 400 {
+		t.Fatalf("\t%s\tTest %d:\tShould get the courier of the extended zone : %+v.", tests.Failed, testID, quotes)
+	}
+	t.Logf("\t%s\tTest %d:\tShould get the courier of the extended zone.", tests.Success, testID)
+
+	if err := s.UpdateZone(ctx, traceID, claims, "45b5fbd3-755f-4379-8f07-a58d4a30fa2f", uz, now); err != shipping.ErrNotFound {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to update an unknown zone : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to update an unknown zone.", tests.Success, testID)
+
+	if err := s.DeleteZone(ctx, traceID, claims, moscow.ID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to delete zone : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to delete zone.", tests.Success, testID)
+
+	zones, err := s.QueryZones(ctx, traceID)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve zones : %s.", tests.Failed, testID, err)
+	}
+	if len(zones) != 1 || len(zones[0].Methods) != 1 || len(zones[0].Methods[0].Rates) != 1 {
+		t.Fatalf("\t%s\tTest %d:\tShould get back the remaining zone with its method : %+v.", tests.Failed, testID, zones)
+	}
+	t.Logf("\t%s\tTest %d:\tShould get back the remaining zone with its method.", tests.Success, testID)
+}
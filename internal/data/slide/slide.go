@@ -3,11 +3,11 @@ package slide
 import (
 	"context"
 	"database/sql"
-	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/internal/auth"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -26,12 +26,12 @@ var (
 
 // Slide manages the set of API's for slide access.
 type Slide struct {
-	log *log.Logger
+	log *logger.Logger
 	db  *sqlx.DB
 }
 
 // New constructs a Slide for api access.
-func New(log *log.Logger, db *sqlx.DB) Slide {
+func New(log *logger.Logger, db *sqlx.DB) Slide {
 	return Slide{
 		log: log,
 		db:  db,
@@ -62,7 +62,7 @@ func (s Slide) Create(ctx context.Context, traceID string, claims auth.Claims, n
 	VALUES
 		($1, $2, $3, $4, $5, $6, $7)`
 
-	s.log.Printf("%s: %s: %s", traceID, "slide.Create",
+	s.log.Debug("slide.Create", logger.TraceID(traceID),
 		database.Log(q, slide.ID, slide.Title, slide.Image, slide.SubTitle, slide.Link, slide.DateCreated, slide.DateUpdated),
 	)
 
@@ -111,7 +111,7 @@ func (s Slide) Update(ctx context.Context, traceID string, claims auth.Claims, S
 	WHERE
 		slide_id = $1`
 
-	s.log.Printf("%s: %s: %s", traceID, "slide.Update",
+	s.log.Debug("slide.Update", logger.TraceID(traceID),
 		database.Log(q, slide.ID, slide.Title, slide.Image, slide.SubTitle, slide.Link, slide.DateUpdated),
 	)
 
@@ -137,7 +137,7 @@ func (s Slide) Delete(ctx context.Context, traceID string, claims auth.Claims, S
 	WHERE
 		slide_id = $1`
 
-	s.log.Printf("%s: %s: %s", traceID, "slide.Delete",
+	s.log.Debug("slide.Delete", logger.TraceID(traceID),
 		database.Log(q, SlideID),
 	)
 
@@ -163,7 +163,7 @@ func (s Slide) QueryByID(ctx context.Context, traceID string, SlideID string) (I
 	WHERE 
 		slide_id = $1`
 
-	s.log.Printf("%s: %s: %s", traceID, "slide.QueryByID",
+	s.log.Debug("slide.QueryByID", logger.TraceID(traceID),
 		database.Log(q, SlideID),
 	)
 
@@ -189,7 +189,7 @@ func (s Slide) Query(ctx context.Context, traceID string) ([]Info, error) {
 	ORDER BY
 		date_created`
 
-	s.log.Printf("%s: %s: %s", traceID, "slides.Query",
+	s.log.Debug("slides.Query", logger.TraceID(traceID),
 		database.Log(q),
 	)
 
@@ -0,0 +1,37 @@
+package subscription
+
+import (
+	"time"
+)
+
+// These are the events a shopper can subscribe to.
+const (
+	KindBackInStock = "back_in_stock"
+	KindPriceDrop   = "price_drop"
+)
+
+// Info represents a shopper's Subscription to news about a product. Price is
+// the price of the product when the subscription was made.
+type Info struct {
+	ID          string    `db:"subscription_id" json:"id"`
+	ProductID   string    `db:"product_id" json:"product_id"`
+	Email       string    `db:"email" json:"email"`
+	Kind        string    `db:"kind" json:"kind"`
+	Price       float64   `db:"price" json:"price"`
+	Token       string    `db:"token" json:"-"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// NewSubscription contains information needed to subscribe to a product.
+type NewSubscription struct {
+	Email string `json:"email" validate:"required,email"`
+	Kind  string `json:"kind" validate:"required,oneof=back_in_stock price_drop"`
+}
+
+// Config defines how notifications link back to the shop.
+type Config struct {
+
+	// UnsubscribeURL is the address of the unsubscribe endpoint, the token
+	// of the subscription is appended to it.
+	UnsubscribeURL string
+}
@@ -0,0 +1,260 @@
+// Package subscription contains back in stock and price drop subscriptions
+// and the dispatcher notifying their subscribers.
+package subscription
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/foundation/notify"
+	"github.com/igorbelousov/shop-backend/internal/data/product"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific Subscription is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInStock occurs when subscribing to the return of a product that is in stock.
+	ErrInStock = errors.New("product is in stock")
+)
+
+// Subscription manages the set of API's for subscription access.
+type Subscription struct {
+	log     *logger.Logger
+	db      *sqlx.DB
+	product product.Product
+	cfg     Config
+}
+
+// New constructs a Subscription for api access.
+func New(log *logger.Logger, db *sqlx.DB, cfg Config) Subscription {
+	return Subscription{
+		log:     log,
+		db:      db,
+		product: product.New(log, db),
+		cfg:     cfg,
+	}
+}
+
+// Create subscribes an email address to news about a product. Subscribing
+// again to the same news is a no-op.
+func (s Subscription) Create(ctx context.Context, traceID string, productID string, ns NewSubscription, now time.Time) (Info, error) {
+	prod, err := s.product.QueryByID(ctx, traceID, productID, now)
+	if err != nil {
+		return Info{}, err
+	}
+
+	if ns.Kind == KindBackInStock && prod.Stock > 0 {
+		return Info{}, ErrInStock
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return Info{}, errors.Wrap(err, "generating unsubscribe token")
+	}
+
+	sub := Info{
+		ID:          uuid.New().String(),
+		ProductID:   prod.ID,
+		Email:       strings.ToLower(ns.Email),
+		Kind:        ns.Kind,
+		Price:       prod.Price,
+		Token:       hex.EncodeToString(b),
+		DateCreated: now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO subscriptions
+		(subscription_id, product_id, email, kind, price, token, date_created)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (product_id, email, kind) DO NOTHING`
+
+	s.log.Debug("subscription.Create", logger.TraceID(traceID),
+		database.Log(q, sub.ID, sub.ProductID, sub.Email, sub.Kind, sub.Price, sub.Token, sub.DateCreated),
+	)
+
+	if _, err := s.db.ExecContext(ctx, q, sub.ID, sub.ProductID, sub.Email, sub.Kind, sub.Price, sub.Token, sub.DateCreated); err != nil {
+		return Info{}, errors.Wrap(err, "inserting subscription")
+	}
+
+	return sub, nil
+}
+
+// Unsubscribe removes the subscription with the token.
+func (s Subscription) Unsubscribe(ctx context.Context, traceID string, token string) error {
+	const q = `
+	DELETE FROM
+		subscriptions
+	WHERE
+		token = $1`
+
+	s.log.Debug("subscription.Unsubscribe", logger.TraceID(traceID),
+		database.Log(q, token),
+	)
+
+	res, err := s.db.ExecContext(ctx, q, token)
+	if err != nil {
+		return errors.Wrap(err, "deleting subscription")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Dispatch notifies the subscribers of products that came back in stock or
+// got cheaper than when they subscribed, and removes their subscriptions.
+// Prices are compared as they are at now. Subscriptions whose messages are
+// rejected for good are removed as well. It returns the number of
+// notifications sent.
+func (s Subscription) Dispatch(ctx context.Context, traceID string, n notify.Notifier, now time.Time) (int, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		subscriptions
+	ORDER BY
+		product_id, date_created`
+
+	s.log.Debug("subscription.Dispatch", logger.TraceID(traceID),
+		database.Log(q),
+	)
+
+	subs := []Info{}
+	if err := s.db.SelectContext(ctx, &subs, q); err != nil {
+		return 0, errors.Wrap(err, "selecting subscriptions")
+	}
+
+	// Products are read with their effective price, so scheduled sales count
+	// as price drops as well.
+	prods := make(map[string]product.Info)
+	var sent int
+	for _, sub := range subs {
+		prod, ok := prods[sub.ProductID]
+		if !ok {
+			var err error
+			if prod, err = s.product.QueryByID(ctx, traceID, sub.ProductID, now); err != nil {
+				return sent, errors.Wrapf(err, "loading product %s", sub.ProductID)
+			}
+			prods[sub.ProductID] = prod
+		}
+
+		m, ok := s.message(sub, prod)
+		if !ok {
+			continue
+		}
+
+		// Stop between notifications once the context is done, never
+		// between sending one and removing its subscription.
+		if ctx.Err() != nil {
+			return sent, nil
+		}
+
+		// A subscriber that can never be reached is dropped instead of being
+		// retried every interval.
+		err := n.Notify(ctx, m)
+		switch {
+		case err == nil:
+			sent++
+		case notify.Permanent(err):
+			s.log.Warn("subscription.Dispatch", logger.TraceID(traceID), logger.String("email", sub.Email), logger.String("status", "dropping subscription"), logger.Err(err))
+		default:
+			s.log.Warn("subscription.Dispatch", logger.TraceID(traceID), logger.String("email", sub.Email), logger.Err(err))
+			continue
+		}
+
+		// The subscription is removed even when the context is done by now,
+		// or the message is sent again on the next run.
+		if err := s.delete(context.Background(), traceID, sub.ID); err != nil {
+			return sent, err
+		}
+	}
+
+	return sent, nil
+}
+
+// Run dispatches notifications every interval until the context is done. It
+// returns only after the dispatch in progress, if any, has stopped.
+func (s Subscription) Run(ctx context.Context, n notify.Notifier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			traceID := uuid.New().String()
+			sent, err := s.Dispatch(ctx, traceID, n, now)
+			if err != nil {
+				s.log.Error("subscription.Run", logger.TraceID(traceID), logger.Err(err))
+			}
+			if sent > 0 {
+				s.log.Info("subscription.Run", logger.TraceID(traceID), logger.Int("sent", sent))
+			}
+		}
+	}
+}
+
+// message builds the notification of a subscription, if the product changed
+// the way the subscriber was waiting for.
+func (s Subscription) message(sub Info, prod product.Info) (notify.Message, bool) {
+	var subject, body string
+	switch sub.Kind {
+	case KindBackInStock:
+		if prod.Stock <= 0 {
+			return notify.Message{}, false
+		}
+		subject = fmt.Sprintf("%s is back in stock", prod.Title)
+		body = fmt.Sprintf("Good news, %s is available again.", prod.Title)
+
+	case KindPriceDrop:
+		if prod.Price >= sub.Price {
+			return notify.Message{}, false
+		}
+		subject = fmt.Sprintf("%s got cheaper", prod.Title)
+		body = fmt.Sprintf("The price of %s dropped from %.2f to %.2f.", prod.Title, sub.Price, prod.Price)
+
+	default:
+		return notify.Message{}, false
+	}
+
+	body += fmt.Sprintf("\n\nTo stop these messages visit %s%s", s.cfg.UnsubscribeURL, sub.Token)
+
+	m := notify.Message{
+		To:      sub.Email,
+		Subject: subject,
+		Body:    body,
+	}
+	return m, true
+}
+
+// delete removes a subscription once its subscriber has been notified.
+func (s Subscription) delete(ctx context.Context, traceID string, subscriptionID string) error {
+	const q = `
+	DELETE FROM
+		subscriptions
+	WHERE
+		subscription_id = $1`
+
+	s.log.Debug("subscription.delete", logger.TraceID(traceID),
+		database.Log(q, subscriptionID),
+	)
+
+	if _, err := s.db.ExecContext(ctx, q, subscriptionID); err != nil {
+		return errors.Wrapf(err, "deleting subscription %s", subscriptionID)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package subscription_test
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/igorbelousov/shop-backend/foundation/notify"
+	"github.com/igorbelousov/shop-backend/internal/data/subscription"
+	"github.com/igorbelousov/shop-backend/internal/tests"
+)
+
+// outbox collects the messages instead of sending them.
+type outbox struct {
+	messages []notify.Message
+}
+
+func (o *outbox) Notify(ctx context.Context, m notify.Message) error {
+	o.messages = append(o.messages, m)
+	return nil
+}
+
+// failing fails to deliver every message with err.
+type failing struct {
+	err error
+}
+
+func (f failing) Notify(ctx context.Context, m notify.Message) error {
+	return f.err
+}
+
+func TestSubscription(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+	testID := 0
+	s := subscription.New(log, db, subscription.Config{UnsubscribeURL: "http://shop/unsubscribe/"})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+	productID := "9097a8f9-c7c0-4e88-81da-72ec34a1dc79"
+
+	ns := subscription.NewSubscription{Email: "Gopher@example.com", Kind: subscription.KindBackInStock}
+	if _, err := s.Create(ctx, traceID, productID, ns, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe : %s.", tests.Failed, testID, err)
+	}
+	ns.Kind = subscription.KindPriceDrop
+	sub, err := s.Create(ctx, traceID, productID, ns, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to subscribe.", tests.Success, testID)
+
+	var out outbox
+	if sent, err := s.Dispatch(ctx, traceID, &out, now); err != nil || sent != 0 {
+		t.Fatalf("\t%s\tTest %d:\tShould not notify before anything changed : %d %v.", tests.Failed, testID, sent, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould not notify before anything changed.", tests.Success, testID)
+
+	const q = `UPDATE products SET stock = 3, price = price - 100 WHERE product_id = $1`
+	if _, err := db.ExecContext(ctx, q, productID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to restock the product : %s.", tests.Failed, testID, err)
+	}
+
+	if sent, err := s.Dispatch(ctx, traceID, &out, now); err != nil || sent != 2 {
+		t.Fatalf("\t%s\tTest %d:\tShould notify about the restock and the price drop : %d %v.", tests.Failed, testID, sent, err)
+	}
+	if out.messages[0].To != "gopher@example.com" {
+		t.Fatalf("\t%s\tTest %d:\tShould notify the subscriber : %+v.", tests.Failed, testID, out.messages[0])
+	}
+	t.Logf("\t%s\tTest %d:\tShould notify about the restock and the price drop.", tests.Success, testID)
+
+	if sent, err := s.Dispatch(ctx, traceID, &out, now); err != nil || sent != 0 {
+		t.Fatalf("\t%s\tTest %d:\tShould notify only once : %d %v.", tests.Failed, testID, sent, err)
+	}
+	if err := s.Unsubscribe(ctx, traceID, sub.Token); err != subscription.ErrNotFound {
+		t.Fatalf("\t%s\tTest %d:\tShould have removed the subscription : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould notify only once.", tests.Success, testID)
+
+	testID++
+	subscribe := func() subscription.Info {
+		const q = `UPDATE products SET stock = $2 WHERE product_id = $1`
+		if _, err := db.ExecContext(ctx, q, productID, 0); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to sell out the product : %s.", tests.Failed, testID, err)
+		}
+		ns.Kind = subscription.KindBackInStock
+		sub, err := s.Create(ctx, traceID, productID, ns, now)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to subscribe : %s.", tests.Failed, testID, err)
+		}
+		if _, err := db.ExecContext(ctx, q, productID, 3); err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to restock the product : %s.", tests.Failed, testID, err)
+		}
+		return sub
+	}
+
+	subscribe()
+	if sent, err := s.Dispatch(ctx, traceID, failing{errors.New("connection refused")}, now); err != nil || sent != 0 {
+		t.Fatalf("\t%s\tTest %d:\tShould not count failed notifications : %d %v.", tests.Failed, testID, sent, err)
+	}
+	if sent, err := s.Dispatch(ctx, traceID, &out, now); err != nil || sent != 1 {
+		t.Fatalf("\t%s\tTest %d:\tShould retry after a temporary failure : %d %v.", tests.Failed, testID, sent, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould retry after a temporary failure.", tests.Success, testID)
+
+	sub = subscribe()
+	rejected := &textproto.Error{Code: 550, Msg: "no such user"}
+	if sent, err := s.Dispatch(ctx, traceID, failing{rejected}, now); err != nil || sent != 0 {
+		t.Fatalf("\t%s\tTest %d:\tShould not count rejected notifications : %d %v.", tests.Failed, testID, sent, err)
+	}
+	if err := s.Unsubscribe(ctx, traceID, sub.Token); err != subscription.ErrNotFound {
+		t.Fatalf("\t%s\tTest %d:\tShould drop the subscription after a permanent failure : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould drop the subscription after a permanent failure.", tests.Success, testID)
+}
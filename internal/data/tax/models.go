@@ -0,0 +1,73 @@
+package tax
+
+import (
+	"time"
+)
+
+// Config defines how catalogue prices relate to taxes.
+type Config struct {
+
+	// PricesIncludeTax is set when the catalogue prices are gross prices.
+	PricesIncludeTax bool
+
+	// DefaultCountry is used when a cart is taxed without an address.
+	DefaultCountry string
+}
+
+// Class represents a tax class assignable to products and categories.
+type Class struct {
+	ID          string    `db:"tax_class_id" json:"id"`
+	Title       string    `db:"title" json:"title"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+	Rates       []Rate    `db:"-" json:"rates"`
+}
+
+// NewClass contains information needed to create a new Class.
+type NewClass struct {
+	Title string `json:"title" validate:"required"`
+}
+
+// Rate is the percentage of a tax class charged in a country, or in a
+// single region of it when Region is set.
+type Rate struct {
+	ID          string    `db:"rate_id" json:"id"`
+	ClassID     string    `db:"tax_class_id" json:"tax_class_id"`
+	Title       string    `db:"title" json:"title"`
+	Country     string    `db:"country" json:"country"`
+	Region      string    `db:"region" json:"region"`
+	Rate        float64   `db:"rate" json:"rate"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// NewRate contains information needed to create a new Rate.
+type NewRate struct {
+	ClassID string  `json:"tax_class_id" validate:"required,uuid"`
+	Title   string  `json:"title" validate:"required"`
+	Country string  `json:"country" validate:"required,len=2"`
+	Region  string  `json:"region"`
+	Rate    float64 `json:"rate" validate:"gte=0,lte=100"`
+}
+
+// Line is a taxable amount of a product, after discounts.
+type Line struct {
+	ProductID string
+	Amount    float64
+}
+
+// TaxLine is the tax charged at a single rate.
+type TaxLine struct {
+	Title  string  `json:"title"`
+	Rate   float64 `json:"rate"`
+	Base   float64 `json:"base"`
+	Amount float64 `json:"amount"`
+}
+
+// Summary is the tax breakdown of a set of lines.
+type Summary struct {
+	PricesIncludeTax bool      `json:"prices_include_tax"`
+	Lines            []TaxLine `json:"lines"`
+	Net              float64   `json:"net"`
+	Tax              float64   `json:"tax"`
+	Gross            float64   `json:"gross"`
+}
@@ -0,0 +1,331 @@
+// Package tax contains tax classes, regional tax rates and tax calculation.
+package tax
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+)
+
+// Tax manages the set of API's for tax access.
+type Tax struct {
+	log *logger.Logger
+	db  *sqlx.DB
+	cfg Config
+}
+
+// New constructs a Tax for api access.
+func New(log *logger.Logger, db *sqlx.DB, cfg Config) Tax {
+	return Tax{
+		log: log,
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// CreateClass inserts a new tax class into the database.
+func (t Tax) CreateClass(ctx context.Context, traceID string, claims auth.Claims, nc NewClass, now time.Time) (Class, error) {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return Class{}, ErrForbidden
+	}
+
+	c := Class{
+		ID:          uuid.New().String(),
+		Title:       nc.Title,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+		Rates:       []Rate{},
+	}
+
+	const q = `
+	INSERT INTO tax_classes
+		(tax_class_id, title, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4)`
+
+	t.log.Debug("tax.CreateClass", logger.TraceID(traceID),
+		database.Log(q, c.ID, c.Title, c.DateCreated, c.DateUpdated),
+	)
+
+	if _, err := t.db.ExecContext(ctx, q, c.ID, c.Title, c.DateCreated, c.DateUpdated); err != nil {
+		return Class{}, errors.Wrap(err, "inserting tax class")
+	}
+
+	return c, nil
+}
+
+// DeleteClass removes a tax class and its rates from the database.
+func (t Tax) DeleteClass(ctx context.Context, traceID string, claims auth.Claims, classID string) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if _, err := uuid.Parse(classID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	DELETE FROM
+		tax_classes
+	WHERE
+		tax_class_id = $1`
+
+	t.log.Debug("tax.DeleteClass", logger.TraceID(traceID),
+		database.Log(q, classID),
+	)
+
+	if _, err := t.db.ExecContext(ctx, q, classID); err != nil {
+		return errors.Wrapf(err, "deleting tax class %s", classID)
+	}
+
+	return nil
+}
+
+// QueryClasses retrieves the tax classes with their rates.
+func (t Tax) QueryClasses(ctx context.Context, traceID string) ([]Class, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		tax_classes
+	ORDER BY
+		title`
+
+	t.log.Debug("tax.QueryClasses", logger.TraceID(traceID),
+		database.Log(q),
+	)
+
+	classes := []Class{}
+	if err := t.db.SelectContext(ctx, &classes, q); err != nil {
+		return nil, errors.Wrap(err, "selecting tax classes")
+	}
+
+	const qr = `
+	SELECT
+		*
+	FROM
+		tax_rates
+	ORDER BY
+		country, region`
+
+	t.log.Debug("tax.QueryClasses", logger.TraceID(traceID),
+		database.Log(qr),
+	)
+
+	rates := []Rate{}
+	if err := t.db.SelectContext(ctx, &rates, qr); err != nil {
+		return nil, errors.Wrap(err, "selecting tax rates")
+	}
+
+	for i := range classes {
+		classes[i].Rates = []Rate{}
+		for _, r := range rates {
+			if r.ClassID == classes[i].ID {
+				classes[i].Rates = append(classes[i].Rates, r)
+			}
+		}
+	}
+
+	return classes, nil
+}
+
+// CreateRate inserts a new tax rate into the database.
+func (t Tax) CreateRate(ctx context.Context, traceID string, claims auth.Claims, nr NewRate, now time.Time) (Rate, error) {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return Rate{}, ErrForbidden
+	}
+
+	r := Rate{
+		ID:          uuid.New().String(),
+		ClassID:     nr.ClassID,
+		Title:       nr.Title,
+		Country:     strings.ToUpper(nr.Country),
+		Region:      nr.Region,
+		Rate:        nr.Rate,
+		DateCreated: now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO tax_rates
+		(rate_id, tax_class_id, title, country, region, rate, date_created)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7)`
+
+	t.log.Debug("tax.CreateRate", logger.TraceID(traceID),
+		database.Log(q, r.ID, r.ClassID, r.Title, r.Country, r.Region, r.Rate, r.DateCreated),
+	)
+
+	if _, err := t.db.ExecContext(ctx, q, r.ID, r.ClassID, r.Title, r.Country, r.Region, r.Rate, r.DateCreated); err != nil {
+		return Rate{}, errors.Wrap(err, "inserting tax rate")
+	}
+
+	return r, nil
+}
+
+// DeleteRate removes a tax rate from the database.
+func (t Tax) DeleteRate(ctx context.Context, traceID string, claims auth.Claims, rateID string) error {
+	if !claims.Authorized(auth.RoleAdmin) {
+		return ErrForbidden
+	}
+	if _, err := uuid.Parse(rateID); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `
+	DELETE FROM
+		tax_rates
+	WHERE
+		rate_id = $1`
+
+	t.log.Debug("tax.DeleteRate", logger.TraceID(traceID),
+		database.Log(q, rateID),
+	)
+
+	if _, err := t.db.ExecContext(ctx, q, rateID); err != nil {
+		return errors.Wrapf(err, "deleting tax rate %s", rateID)
+	}
+
+	return nil
+}
+
+// Calculate returns the tax breakdown of the lines shipped to the country
+// and region. The tax class of a product falls back to the class of its
+// category, and a regional rate wins over the rate for the whole country.
+// Lines without a class or a rate are not taxed.
+func (t Tax) Calculate(ctx context.Context, traceID string, country string, region string, lines []Line) (Summary, error) {
+	if country == "" {
+		country = t.cfg.DefaultCountry
+	}
+	country = strings.ToUpper(country)
+
+	ids := make(pq.StringArray, len(lines))
+	for i, l := range lines {
+		ids[i] = l.ProductID
+	}
+
+	const qc = `
+	SELECT
+		p.product_id::text AS product_id,
+		COALESCE(p.tax_class_id, c.tax_class_id)::text AS tax_class_id
+	FROM
+		products p
+	LEFT JOIN
+		categories c ON c.category_id = p.category_id
+	WHERE
+		p.product_id::text = ANY($1)`
+
+	t.log.Debug("tax.Calculate", logger.TraceID(traceID),
+		database.Log(qc, ids),
+	)
+
+	var classes []struct {
+		ProductID string  `db:"product_id"`
+		ClassID   *string `db:"tax_class_id"`
+	}
+	if err := t.db.SelectContext(ctx, &classes, qc, ids); err != nil {
+		return Summary{}, errors.Wrap(err, "selecting product tax classes")
+	}
+
+	const qr = `
+	SELECT
+		*
+	FROM
+		tax_rates
+	WHERE
+		country = $1 AND (region = '' OR lower(region) = lower($2))`
+
+	t.log.Debug("tax.Calculate", logger.TraceID(traceID),
+		database.Log(qr, country, region),
+	)
+
+	rates := []Rate{}
+	if err := t.db.SelectContext(ctx, &rates, qr, country, region); err != nil {
+		return Summary{}, errors.Wrap(err, "selecting tax rates")
+	}
+
+	// Resolve the rate of every product.
+	byClass := make(map[string]Rate)
+	for _, r := range rates {
+		if cur, ok := byClass[r.ClassID]; !ok || cur.Region == "" {
+			byClass[r.ClassID] = r
+		}
+	}
+	byProduct := make(map[string]Rate)
+	for _, c := range classes {
+		if c.ClassID == nil {
+			continue
+		}
+		if r, ok := byClass[*c.ClassID]; ok {
+			byProduct[c.ProductID] = r
+		}
+	}
+
+	return summarize(lines, byProduct, t.cfg.PricesIncludeTax), nil
+}
+
+// summarize groups the taxes of the lines by rate.
+func summarize(lines []Line, rates map[string]Rate, included bool) Summary {
+	s := Summary{
+		PricesIncludeTax: included,
+		Lines:            []TaxLine{},
+	}
+
+	index := make(map[string]int)
+	for _, l := range lines {
+		r, ok := rates[l.ProductID]
+		if !ok {
+			s.Net += l.Amount
+			continue
+		}
+
+		i, ok := index[r.ID]
+		if !ok {
+			i = len(s.Lines)
+			index[r.ID] = i
+			s.Lines = append(s.Lines, TaxLine{Title: r.Title, Rate: r.Rate})
+		}
+
+		// Taxes are rounded per rate, so only sum up the amounts here.
+		s.Lines[i].Base += l.Amount
+	}
+
+	for i := range s.Lines {
+		tl := &s.Lines[i]
+		if included {
+			tl.Amount = round(tl.Base * tl.Rate / (100 + tl.Rate))
+			tl.Base = round(tl.Base - tl.Amount)
+		} else {
+			tl.Base = round(tl.Base)
+			tl.Amount = round(tl.Base * tl.Rate / 100)
+		}
+		s.Net += tl.Base
+		s.Tax += tl.Amount
+	}
+
+	s.Net = round(s.Net)
+	s.Tax = round(s.Tax)
+	s.Gross = round(s.Net + s.Tax)
+
+	return s
+}
+
+// round rounds an amount of money to cents.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
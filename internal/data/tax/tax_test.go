@@ -0,0 +1,83 @@
+package tax_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/tax"
+	"github.com/igorbelousov/shop-backend/internal/tests"
+)
+
+func TestTax(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+	testID := 0
+	tx := tax.New(log, db, tax.Config{PricesIncludeTax: true, DefaultCountry: "RU"})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "Shop backend",
+			Subject:   "00000000-0000-0000-0000-000000000000",
+			Audience:  "SHOP",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		Roles: []string{auth.RoleAdmin},
+	}
+
+	class, err := tx.CreateClass(ctx, traceID, claims, tax.NewClass{Title: "Standard"}, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create tax class : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to create tax class.", tests.Success, testID)
+
+	nr := tax.NewRate{
+		ClassID: class.ID,
+		Title:   "VAT 20%",
+		Country: "RU",
+		Rate:    20,
+	}
+	if _, err := tx.CreateRate(ctx, traceID, claims, nr, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create tax rate : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to create tax rate.", tests.Success, testID)
+
+	// The seeded product is taxed through its category.
+	const q = `UPDATE categories SET tax_class_id = $1 WHERE category_id = '00000000-0000-0000-0000-000000000000'`
+	if _, err := db.ExecContext(ctx, q, class.ID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to assign tax class : %s.", tests.Failed, testID, err)
+	}
+
+	lines := []tax.Line{
+		{ProductID: "9097a8f9-c7c0-4e88-81da-72ec34a1dc79", Amount: 120},
+	}
+
+	sum, err := tx.Calculate(ctx, traceID, "", "", lines)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to calculate taxes : %s.", tests.Failed, testID, err)
+	}
+	if len(sum.Lines) != 1 || sum.Tax != 20 || sum.Net != 100 || sum.Gross != 120 {
+		t.Fatalf("\t%s\tTest %d:\tShould get 20 of included VAT : %+v.", tests.Failed, testID, sum)
+	}
+	t.Logf("\t%s\tTest %d:\tShould get 20 of included VAT.", tests.Success, testID)
+
+	sum, err = tx.Calculate(ctx, traceID, "DE", "", lines)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to calculate taxes : %s.", tests.Failed, testID, err)
+	}
+	if len(sum.Lines) != 0 || sum.Tax != 0 || sum.Gross != 120 {
+		t.Fatalf("\t%s\tTest %d:\tShould get no taxes without a rate : %+v.", tests.Failed, testID, sum)
+	}
+	t.Logf("\t%s\tTest %d:\tShould get no taxes without a rate.", tests.Success, testID)
+
+	if err := tx.DeleteClass(ctx, traceID, claims, class.ID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to delete tax class : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to delete tax class.", tests.Success, testID)
+}
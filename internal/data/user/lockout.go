@@ -0,0 +1,144 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/pkg/errors"
+)
+
+const (
+	// lockoutThreshold is the number of failed logins an email is allowed
+	// before it is locked.
+	lockoutThreshold = 5
+
+	// lockoutBase is how long the first lockout lasts, every further failure
+	// doubles it up to lockoutMax.
+	lockoutBase = time.Minute
+	lockoutMax  = time.Hour
+
+	// lockoutWindow is how long failed logins are remembered.
+	lockoutWindow = 24 * time.Hour
+)
+
+// LockedError occurs when logins for an email are refused after too many
+// failed attempts.
+type LockedError struct {
+	Until time.Time
+}
+
+// Error implements the error interface.
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed logins, locked until %s", e.Until.Format(time.RFC3339))
+}
+
+// locked returns a LockedError if logins for the email are locked at now.
+func (u User) locked(ctx context.Context, traceID string, email string, now time.Time) error {
+	const q = `
+	SELECT
+		locked_until
+	FROM
+		login_attempts
+	WHERE
+		email = $1 AND locked_until > $2`
+
+	u.log.Debug("user.locked", logger.TraceID(traceID),
+		database.Log(q, email, now.UTC()),
+	)
+
+	var until time.Time
+	if err := u.db.GetContext(ctx, &until, q, email, now.UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return errors.Wrap(err, "selecting login attempts")
+	}
+
+	return &LockedError{Until: until}
+}
+
+// fail records a failed login for the email and locks it once the failures
+// pass the threshold. Failures older than the window start over.
+func (u User) fail(ctx context.Context, traceID string, email string, now time.Time) error {
+	const q = `
+	INSERT INTO login_attempts
+		(email, failures, date_updated)
+	VALUES
+		($1, 1, $2)
+	ON CONFLICT (email) DO UPDATE SET
+		failures = CASE WHEN login_attempts.date_updated < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+		date_updated = $2
+	RETURNING failures`
+
+	now = now.UTC()
+	since := now.Add(-lockoutWindow)
+	u.log.Debug("user.fail", logger.TraceID(traceID),
+		database.Log(q, email, now, since),
+	)
+
+	var failures int
+	if err := u.db.GetContext(ctx, &failures, q, email, now, since); err != nil {
+		return errors.Wrap(err, "recording failed login")
+	}
+
+	if failures < lockoutThreshold {
+		return nil
+	}
+
+	lock := lockoutMax
+	if n := failures - lockoutThreshold; n < 6 {
+		if d := lockoutBase << uint(n); d < lockoutMax {
+			lock = d
+		}
+	}
+
+	const lq = `
+	UPDATE
+		login_attempts
+	SET
+		locked_until = $2
+	WHERE
+		email = $1`
+
+	until := now.Add(lock)
+	u.log.Debug("user.fail", logger.TraceID(traceID),
+		database.Log(lq, email, until),
+	)
+
+	if _, err := u.db.ExecContext(ctx, lq, email, until); err != nil {
+		return errors.Wrap(err, "locking logins")
+	}
+
+	return nil
+}
+
+// reset forgets the failed logins of the email.
+func (u User) reset(ctx context.Context, traceID string, email string) error {
+	const q = `
+	DELETE FROM
+		login_attempts
+	WHERE
+		email = $1`
+
+	u.log.Debug("user.reset", logger.TraceID(traceID),
+		database.Log(q, email),
+	)
+
+	if _, err := u.db.ExecContext(ctx, q, email); err != nil {
+		return errors.Wrap(err, "resetting failed logins")
+	}
+
+	return nil
+}
+
+// failed records the failed login and returns the error to report for it.
+func (u User) failed(ctx context.Context, traceID string, email string, now time.Time) error {
+	if err := u.fail(ctx, traceID, email, now); err != nil {
+		return err
+	}
+	return ErrAuthenticationFailure
+}
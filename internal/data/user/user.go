@@ -4,12 +4,12 @@ package user
 import (
 	"context"
 	"database/sql"
-	"log"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/google/uuid"
 	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/internal/auth"
 
 	"github.com/jmoiron/sqlx"
@@ -34,12 +34,12 @@ var (
 
 // User manages the set of API's for user access.
 type User struct {
-	log *log.Logger
+	log *logger.Logger
 	db  *sqlx.DB
 }
 
 // New constructs a User for api access.
-func New(log *log.Logger, db *sqlx.DB) User {
+func New(log *logger.Logger, db *sqlx.DB) User {
 	return User{
 		log: log,
 		db:  db,
@@ -70,8 +70,8 @@ func (u User) Create(ctx context.Context, traceID string, nu NewUser, now time.T
 	VALUES
 		($1, $2, $3, $4, $5, $6, $7)`
 
-	u.log.Printf("%s: %s: %s", traceID, "user.Create",
-		database.Log(q, usr.ID, usr.Name, usr.Email, usr.PasswordHash, usr.Roles, usr.DateCreated, usr.DateUpdated),
+	u.log.Debug("user.Create", logger.TraceID(traceID),
+		database.Log(q, usr.ID, usr.Name, usr.Email, database.Secret(usr.PasswordHash), usr.Roles, usr.DateCreated, usr.DateUpdated),
 	)
 
 	if _, err = u.db.ExecContext(ctx, q, usr.ID, usr.Name, usr.Email, usr.PasswordHash, usr.Roles, usr.DateCreated, usr.DateUpdated); err != nil {
@@ -119,8 +119,8 @@ func (u User) Update(ctx context.Context, traceID string, claims auth.Claims, us
 	WHERE
 		user_id = $1`
 
-	u.log.Printf("%s: %s: %s", traceID, "user.Update",
-		database.Log(q, usr.ID, usr.Name, usr.Email, usr.Roles, usr.PasswordHash, usr.DateCreated, usr.DateUpdated),
+	u.log.Debug("user.Update", logger.TraceID(traceID),
+		database.Log(q, usr.ID, usr.Name, usr.Email, usr.Roles, database.Secret(usr.PasswordHash), usr.DateCreated, usr.DateUpdated),
 	)
 
 	if _, err = u.db.ExecContext(ctx, q, userID, usr.Name, usr.Email, usr.Roles, usr.PasswordHash, usr.DateUpdated); err != nil {
@@ -148,7 +148,7 @@ func (u User) Delete(ctx context.Context, traceID string, claims auth.Claims, us
 	WHERE
 		user_id = $1`
 
-	u.log.Printf("%s: %s: %s", traceID, "user.Delete",
+	u.log.Debug("user.Delete", logger.TraceID(traceID),
 		database.Log(q, userID),
 	)
 
@@ -173,7 +173,7 @@ func (u User) Query(ctx context.Context, traceID string, pageNumber int, rowsPer
 
 	offset := (pageNumber - 1) * rowsPerPage
 
-	u.log.Printf("%s: %s: %s", traceID, "user.Query",
+	u.log.Debug("user.Query", logger.TraceID(traceID),
 		database.Log(q, offset, rowsPerPage),
 	)
 
@@ -205,7 +205,7 @@ func (u User) QueryByID(ctx context.Context, traceID string, claims auth.Claims,
 	WHERE 
 		user_id = $1`
 
-	u.log.Printf("%s: %s: %s", traceID, "user.QueryByID",
+	u.log.Debug("user.QueryByID", logger.TraceID(traceID),
 		database.Log(q, userID),
 	)
 
@@ -231,7 +231,7 @@ func (u User) QueryByEmail(ctx context.Context, traceID string, claims auth.Clai
 	WHERE
 		email = $1`
 
-	u.log.Printf("%s: %s: %s", traceID, "user.QueryByEmail",
+	u.log.Debug("user.QueryByEmail", logger.TraceID(traceID),
 		database.Log(q, email),
 	)
 
@@ -256,6 +256,12 @@ func (u User) QueryByEmail(ctx context.Context, traceID string, claims auth.Clai
 // used to generate a token for future authentication.
 func (u User) Authenticate(ctx context.Context, traceID string, now time.Time, email, password string) (auth.Claims, error) {
 
+	// Emails are locked regardless of whether they belong to a user, so the
+	// lockout does not leak which emails are in the system either.
+	if err := u.locked(ctx, traceID, email, now); err != nil {
+		return auth.Claims{}, err
+	}
+
 	const q = `
 	SELECT
 		*
@@ -264,7 +270,7 @@ func (u User) Authenticate(ctx context.Context, traceID string, now time.Time, e
 	WHERE
 		email = $1`
 
-	u.log.Printf("%s: %s: %s", traceID, "user.Authenticate",
+	u.log.Debug("user.Authenticate", logger.TraceID(traceID),
 		database.Log(q, email),
 	)
 
@@ -274,7 +280,7 @@ func (u User) Authenticate(ctx context.Context, traceID string, now time.Time, e
 		// Normally we would return ErrNotFound in this scenario but we do not want
 		// to leak to an unauthenticated user which emails are in the system.
 		if err == sql.ErrNoRows {
-			return auth.Claims{}, ErrAuthenticationFailure
+			return auth.Claims{}, u.failed(ctx, traceID, email, now)
 		}
 
 		return auth.Claims{}, errors.Wrap(err, "selecting single user")
@@ -283,7 +289,11 @@ func (u User) Authenticate(ctx context.Context, traceID string, now time.Time, e
 	// Compare the provided password with the saved hash. Use the bcrypt
 	// comparison function so it is cryptographically secure.
 	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(password)); err != nil {
-		return auth.Claims{}, ErrAuthenticationFailure
+		return auth.Claims{}, u.failed(ctx, traceID, email, now)
+	}
+
+	if err := u.reset(ctx, traceID, email); err != nil {
+		return auth.Claims{}, err
 	}
 
 	// If we are this far the request is valid. Create some claims for the user
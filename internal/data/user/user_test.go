@@ -89,6 +89,33 @@ func TestUser(t *testing.T) {
 		t.Logf("\t%s\tTest %d:\tShould be able to see updates to Email.", tests.Success, testID)
 	}
 
+	if _, err := u.Authenticate(ctx, traceID, now, *upd.Email, "gophers"); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to authenticate.", tests.Success, testID)
+
+	// Requests may carry the time of a host in another time zone, the lock
+	// must still end at the same instant.
+	local := now.In(time.FixedZone("MSK", 3*60*60))
+	for i := 0; i < 5; i++ {
+		if _, err := u.Authenticate(ctx, traceID, local, *upd.Email, "wrong"); err != user.ErrAuthenticationFailure {
+			t.Fatalf("\t%s\tTest %d:\tShould fail with a wrong password : %v.", tests.Failed, testID, err)
+		}
+	}
+	t.Logf("\t%s\tTest %d:\tShould fail with a wrong password.", tests.Success, testID)
+
+	_, err = u.Authenticate(ctx, traceID, local, *upd.Email, "gophers")
+	lerr, ok := err.(*user.LockedError)
+	if !ok || !lerr.Until.Equal(now.Add(time.Minute)) {
+		t.Fatalf("\t%s\tTest %d:\tShould lock the email for a minute : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould lock the email for a minute.", tests.Success, testID)
+
+	if _, err := u.Authenticate(ctx, traceID, now.Add(time.Minute), *upd.Email, "gophers"); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate once the lock expires : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to authenticate once the lock expires.", tests.Success, testID)
+
 	if err := u.Delete(ctx, traceID, claims, usr.ID); err != nil {
 		t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", tests.Failed, testID, err)
 	}
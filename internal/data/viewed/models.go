@@ -0,0 +1,11 @@
+package viewed
+
+import (
+	"time"
+)
+
+// Item is a product a shopper looked at.
+type Item struct {
+	ProductID  string    `db:"product_id" json:"product_id"`
+	DateViewed time.Time `db:"date_viewed" json:"date_viewed"`
+}
@@ -0,0 +1,193 @@
+// Package viewed keeps the recently viewed products of shoppers.
+package viewed
+
+import (
+	"context"
+	"time"
+
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// MaxItems is the number of recently viewed products kept per shopper.
+const MaxItems = 20
+
+// ErrNoVisitor occurs when a view is recorded without a user or a session.
+var ErrNoVisitor = errors.New("user or session required")
+
+// Viewed manages the set of API's for recently viewed products.
+type Viewed struct {
+	log *logger.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a Viewed for api access.
+func New(log *logger.Logger, db *sqlx.DB) Viewed {
+	return Viewed{
+		log: log,
+		db:  db,
+	}
+}
+
+// Record stores a view of the product by the logged in user, or the
+// anonymous session when userID is empty. Only the latest MaxItems products
+// are kept.
+func (vw Viewed) Record(ctx context.Context, traceID string, userID string, session string, productID string, now time.Time) error {
+	visitor, err := visitorOf(userID, session)
+	if err != nil {
+		return err
+	}
+
+	tx, err := vw.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	INSERT INTO viewed_products
+		(visitor, product_id, date_viewed)
+	VALUES
+		($1, $2, $3)
+	ON CONFLICT (visitor, product_id) DO UPDATE SET
+		date_viewed = EXCLUDED.date_viewed`
+
+	vw.log.Debug("viewed.Record", logger.TraceID(traceID),
+		database.Log(q, visitor, productID, now.UTC()),
+	)
+
+	if _, err := tx.ExecContext(ctx, q, visitor, productID, now.UTC()); err != nil {
+		return errors.Wrapf(err, "recording view of product %s", productID)
+	}
+
+	if err := vw.trim(ctx, traceID, tx, visitor); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Query retrieves the recently viewed products of the user, or the anonymous
+// session when userID is empty, latest first.
+func (vw Viewed) Query(ctx context.Context, traceID string, userID string, session string) ([]Item, error) {
+	visitor, err := visitorOf(userID, session)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+	SELECT
+		product_id, date_viewed
+	FROM
+		viewed_products
+	WHERE
+		visitor = $1
+	ORDER BY
+		date_viewed DESC`
+
+	vw.log.Debug("viewed.Query", logger.TraceID(traceID),
+		database.Log(q, visitor),
+	)
+
+	items := []Item{}
+	if err := vw.db.SelectContext(ctx, &items, q, visitor); err != nil {
+		return nil, errors.Wrap(err, "selecting viewed products")
+	}
+
+	return items, nil
+}
+
+// Merge moves the history of an anonymous session to the user who just
+// logged in. The latest view of a product wins.
+func (vw Viewed) Merge(ctx context.Context, traceID string, session string, userID string) error {
+	from, err := visitorOf("", session)
+	if err != nil {
+		return err
+	}
+	to, err := visitorOf(userID, "")
+	if err != nil {
+		return err
+	}
+
+	tx, err := vw.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "starting transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `
+	INSERT INTO viewed_products
+		(visitor, product_id, date_viewed)
+	SELECT
+		$2, product_id, date_viewed
+	FROM
+		viewed_products
+	WHERE
+		visitor = $1
+	ON CONFLICT (visitor, product_id) DO UPDATE SET
+		date_viewed = GREATEST(viewed_products.date_viewed, EXCLUDED.date_viewed)`
+
+	vw.log.Debug("viewed.Merge", logger.TraceID(traceID),
+		database.Log(q, from, to),
+	)
+
+	if _, err := tx.ExecContext(ctx, q, from, to); err != nil {
+		return errors.Wrap(err, "merging viewed products")
+	}
+
+	const qd = `
+	DELETE FROM
+		viewed_products
+	WHERE
+		visitor = $1`
+
+	vw.log.Debug("viewed.Merge", logger.TraceID(traceID),
+		database.Log(qd, from),
+	)
+
+	if _, err := tx.ExecContext(ctx, qd, from); err != nil {
+		return errors.Wrap(err, "deleting session views")
+	}
+
+	if err := vw.trim(ctx, traceID, tx, to); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// trim removes all but the latest MaxItems views of a visitor.
+func (vw Viewed) trim(ctx context.Context, traceID string, tx sqlx.ExecerContext, visitor string) error {
+	const q = `
+	DELETE FROM
+		viewed_products
+	WHERE
+		visitor = $1 AND product_id NOT IN (
+			SELECT product_id FROM viewed_products WHERE visitor = $1 ORDER BY date_viewed DESC LIMIT $2
+		)`
+
+	vw.log.Debug("viewed.trim", logger.TraceID(traceID),
+		database.Log(q, visitor, MaxItems),
+	)
+
+	if _, err := tx.ExecContext(ctx, q, visitor, MaxItems); err != nil {
+		return errors.Wrap(err, "trimming viewed products")
+	}
+
+	return nil
+}
+
+// visitorOf returns the key views are stored under. Users and sessions live
+// in separate namespaces so a session token can never read a user's history.
+func visitorOf(userID string, session string) (string, error) {
+	switch {
+	case userID != "":
+		return "user:" + userID, nil
+	case session != "":
+		return "session:" + session, nil
+	default:
+		return "", ErrNoVisitor
+	}
+}
@@ -0,0 +1,54 @@
+package viewed_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/igorbelousov/shop-backend/internal/data/viewed"
+	"github.com/igorbelousov/shop-backend/internal/tests"
+)
+
+func TestViewed(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+	testID := 0
+	vw := viewed.New(log, db)
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+	productID := "9097a8f9-c7c0-4e88-81da-72ec34a1dc79"
+	userID := "5cf37266-3473-4006-984f-9325122678b7"
+	session := "c0ffee"
+
+	if err := vw.Record(ctx, traceID, "", session, productID, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to record an anonymous view : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to record an anonymous view.", tests.Success, testID)
+
+	if err := vw.Record(ctx, traceID, "", "", productID, now); err != viewed.ErrNoVisitor {
+		t.Fatalf("\t%s\tTest %d:\tShould NOT be able to record a view without a visitor : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould NOT be able to record a view without a visitor.", tests.Success, testID)
+
+	if err := vw.Merge(ctx, traceID, session, userID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to merge the session into the user : %s.", tests.Failed, testID, err)
+	}
+
+	items, err := vw.Query(ctx, traceID, userID, "")
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to query viewed products : %s.", tests.Failed, testID, err)
+	}
+	if len(items) != 1 || items[0].ProductID != productID {
+		t.Fatalf("\t%s\tTest %d:\tShould see the merged view : %+v.", tests.Failed, testID, items)
+	}
+
+	items, err = vw.Query(ctx, traceID, "", session)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to query viewed products : %s.", tests.Failed, testID, err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("\t%s\tTest %d:\tShould have emptied the session : %+v.", tests.Failed, testID, items)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to merge the session into the user.", tests.Success, testID)
+}
@@ -0,0 +1,38 @@
+package wishlist
+
+import (
+	"time"
+)
+
+// Info represents an individual named Wishlist of a user.
+type Info struct {
+	ID          string    `db:"wishlist_id" json:"id"`
+	UserID      string    `db:"user_id" json:"user_id"`
+	Title       string    `db:"title" json:"title"`
+	ShareToken  *string   `db:"share_token" json:"share_token"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+	Items       []Item    `db:"-" json:"items"`
+}
+
+// Item is a product saved to a Wishlist.
+type Item struct {
+	ProductID string    `db:"product_id" json:"product_id"`
+	DateAdded time.Time `db:"date_added" json:"date_added"`
+}
+
+// NewWishlist contains information needed to create a new Wishlist.
+type NewWishlist struct {
+	Title string `json:"title" validate:"required"`
+}
+
+// UpdateWishlist defines what information may be provided to modify an
+// existing Wishlist.
+type UpdateWishlist struct {
+	Title *string `json:"title" validate:"omitempty,min=1"`
+}
+
+// NewItem contains the product to save to a Wishlist.
+type NewItem struct {
+	ProductID string `json:"product_id" validate:"required,uuid"`
+}
@@ -0,0 +1,380 @@
+// Package wishlist contains the named product lists users save for later.
+package wishlist
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is used when a specific Wishlist is requested but does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
+	ErrForbidden = errors.New("attempted action is not allowed")
+)
+
+// Wishlist manages the set of API's for wishlist access.
+type Wishlist struct {
+	log *logger.Logger
+	db  *sqlx.DB
+}
+
+// New constructs a Wishlist for api access.
+func New(log *logger.Logger, db *sqlx.DB) Wishlist {
+	return Wishlist{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new wishlist of the claims' user into the database.
+func (wl Wishlist) Create(ctx context.Context, traceID string, claims auth.Claims, nw NewWishlist, now time.Time) (Info, error) {
+	w := Info{
+		ID:          uuid.New().String(),
+		UserID:      claims.Subject,
+		Title:       nw.Title,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+		Items:       []Item{},
+	}
+
+	const q = `
+	INSERT INTO wishlists
+		(wishlist_id, user_id, title, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5)`
+
+	wl.log.Debug("wishlist.Create", logger.TraceID(traceID),
+		database.Log(q, w.ID, w.UserID, w.Title, w.DateCreated, w.DateUpdated),
+	)
+
+	if _, err := wl.db.ExecContext(ctx, q, w.ID, w.UserID, w.Title, w.DateCreated, w.DateUpdated); err != nil {
+		return Info{}, errors.Wrap(err, "inserting wishlist")
+	}
+
+	return w, nil
+}
+
+// Update modifies data about a wishlist.
+func (wl Wishlist) Update(ctx context.Context, traceID string, claims auth.Claims, wishlistID string, uw UpdateWishlist, now time.Time) error {
+	w, err := wl.QueryByID(ctx, traceID, claims, wishlistID)
+	if err != nil {
+		return err
+	}
+
+	if uw.Title != nil {
+		w.Title = *uw.Title
+	}
+	w.DateUpdated = now
+
+	const q = `
+	UPDATE
+		wishlists
+	SET
+		"title" = $2,
+		"date_updated" = $3
+	WHERE
+		wishlist_id = $1`
+
+	wl.log.Debug("wishlist.Update", logger.TraceID(traceID),
+		database.Log(q, w.ID, w.Title, w.DateUpdated),
+	)
+
+	if _, err := wl.db.ExecContext(ctx, q, w.ID, w.Title, w.DateUpdated); err != nil {
+		return errors.Wrapf(err, "updating wishlist %s", w.ID)
+	}
+
+	return nil
+}
+
+// Delete removes a wishlist and its items from the database.
+func (wl Wishlist) Delete(ctx context.Context, traceID string, claims auth.Claims, wishlistID string) error {
+	w, err := wl.QueryByID(ctx, traceID, claims, wishlistID)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	DELETE FROM
+		wishlists
+	WHERE
+		wishlist_id = $1`
+
+	wl.log.Debug("wishlist.Delete", logger.TraceID(traceID),
+		database.Log(q, w.ID),
+	)
+
+	if _, err := wl.db.ExecContext(ctx, q, w.ID); err != nil {
+		return errors.Wrapf(err, "deleting wishlist %s", w.ID)
+	}
+
+	return nil
+}
+
+// AddItem saves a product to the wishlist. Adding a product twice is a no-op.
+func (wl Wishlist) AddItem(ctx context.Context, traceID string, claims auth.Claims, wishlistID string, ni NewItem, now time.Time) error {
+	w, err := wl.QueryByID(ctx, traceID, claims, wishlistID)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	INSERT INTO wishlist_items
+		(wishlist_id, product_id, date_added)
+	VALUES
+		($1, $2, $3)
+	ON CONFLICT DO NOTHING`
+
+	wl.log.Debug("wishlist.AddItem", logger.TraceID(traceID),
+		database.Log(q, w.ID, ni.ProductID, now.UTC()),
+	)
+
+	if _, err := wl.db.ExecContext(ctx, q, w.ID, ni.ProductID, now.UTC()); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+			return ErrNotFound
+		}
+		return errors.Wrapf(err, "adding product %s to wishlist %s", ni.ProductID, w.ID)
+	}
+
+	return nil
+}
+
+// RemoveItem removes a product from the wishlist.
+func (wl Wishlist) RemoveItem(ctx context.Context, traceID string, claims auth.Claims, wishlistID string, productID string) error {
+	if _, err := uuid.Parse(productID); err != nil {
+		return ErrInvalidID
+	}
+
+	w, err := wl.QueryByID(ctx, traceID, claims, wishlistID)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	DELETE FROM
+		wishlist_items
+	WHERE
+		wishlist_id = $1 AND product_id = $2`
+
+	wl.log.Debug("wishlist.RemoveItem", logger.TraceID(traceID),
+		database.Log(q, w.ID, productID),
+	)
+
+	if _, err := wl.db.ExecContext(ctx, q, w.ID, productID); err != nil {
+		return errors.Wrapf(err, "removing product %s from wishlist %s", productID, w.ID)
+	}
+
+	return nil
+}
+
+// Clear removes every product from the wishlist and returns the removed
+// items.
+func (wl Wishlist) Clear(ctx context.Context, traceID string, claims auth.Claims, wishlistID string) ([]Item, error) {
+	w, err := wl.QueryByID(ctx, traceID, claims, wishlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+	DELETE FROM
+		wishlist_items
+	WHERE
+		wishlist_id = $1`
+
+	wl.log.Debug("wishlist.Clear", logger.TraceID(traceID),
+		database.Log(q, w.ID),
+	)
+
+	if _, err := wl.db.ExecContext(ctx, q, w.ID); err != nil {
+		return nil, errors.Wrapf(err, "clearing wishlist %s", w.ID)
+	}
+
+	return w.Items, nil
+}
+
+// Share generates a new share token for the wishlist, replacing the previous
+// one. Anyone holding the token can view the wishlist.
+func (wl Wishlist) Share(ctx context.Context, traceID string, claims auth.Claims, wishlistID string) (string, error) {
+	w, err := wl.QueryByID(ctx, traceID, claims, wishlistID)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating share token")
+	}
+	token := hex.EncodeToString(b)
+
+	if err := wl.setToken(ctx, traceID, w.ID, &token); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Unshare revokes the share token of the wishlist.
+func (wl Wishlist) Unshare(ctx context.Context, traceID string, claims auth.Claims, wishlistID string) error {
+	w, err := wl.QueryByID(ctx, traceID, claims, wishlistID)
+	if err != nil {
+		return err
+	}
+
+	return wl.setToken(ctx, traceID, w.ID, nil)
+}
+
+// QueryByUser retrieves the wishlists of the claims' user with their items.
+func (wl Wishlist) QueryByUser(ctx context.Context, traceID string, claims auth.Claims) ([]Info, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		wishlists
+	WHERE
+		user_id = $1
+	ORDER BY
+		date_created`
+
+	wl.log.Debug("wishlist.QueryByUser", logger.TraceID(traceID),
+		database.Log(q, claims.Subject),
+	)
+
+	lists := []Info{}
+	if err := wl.db.SelectContext(ctx, &lists, q, claims.Subject); err != nil {
+		return nil, errors.Wrap(err, "selecting wishlists")
+	}
+
+	for i := range lists {
+		items, err := wl.items(ctx, traceID, lists[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		lists[i].Items = items
+	}
+
+	return lists, nil
+}
+
+// QueryByID gets the specified wishlist with its items. Only admins and the
+// owner of the wishlist can retrieve it.
+func (wl Wishlist) QueryByID(ctx context.Context, traceID string, claims auth.Claims, wishlistID string) (Info, error) {
+	if _, err := uuid.Parse(wishlistID); err != nil {
+		return Info{}, ErrInvalidID
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		wishlists
+	WHERE
+		wishlist_id = $1`
+
+	w, err := wl.query(ctx, traceID, "wishlist.QueryByID", q, wishlistID)
+	if err != nil {
+		return Info{}, err
+	}
+
+	// If you are not an admin and looking to retrieve someone else's wishlist.
+	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != w.UserID {
+		return Info{}, ErrForbidden
+	}
+
+	return w, nil
+}
+
+// QueryByToken gets the wishlist shared with the token.
+func (wl Wishlist) QueryByToken(ctx context.Context, traceID string, token string) (Info, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		wishlists
+	WHERE
+		share_token = $1`
+
+	return wl.query(ctx, traceID, "wishlist.QueryByToken", q, token)
+}
+
+// query gets a single wishlist with its items.
+func (wl Wishlist) query(ctx context.Context, traceID string, name string, q string, arg string) (Info, error) {
+	wl.log.Debug(name, logger.TraceID(traceID),
+		database.Log(q, arg),
+	)
+
+	var w Info
+	if err := wl.db.GetContext(ctx, &w, q, arg); err != nil {
+		if err == sql.ErrNoRows {
+			return Info{}, ErrNotFound
+		}
+		return Info{}, errors.Wrapf(err, "selecting wishlist %q", arg)
+	}
+
+	items, err := wl.items(ctx, traceID, w.ID)
+	if err != nil {
+		return Info{}, err
+	}
+	w.Items = items
+
+	return w, nil
+}
+
+// items retrieves the items of a wishlist, newest first.
+func (wl Wishlist) items(ctx context.Context, traceID string, wishlistID string) ([]Item, error) {
+	const q = `
+	SELECT
+		product_id, date_added
+	FROM
+		wishlist_items
+	WHERE
+		wishlist_id = $1
+	ORDER BY
+		date_added DESC`
+
+	wl.log.Debug("wishlist.items", logger.TraceID(traceID),
+		database.Log(q, wishlistID),
+	)
+
+	items := []Item{}
+	if err := wl.db.SelectContext(ctx, &items, q, wishlistID); err != nil {
+		return nil, errors.Wrapf(err, "selecting items of wishlist %q", wishlistID)
+	}
+
+	return items, nil
+}
+
+// setToken stores the share token of a wishlist.
+func (wl Wishlist) setToken(ctx context.Context, traceID string, wishlistID string, token *string) error {
+	const q = `
+	UPDATE
+		wishlists
+	SET
+		"share_token" = $2
+	WHERE
+		wishlist_id = $1`
+
+	wl.log.Debug("wishlist.setToken", logger.TraceID(traceID),
+		database.Log(q, wishlistID, token),
+	)
+
+	if _, err := wl.db.ExecContext(ctx, q, wishlistID, token); err != nil {
+		return errors.Wrapf(err, "sharing wishlist %s", wishlistID)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package wishlist_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/igorbelousov/shop-backend/internal/data/wishlist"
+	"github.com/igorbelousov/shop-backend/internal/tests"
+)
+
+func TestWishlist(t *testing.T) {
+	log, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+	testID := 0
+	wl := wishlist.New(log, db)
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+	productID := "9097a8f9-c7c0-4e88-81da-72ec34a1dc79"
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "Shop backend",
+			Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+			Audience:  "SHOP",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		Roles: []string{auth.RoleUser},
+	}
+
+	list, err := wl.Create(ctx, traceID, claims, wishlist.NewWishlist{Title: "Birthday"}, now)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to create wishlist : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to create wishlist.", tests.Success, testID)
+
+	if err := wl.AddItem(ctx, traceID, claims, list.ID, wishlist.NewItem{ProductID: productID}, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to add product : %s.", tests.Failed, testID, err)
+	}
+	if err := wl.AddItem(ctx, traceID, claims, list.ID, wishlist.NewItem{ProductID: productID}, now); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to add product twice : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to add product.", tests.Success, testID)
+
+	other := claims
+	other.Subject = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+	if _, err := wl.QueryByID(ctx, traceID, other, list.ID); err != wishlist.ErrForbidden {
+		t.Fatalf("\t%s\tTest %d:\tShould not be able to see someone else's wishlist : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould not be able to see someone else's wishlist.", tests.Success, testID)
+
+	token, err := wl.Share(ctx, traceID, claims, list.ID)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to share wishlist : %s.", tests.Failed, testID, err)
+	}
+	shared, err := wl.QueryByToken(ctx, traceID, token)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve shared wishlist : %s.", tests.Failed, testID, err)
+	}
+	if shared.ID != list.ID || len(shared.Items) != 1 {
+		t.Fatalf("\t%s\tTest %d:\tShould get the shared wishlist with one item : %+v.", tests.Failed, testID, shared)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to retrieve shared wishlist.", tests.Success, testID)
+
+	if err := wl.Unshare(ctx, traceID, claims, list.ID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to unshare wishlist : %s.", tests.Failed, testID, err)
+	}
+	if _, err := wl.QueryByToken(ctx, traceID, token); err != wishlist.ErrNotFound {
+		t.Fatalf("\t%s\tTest %d:\tShould not be able to use a revoked token : %v.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould not be able to use a revoked token.", tests.Success, testID)
+
+	items, err := wl.Clear(ctx, traceID, claims, list.ID)
+	if err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to clear wishlist : %s.", tests.Failed, testID, err)
+	}
+	if len(items) != 1 || items[0].ProductID != productID {
+		t.Fatalf("\t%s\tTest %d:\tShould get the cleared items : %+v.", tests.Failed, testID, items)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to clear wishlist.", tests.Success, testID)
+
+	if err := wl.Delete(ctx, traceID, claims, list.ID); err != nil {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to delete wishlist : %s.", tests.Failed, testID, err)
+	}
+	t.Logf("\t%s\tTest %d:\tShould be able to delete wishlist.", tests.Success, testID)
+}
@@ -0,0 +1,119 @@
+// Package metrics contains the Prometheus collectors of the service.
+package metrics
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "shop"
+
+// requestLabels are the labels of the request metrics. The route is the
+// pattern the request matched, so the label values stay few.
+var requestLabels = []string{"method", "route", "status"}
+
+// Set of collectors updated by the application.
+var (
+	// Requests counts the handled requests.
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of handled requests.",
+	}, requestLabels)
+
+	// Latency observes how long requests took.
+	Latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time spent handling requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, requestLabels)
+
+	// LoginsFailed counts the refused logins, wrong passwords and locked
+	// emails alike.
+	LoginsFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_failed_total",
+		Help:      "Number of refused logins.",
+	})
+
+	// CartsPriced counts the carts priced for customers.
+	CartsPriced = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "carts_priced_total",
+		Help:      "Number of priced carts.",
+	})
+)
+
+// Register adds the collectors of the application, the pool statistics of
+// the database and the Go runtime and process collectors to the registry.
+func Register(reg prometheus.Registerer, db *sqlx.DB) error {
+	cs := []prometheus.Collector{
+		Requests,
+		Latency,
+		LoginsFailed,
+		CartsPriced,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	}
+	cs = append(cs, dbStats(db)...)
+
+	for _, c := range cs {
+		if err := reg.Register(c); err != nil {
+			return errors.Wrap(err, "registering collector")
+		}
+	}
+
+	return nil
+}
+
+// dbStats reads the statistics of the connection pool whenever the metrics
+// are scraped.
+func dbStats(db *sqlx.DB) []prometheus.Collector {
+	gauge := func(name, help string, value func() float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      name,
+			Help:      help,
+		}, value)
+	}
+	counter := func(name, help string, value func() float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      name,
+			Help:      help,
+		}, value)
+	}
+
+	return []prometheus.Collector{
+		gauge("max_open_connections", "Maximum number of open connections.", func() float64 {
+			return float64(db.Stats().MaxOpenConnections)
+		}),
+		gauge("open_connections", "Number of open connections.", func() float64 {
+			return float64(db.Stats().OpenConnections)
+		}),
+		gauge("in_use_connections", "Number of connections in use.", func() float64 {
+			return float64(db.Stats().InUse)
+		}),
+		gauge("idle_connections", "Number of idle connections.", func() float64 {
+			return float64(db.Stats().Idle)
+		}),
+		counter("wait_count_total", "Number of connections waited for.", func() float64 {
+			return float64(db.Stats().WaitCount)
+		}),
+		counter("wait_duration_seconds_total", "Time spent waiting for connections.", func() float64 {
+			return db.Stats().WaitDuration.Seconds()
+		}),
+		counter("max_idle_closed_total", "Number of connections closed for exceeding the idle limit.", func() float64 {
+			return float64(db.Stats().MaxIdleClosed)
+		}),
+		counter("max_lifetime_closed_total", "Number of connections closed for exceeding their lifetime.", func() float64 {
+			return float64(db.Stats().MaxLifetimeClosed)
+		}),
+	}
+}
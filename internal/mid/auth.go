@@ -45,6 +45,11 @@ func Authenticate(a *auth.Auth) web.Middleware {
 			// Add claims to the context so they can be retrieved later.
 			ctx = context.WithValue(ctx, auth.Key, claims)
 
+			// Record the user for the request log.
+			if v, ok := ctx.Value(web.KeyValues).(*web.Values); ok {
+				v.UserID = claims.Subject
+			}
+
 			// Call the next handler.
 			return handler(ctx, w, r)
 		}
@@ -55,6 +60,30 @@ func Authenticate(a *auth.Auth) web.Middleware {
 	return m
 }
 
+// AuthenticateOptional adds the claims of a valid JWT from the `Authorization`
+// header to the context, if one is provided. Requests without a token are
+// passed on as anonymous, a malformed or invalid token is still rejected.
+func AuthenticateOptional(a *auth.Auth) web.Middleware {
+	authenticate := Authenticate(a)
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+		authenticated := authenticate(handler)
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if r.Header.Get("authorization") == "" {
+				return handler(ctx, w, r)
+			}
+			return authenticated(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
 // Authorize validates that an authenticated user has at least one role from a
 // specified list. This method constructs the actual function that is used.
 func Authorize(roles ...string) web.Middleware {
@@ -0,0 +1,187 @@
+package mid
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/igorbelousov/shop-backend/foundation/web"
+)
+
+// minCompress is the smallest body worth compressing.
+const minCompress = 1024
+
+// Compress buffers the response to tag and compress it. Successful GET
+// responses get a strong ETag computed from the body and are answered with
+// 304 Not Modified when the client already holds them. Bodies are
+// compressed with brotli or gzip, whichever the client prefers.
+func Compress() web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			bw := bufferedWriter{ResponseWriter: w}
+
+			// Call the next handler with the buffer and send the response
+			// whatever the result, the error is handled further up.
+			err := handler(ctx, &bw, r)
+
+			status := bw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			body := bw.buf.Bytes()
+			hdr := w.Header()
+
+			var enc string
+			if len(body) >= minCompress && hdr.Get("Content-Encoding") == "" {
+				hdr.Add("Vary", "Accept-Encoding")
+				enc = negotiate(r.Header.Get("Accept-Encoding"))
+			}
+
+			if status == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+				etag := etagOf(body, enc)
+				hdr.Set("ETag", etag)
+
+				if noneMatch(r.Header.Get("If-None-Match"), etag) {
+					hdr.Del("Content-Type")
+					hdr.Del("Content-Length")
+					w.WriteHeader(http.StatusNotModified)
+					v.StatusCode = http.StatusNotModified
+					return err
+				}
+			}
+
+			if enc != "" {
+				var cbuf bytes.Buffer
+				if cerr := compress(&cbuf, enc, body); cerr != nil {
+					return cerr
+				}
+				body = cbuf.Bytes()
+				hdr.Set("Content-Encoding", enc)
+			}
+
+			if len(body) > 0 {
+				hdr.Set("Content-Length", strconv.Itoa(len(body)))
+			}
+			w.WriteHeader(status)
+			if _, werr := w.Write(body); werr != nil && err == nil {
+				return werr
+			}
+
+			return err
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// bufferedWriter holds the response back until the handler is done.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+// WriteHeader records the first status written.
+func (bw *bufferedWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+// Write buffers the body.
+func (bw *bufferedWriter) Write(p []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.buf.Write(p)
+}
+
+// etagOf returns the strong ETag of the body. Each encoding is a different
+// representation, so it gets its own tag.
+func etagOf(body []byte, enc string) string {
+	sum := sha256.Sum256(body)
+	tag := hex.EncodeToString(sum[:16])
+	if enc != "" {
+		tag += "-" + enc
+	}
+	return `"` + tag + `"`
+}
+
+// noneMatch reports whether the If-None-Match header lists the ETag.
+func noneMatch(header string, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate picks the encoding the client accepts with the highest
+// quality, brotli wins a tie. It returns an empty string if the client
+// accepts neither brotli nor gzip.
+func negotiate(header string) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		quality := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					quality = v
+				}
+			}
+		}
+		q[name] = quality
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range []string{"br", "gzip"} {
+		v, ok := q[enc]
+		if !ok {
+			v, ok = q["*"]
+		}
+		if ok && v > bestQ {
+			best, bestQ = enc, v
+		}
+	}
+
+	return best
+}
+
+// compress writes the body in the encoding to out.
+func compress(out io.Writer, enc string, body []byte) error {
+	var cw io.WriteCloser
+	switch enc {
+	case "br":
+		cw = brotli.NewWriterLevel(out, brotli.DefaultCompression)
+	default:
+		cw = gzip.NewWriter(out)
+	}
+
+	if _, err := cw.Write(body); err != nil {
+		return err
+	}
+	return cw.Close()
+}
@@ -0,0 +1,225 @@
+package mid_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/mid"
+)
+
+// big is a body long enough to be compressed.
+var big = strings.Repeat("shop", 512)
+
+// newCompressApp returns an app compressing the responses of handlers
+// answering big and small bodies, a POST and a 404.
+func newCompressApp() *web.App {
+	app := web.NewApp(make(chan os.Signal, 1), logger.New(ioutil.Discard, logger.Error), mid.Compress())
+	respond := func(body string, status int) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return web.Respond(ctx, w, body, status)
+		}
+	}
+	app.Handle(http.MethodGet, "/big", respond(big, http.StatusOK))
+	app.Handle(http.MethodPost, "/big", respond(big, http.StatusOK))
+	app.Handle(http.MethodGet, "/small", respond("ok", http.StatusOK))
+	app.Handle(http.MethodGet, "/missing", respond(big, http.StatusNotFound))
+	return app
+}
+
+// get sends a request to the app with the headers.
+func get(app *web.App, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	return w
+}
+
+// decompress returns the body of the response decoded from its encoding.
+func decompress(t *testing.T, w *httptest.ResponseRecorder) string {
+	var rd io.Reader = w.Body
+	switch w.Header().Get("Content-Encoding") {
+	case "gzip":
+		gr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatalf("Should be able to read gzip body : %s", err)
+		}
+		rd = gr
+	case "br":
+		rd = brotli.NewReader(w.Body)
+	}
+	body, err := ioutil.ReadAll(rd)
+	if err != nil {
+		t.Fatalf("Should be able to decode body : %s", err)
+	}
+	return string(body)
+}
+
+func TestCompressNegotiate(t *testing.T) {
+	app := newCompressApp()
+
+	tt := []struct {
+		name   string
+		accept string
+		enc    string
+	}{
+		{"none", "", ""},
+		{"identity", "identity", ""},
+		{"gzip", "gzip", "gzip"},
+		{"br", "br", "br"},
+		{"tie", "gzip, br", "br"},
+		{"quality", "br;q=0.5, gzip", "gzip"},
+		{"case", "GZIP;Q=0.8", "gzip"},
+		{"refused", "br;q=0, gzip;q=0", ""},
+		{"refused br", "br;q=0, gzip;q=0.1", "gzip"},
+		{"star", "*", "br"},
+		{"star quality", "*;q=0.5, gzip", "gzip"},
+		{"star without br", "br;q=0, *", "gzip"},
+		{"star refused", "*;q=0", ""},
+	}
+
+	var etags []string
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := get(app, http.MethodGet, "/big", map[string]string{"Accept-Encoding": tc.accept})
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Should answer 200 : %d", w.Code)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tc.enc {
+				t.Fatalf("Should encode with %q : %q", tc.enc, got)
+			}
+			if vary := strings.Join(w.Header().Values("Vary"), ", "); !strings.Contains(vary, "Accept-Encoding") {
+				t.Fatalf("Should vary on Accept-Encoding : %q", vary)
+			}
+			if body := decompress(t, w); !strings.Contains(body, big) {
+				t.Fatalf("Should decode to the body : %d bytes", len(body))
+			}
+
+			etag := w.Header().Get("ETag")
+			suffix := `"`
+			if tc.enc != "" {
+				suffix = "-" + tc.enc + `"`
+			}
+			if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, suffix) {
+				t.Fatalf("Should tag the %q encoding : %s", tc.enc, etag)
+			}
+			etags = append(etags, etag)
+		})
+	}
+
+	// Every encoding is a representation of its own, the tags of
+	// different encodings never match.
+	seen := map[string]bool{}
+	for _, etag := range etags {
+		seen[etag] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("Should have a tag per encoding : %v", seen)
+	}
+}
+
+func TestCompressSmall(t *testing.T) {
+	app := newCompressApp()
+
+	w := get(app, http.MethodGet, "/small", map[string]string{"Accept-Encoding": "gzip, br"})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Should answer 200 : %d", w.Code)
+	}
+	if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Fatalf("Should NOT compress a small body : %q", enc)
+	}
+	if vary := strings.Join(w.Header().Values("Vary"), ", "); strings.Contains(vary, "Accept-Encoding") {
+		t.Fatalf("Should NOT vary on Accept-Encoding : %q", vary)
+	}
+	if etag := w.Header().Get("ETag"); etag == "" || strings.Contains(etag, "-") {
+		t.Fatalf("Should tag the body without encoding : %q", etag)
+	}
+	if body := w.Body.String(); body != `"ok"` {
+		t.Fatalf("Should send the body as is : %q", body)
+	}
+}
+
+func TestCompressNotModified(t *testing.T) {
+	app := newCompressApp()
+	gzipped := map[string]string{"Accept-Encoding": "gzip"}
+
+	etag := get(app, http.MethodGet, "/big", gzipped).Header().Get("ETag")
+	plain := get(app, http.MethodGet, "/big", nil).Header().Get("ETag")
+
+	tt := []struct {
+		name   string
+		match  string
+		status int
+	}{
+		{"same", etag, http.StatusNotModified},
+		{"list", `"other", ` + etag, http.StatusNotModified},
+		{"list without spaces", `"other",` + etag + `,"more"`, http.StatusNotModified},
+		{"weak", "W/" + etag, http.StatusNotModified},
+		{"star", "*", http.StatusNotModified},
+		{"other", `"other"`, http.StatusOK},
+		{"other encoding", plain, http.StatusOK},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := get(app, http.MethodGet, "/big", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": tc.match})
+
+			if w.Code != tc.status {
+				t.Fatalf("Should answer %d : %d", tc.status, w.Code)
+			}
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Fatalf("Should send the ETag %s : %s", etag, got)
+			}
+			if tc.status == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Fatalf("Should NOT send a body with 304 : %d bytes", w.Body.Len())
+			}
+		})
+	}
+}
+
+func TestCompressUntagged(t *testing.T) {
+	app := newCompressApp()
+
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{"post", http.MethodPost, "/big", http.StatusOK},
+		{"not found", http.MethodGet, "/missing", http.StatusNotFound},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := get(app, tc.method, tc.path, map[string]string{"Accept-Encoding": "gzip", "If-None-Match": "*"})
+
+			if w.Code != tc.status {
+				t.Fatalf("Should answer %d : %d", tc.status, w.Code)
+			}
+			if etag := w.Header().Get("ETag"); etag != "" {
+				t.Fatalf("Should NOT tag the response : %s", etag)
+			}
+			if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+				t.Fatalf("Should still compress the response : %q", enc)
+			}
+			if body := decompress(t, w); !strings.Contains(body, big) {
+				t.Fatalf("Should decode to the body : %d bytes", len(body))
+			}
+		})
+	}
+}
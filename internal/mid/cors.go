@@ -0,0 +1,134 @@
+package mid
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/igorbelousov/shop-backend/foundation/web"
+)
+
+// CORSPolicy describes which cross origin requests are allowed. Origins are
+// matched exactly or as patterns with a single * wildcard like
+// https://*.example.com, a lone * allows every origin.
+type CORSPolicy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CORSConfig is the policy applied to all routes with overrides for single
+// routes. Routes are keyed by the pattern they are registered with, for
+// example /wishlist/shared/:token.
+type CORSConfig struct {
+	CORSPolicy
+	Routes map[string]CORSPolicy
+}
+
+// corsHeaders are the response headers owned by the middleware.
+var corsHeaders = []string{
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Allow-Methods",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Expose-Headers",
+	"Access-Control-Max-Age",
+}
+
+// CORS sets the cross origin headers for the requests of allowed origins and
+// answers preflight requests without calling the handler.
+func CORS(cfg CORSConfig) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			policy := cfg.CORSPolicy
+			if p, ok := cfg.Routes[web.Route(r)]; ok {
+				policy = p
+			}
+
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			hdr := w.Header()
+			for _, name := range corsHeaders {
+				hdr.Del(name)
+			}
+			hdr.Add("Vary", "Origin")
+
+			if origin == "" || !policy.allows(origin) {
+				if preflight {
+					return web.Respond(ctx, w, nil, http.StatusNoContent)
+				}
+				return handler(ctx, w, r)
+			}
+
+			// Credentials are never sent to a wildcard origin, so the
+			// request origin is echoed back instead.
+			switch {
+			case policy.AllowCredentials:
+				hdr.Set("Access-Control-Allow-Origin", origin)
+				hdr.Set("Access-Control-Allow-Credentials", "true")
+			case policy.any():
+				hdr.Set("Access-Control-Allow-Origin", "*")
+			default:
+				hdr.Set("Access-Control-Allow-Origin", origin)
+			}
+
+			if !preflight {
+				if len(policy.ExposedHeaders) > 0 {
+					hdr.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+				}
+				return handler(ctx, w, r)
+			}
+
+			hdr.Set("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+			hdr.Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+			if policy.MaxAge > 0 {
+				hdr.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+			}
+
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// any reports whether the policy allows every origin.
+func (p CORSPolicy) any() bool {
+	for _, o := range p.AllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether the origin matches one of the allowed origins.
+func (p CORSPolicy) allows(origin string) bool {
+	for _, o := range p.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+		i := strings.Index(o, "*")
+		if i < 0 {
+			continue
+		}
+		prefix, suffix := o[:i], o[i+1:]
+		if len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
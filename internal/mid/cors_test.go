@@ -0,0 +1,117 @@
+package mid_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/mid"
+)
+
+// newApp returns an app running the middleware with a handler answering
+// 200 for GET on the path.
+func newApp(path string, mw ...web.Middleware) *web.App {
+	app := web.NewApp(make(chan os.Signal, 1), logger.New(ioutil.Discard, logger.Error), mw...)
+	app.Handle(http.MethodGet, path, func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, "ok", http.StatusOK)
+	})
+	return app
+}
+
+func TestCORS(t *testing.T) {
+	cfg := mid.CORSConfig{
+		CORSPolicy: mid.CORSPolicy{
+			AllowedOrigins:   []string{"https://shop.example.com", "https://*.admin.example.com"},
+			AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+			AllowedHeaders:   []string{"Authorization"},
+			ExposedHeaders:   []string{"ETag"},
+			AllowCredentials: true,
+			MaxAge:           time.Hour,
+		},
+		Routes: map[string]mid.CORSPolicy{
+			"/shared/:token": {
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{http.MethodGet},
+			},
+		},
+	}
+	app := newApp("/items/:id", mid.CORS(cfg))
+	app.Handle(http.MethodGet, "/shared/:token", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, "ok", http.StatusOK)
+	})
+
+	tt := []struct {
+		name      string
+		path      string
+		origin    string
+		preflight bool
+		status    int
+		allow     string
+	}{
+		{"exact", "/items/1", "https://shop.example.com", false, http.StatusOK, "https://shop.example.com"},
+		{"exact case", "/items/1", "HTTPS://SHOP.EXAMPLE.COM", false, http.StatusOK, "HTTPS://SHOP.EXAMPLE.COM"},
+		{"pattern", "/items/1", "https://eu.admin.example.com", false, http.StatusOK, "https://eu.admin.example.com"},
+		{"pattern empty", "/items/1", "https://.admin.example.com", false, http.StatusOK, ""},
+		{"pattern base", "/items/1", "https://admin.example.com", false, http.StatusOK, ""},
+		{"pattern suffix", "/items/1", "https://eu.admin.example.com.evil.com", false, http.StatusOK, ""},
+		{"other", "/items/1", "https://evil.com", false, http.StatusOK, ""},
+		{"no origin", "/items/1", "", false, http.StatusOK, ""},
+		{"preflight", "/items/1", "https://shop.example.com", true, http.StatusNoContent, "https://shop.example.com"},
+		{"preflight other", "/items/1", "https://evil.com", true, http.StatusNoContent, ""},
+		{"route", "/shared/abc", "https://evil.com", false, http.StatusOK, "*"},
+	}
+
+	for _, tc := range tt {
+		method := http.MethodGet
+		if tc.preflight {
+			method = http.MethodOptions
+		}
+		req := httptest.NewRequest(method, tc.path, nil)
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		if tc.preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+
+		hdr := rec.Header()
+		if rec.Code != tc.status {
+			t.Fatalf("%s: Should receive status %d : got %d", tc.name, tc.status, rec.Code)
+		}
+		if got := hdr.Get("Access-Control-Allow-Origin"); got != tc.allow {
+			t.Fatalf("%s: Should allow origin %q : got %q", tc.name, tc.allow, got)
+		}
+		if hdr.Get("Vary") == "" {
+			t.Fatalf("%s: Should vary on the origin", tc.name)
+		}
+
+		switch {
+		case tc.allow == "" || tc.allow == "*":
+			if got := hdr.Get("Access-Control-Allow-Credentials"); got != "" {
+				t.Fatalf("%s: Should not allow credentials : got %q", tc.name, got)
+			}
+		case tc.preflight:
+			if got := hdr.Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+				t.Fatalf("%s: Should list the allowed methods : got %q", tc.name, got)
+			}
+			if got := hdr.Get("Access-Control-Max-Age"); got != "3600" {
+				t.Fatalf("%s: Should set the max age : got %q", tc.name, got)
+			}
+		default:
+			if got := hdr.Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Fatalf("%s: Should allow credentials : got %q", tc.name, got)
+			}
+			if got := hdr.Get("Access-Control-Expose-Headers"); got != "ETag" {
+				t.Fatalf("%s: Should expose the headers : got %q", tc.name, got)
+			}
+		}
+	}
+}
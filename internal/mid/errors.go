@@ -2,17 +2,18 @@ package mid
 
 import (
 	"context"
-	"log"
 	"net/http"
 
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // Errors handles errors coming out of the call chain. It detects normal
 // application errors which are used to respond to the client in a uniform way.
 // Unexpected errors (status >= 500) are logged.
-func Errors(log *log.Logger) web.Middleware {
+func Errors(log *logger.Logger) web.Middleware {
 
 	// This is the actual middleware function to be executed.
 	m := func(handler web.Handler) web.Handler {
@@ -32,8 +33,13 @@ func Errors(log *log.Logger) web.Middleware {
 			// Run the next handler and catch any propagated error.
 			if err := handler(ctx, w, r); err != nil {
 
-				// Log the error.
-				log.Printf("%s: ERROR: %v", v.TraceID, err)
+				// Log the error, expected errors of the client are only
+				// worth a warning.
+				level := log.Error
+				if webErr, ok := errors.Cause(err).(*web.Error); ok && webErr.Status < http.StatusInternalServerError {
+					level = log.Warn
+				}
+				level("request failed", logger.TraceID(v.TraceID), logger.Err(err))
 
 				// Respond to the error.
 				if err := web.RespondError(ctx, w, err); err != nil {
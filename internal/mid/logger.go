@@ -2,16 +2,16 @@ package mid
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"time"
 
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/foundation/web"
 )
 
-// Logger writes some information about the request to the logs in the
-// format: TraceID : (200) GET /foo -> IP ADDR (latency)
-func Logger(log *log.Logger) web.Middleware {
+// Logger writes an entry when a request starts and one when it completes
+// with the route, status, latency and the authenticated user.
+func Logger(log *logger.Logger) web.Middleware {
 
 	// This is the actual middleware function to be executed.
 	m := func(handler web.Handler) web.Handler {
@@ -25,18 +25,25 @@ func Logger(log *log.Logger) web.Middleware {
 				return web.NewShutdownError("web value missing from context")
 			}
 
-			log.Printf("%s: started: %s %s -> %s",
-				v.TraceID,
-				r.Method, r.URL.Path, r.RemoteAddr,
+			log.Debug("request started",
+				logger.TraceID(v.TraceID),
+				logger.String("method", r.Method),
+				logger.String("path", r.URL.Path),
+				logger.String("remote_addr", r.RemoteAddr),
 			)
 
 			// Call the next handler.
 			err := handler(ctx, w, r)
 
-			log.Printf("%s: completed: %s %s -> %s (%d) (%s)",
-				v.TraceID,
-				r.Method, r.URL.Path, r.RemoteAddr,
-				v.StatusCode, time.Since(v.Now),
+			log.Info("request completed",
+				logger.TraceID(v.TraceID),
+				logger.String("method", r.Method),
+				logger.String("route", web.Route(r)),
+				logger.String("path", r.URL.Path),
+				logger.String("remote_addr", r.RemoteAddr),
+				logger.Int("status", v.StatusCode),
+				logger.Duration("latency_ms", time.Since(v.Now)),
+				logger.String("user_id", v.UserID),
 			)
 
 			// Return the error so it can be handled further up the chain.
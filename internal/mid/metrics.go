@@ -5,9 +5,14 @@ import (
 	"expvar"
 	"net/http"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/metrics"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -22,7 +27,7 @@ var m = struct {
 	err: expvar.NewInt("errors"),
 }
 
-// Metrics updates program counters.
+// Metrics updates program counters and the Prometheus request metrics.
 func Metrics() web.Middleware {
 
 	// This is the actual middleware function to be executed.
@@ -39,9 +44,32 @@ func Metrics() web.Middleware {
 				return handler(ctx, w, r)
 			}
 
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return web.NewShutdownError("web value missing from context")
+			}
+
 			// Call the next handler.
 			err := handler(ctx, w, r)
 
+			// Errors are responded to further up the chain, take the status
+			// they will be answered with.
+			status := v.StatusCode
+			if err != nil {
+				status = http.StatusInternalServerError
+				if webErr, ok := errors.Cause(err).(*web.Error); ok {
+					status = webErr.Status
+				}
+			}
+
+			labels := prometheus.Labels{
+				"method": r.Method,
+				"route":  web.Route(r),
+				"status": strconv.Itoa(status),
+			}
+			metrics.Requests.With(labels).Inc()
+			metrics.Latency.With(labels).Observe(time.Since(v.Now).Seconds())
+
 			// Increment the request counter.
 			m.req.Add(1)
 
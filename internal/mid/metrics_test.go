@@ -0,0 +1,57 @@
+package mid_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/metrics"
+	"github.com/igorbelousov/shop-backend/internal/mid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	log := logger.New(ioutil.Discard, logger.Error)
+	app := newApp("/items/:id", mid.Errors(log), mid.Metrics())
+	app.Handle(http.MethodGet, "/missing/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.NewRequestError(errors.New("item not found"), http.StatusNotFound)
+	})
+	app.Handle(http.MethodGet, "/broken", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return errors.Wrap(errors.New("boom"), "handling")
+	})
+
+	tt := []struct {
+		name   string
+		path   string
+		route  string
+		status int
+	}{
+		{"ok", "/items/42", "/items/:id", http.StatusOK},
+		{"request error", "/missing/42", "/missing/:id", http.StatusNotFound},
+		{"other error", "/broken", "/broken", http.StatusInternalServerError},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			counter := metrics.Requests.WithLabelValues(http.MethodGet, tc.route, strconv.Itoa(tc.status))
+			before := testutil.ToFloat64(counter)
+
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Fatalf("Should answer %d : %d", tc.status, w.Code)
+			}
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Fatalf("Should count the request with route %s and status %d : %v", tc.route, tc.status, got)
+			}
+		})
+	}
+}
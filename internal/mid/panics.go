@@ -2,10 +2,10 @@ package mid
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"runtime/debug"
 
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/foundation/web"
 	"github.com/pkg/errors"
 	"go.opentelemetry.io/otel/trace"
@@ -13,7 +13,7 @@ import (
 
 // Panics recovers from panics and converts the panic to an error so it is
 // reported in Metrics and handled in Errors.
-func Panics(log *log.Logger) web.Middleware {
+func Panics(log *logger.Logger) web.Middleware {
 
 	// This is the actual middleware function to be executed.
 	m := func(handler web.Handler) web.Handler {
@@ -37,7 +37,7 @@ func Panics(log *log.Logger) web.Middleware {
 					err = errors.Errorf("panic: %v", r)
 
 					// Log the Go stack trace for this panic'd goroutine.
-					log.Printf("%s: PANIC:\n%s", v.TraceID, debug.Stack())
+					log.Error("panic", logger.TraceID(v.TraceID), logger.String("stack", string(debug.Stack())))
 				}
 			}()
 
@@ -0,0 +1,181 @@
+package mid
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/igorbelousov/shop-backend/foundation/ratelimit"
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/auth"
+	"github.com/pkg/errors"
+)
+
+// KeyFunc picks the bucket a request takes its token from.
+type KeyFunc func(ctx context.Context, r *http.Request) string
+
+// ByIP keys requests by the address of the client.
+func ByIP(ctx context.Context, r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ByClientIP keys requests by the address of the client as seen through the
+// trusted proxies, like the ingress in front of the service. When the
+// connection comes from a trusted proxy the X-Forwarded-For header is read
+// from the right, the first address not belonging to a trusted proxy is the
+// client. Without trusted proxies it keys requests just like ByIP.
+func ByClientIP(trusted []*net.IPNet) KeyFunc {
+	isTrusted := func(host string) bool {
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false
+		}
+		for _, n := range trusted {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	f := func(ctx context.Context, r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if !isTrusted(host) {
+			return "ip:" + host
+		}
+
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			host = hop
+			if !isTrusted(hop) {
+				break
+			}
+		}
+		return "ip:" + host
+	}
+
+	return f
+}
+
+// ParseProxies parses the addresses of trusted proxies given as CIDRs or
+// single IPs.
+func ParseProxies(proxies []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, errors.Errorf("invalid proxy address %q", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid proxy address %q", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Except exempts requests matching one of the routes from the limit, like
+// health checks polled by the orchestrator.
+func Except(key KeyFunc, routes ...string) KeyFunc {
+	f := func(ctx context.Context, r *http.Request) string {
+		route := web.Route(r)
+		for _, exempt := range routes {
+			if route == exempt {
+				return ""
+			}
+		}
+		return key(ctx, r)
+	}
+
+	return f
+}
+
+// BySubject keys requests by the subject of the JWT. Anonymous requests are
+// keyed by IP, so it is best used after Authenticate.
+func BySubject(ctx context.Context, r *http.Request) string {
+	if claims, ok := ctx.Value(auth.Key).(auth.Claims); ok {
+		return "sub:" + claims.Subject
+	}
+	return ByIP(ctx, r)
+}
+
+// ByRoute keys requests by the route they matched, all clients share the
+// limit.
+func ByRoute(ctx context.Context, r *http.Request) string {
+	return "route:" + r.Method + " " + web.Route(r)
+}
+
+// RateLimit allows the requests of each key the given limit. The name
+// separates the buckets of different limits in a shared store. Every
+// response carries the X-RateLimit-* headers, requests over the limit are
+// rejected with 429 and a Retry-After header. Requests the key function
+// returns an empty key for are not limited.
+func RateLimit(store ratelimit.Store, name string, l ratelimit.Limit, key KeyFunc) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			k := key(ctx, r)
+			if k == "" {
+				return handler(ctx, w, r)
+			}
+
+			res, err := store.Take(ctx, name+":"+k, l, v.Now)
+			if err != nil {
+				return errors.Wrap(err, "taking rate limit token")
+			}
+
+			hdr := w.Header()
+			hdr.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			hdr.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			hdr.Set("X-RateLimit-Reset", strconv.FormatInt(res.Reset.Unix(), 10))
+
+			if !res.Allowed {
+				hdr.Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+				err := errors.New("too many requests")
+				return web.NewRequestError(err, http.StatusTooManyRequests)
+			}
+
+			// Call the next handler.
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
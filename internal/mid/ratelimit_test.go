@@ -0,0 +1,91 @@
+package mid_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/igorbelousov/shop-backend/foundation/logger"
+	"github.com/igorbelousov/shop-backend/foundation/ratelimit"
+	"github.com/igorbelousov/shop-backend/foundation/web"
+	"github.com/igorbelousov/shop-backend/internal/mid"
+)
+
+func TestByClientIP(t *testing.T) {
+	proxies, err := mid.ParseProxies([]string{"10.0.0.0/8", "192.168.1.1", " "})
+	if err != nil {
+		t.Fatalf("Should be able to parse proxies : %s", err)
+	}
+	key := mid.ByClientIP(proxies)
+
+	tt := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		key       string
+	}{
+		{"direct", "203.0.113.7:1234", nil, "ip:203.0.113.7"},
+		{"untrusted forwarder", "203.0.113.7:1234", []string{"198.51.100.1"}, "ip:203.0.113.7"},
+		{"proxy", "10.1.2.3:1234", []string{"198.51.100.1"}, "ip:198.51.100.1"},
+		{"proxy without header", "10.1.2.3:1234", nil, "ip:10.1.2.3"},
+		{"proxy chain", "10.1.2.3:1234", []string{"6.6.6.6, 198.51.100.1, 192.168.1.1"}, "ip:198.51.100.1"},
+		{"headers", "10.1.2.3:1234", []string{"6.6.6.6", "198.51.100.1"}, "ip:198.51.100.1"},
+		{"garbage", "10.1.2.3:1234", []string{"6.6.6.6, junk, 10.0.0.9"}, "ip:10.0.0.9"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remote
+			for _, f := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", f)
+			}
+			if got := key(context.Background(), r); got != tc.key {
+				t.Fatalf("Should key the request as %s : %s", tc.key, got)
+			}
+		})
+	}
+
+	if _, err := mid.ParseProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatalf("Should NOT be able to parse an invalid CIDR")
+	}
+	if _, err := mid.ParseProxies([]string{"proxy"}); err == nil {
+		t.Fatalf("Should NOT be able to parse an invalid IP")
+	}
+}
+
+func TestRateLimitExcept(t *testing.T) {
+	log := logger.New(ioutil.Discard, logger.Error)
+	limit := ratelimit.Limit{Requests: 1, Per: time.Hour}
+	key := mid.Except(mid.ByIP, "/readiness")
+	app := newApp("/readiness", mid.Errors(log), mid.RateLimit(ratelimit.NewMemory(), "api", limit, key))
+	app.Handle(http.MethodGet, "/items", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, "ok", http.StatusOK)
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		w := get("/readiness")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Should never limit an exempt route : %d", w.Code)
+		}
+		if h := w.Header().Get("X-RateLimit-Limit"); h != "" {
+			t.Fatalf("Should NOT send rate limit headers for an exempt route : %s", h)
+		}
+	}
+
+	if code := get("/items").Code; code != http.StatusOK {
+		t.Fatalf("Should allow the first request : %d", code)
+	}
+	if code := get("/items").Code; code != http.StatusTooManyRequests {
+		t.Fatalf("Should limit the other routes : %d", code)
+	}
+}
@@ -5,12 +5,12 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
-	"log"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/igorbelousov/shop-backend/foundation/database"
+	"github.com/igorbelousov/shop-backend/foundation/logger"
 	"github.com/igorbelousov/shop-backend/internal/auth"
 	"github.com/igorbelousov/shop-backend/internal/data/schema"
 	"github.com/igorbelousov/shop-backend/internal/data/user"
@@ -35,7 +35,7 @@ var (
 type Test struct {
 	TraceID  string
 	DB       *sqlx.DB
-	Log      *log.Logger
+	Log      *logger.Logger
 	Auth     *auth.Auth
 	KID      string
 	Teardown func()
@@ -43,7 +43,7 @@ type Test struct {
 	t *testing.T
 }
 
-func NewUnit(t *testing.T) (*log.Logger, *sqlx.DB, func()) {
+func NewUnit(t *testing.T) (*logger.Logger, *sqlx.DB, func()) {
 	c := startContainer(t, dbImage, dbPort, dbArgs...)
 
 	db, err := database.Open(database.Config{
@@ -94,7 +94,7 @@ func NewUnit(t *testing.T) (*log.Logger, *sqlx.DB, func()) {
 		stopContainer(t, c.ID)
 	}
 
-	log := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	log := logger.New(os.Stdout, logger.Debug, logger.String("service", "TEST"))
 
 	return log, db, teardown
 }